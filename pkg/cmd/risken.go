@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ca-risken/security-review/pkg/review"
+	"github.com/spf13/cobra"
+)
+
+var riskenCmd = &cobra.Command{
+	Use:   "risken",
+	Short: "Manage the findings sent to RISKEN",
+}
+
+var riskenFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Send the findings spooled by --risken-spool-dir to RISKEN, e.g. after the RISKEN outage",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if opt.RiskenSpoolDir == "" || opt.RiskenApiEndpoint == "" || opt.RiskenApiToken == "" {
+			log.Fatal("Missing required parameters")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+		return review.FlushRiskenSpool(ctx, &opt, logger)
+	},
+}
+
+func init() {
+	riskenCmd.AddCommand(riskenFlushCmd)
+	rootCmd.AddCommand(riskenCmd)
+}
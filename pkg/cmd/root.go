@@ -2,12 +2,20 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ca-risken/security-review/pkg/review"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/ca-risken/security-review/pkg/scm/bitbucket"
+	"github.com/ca-risken/security-review/pkg/verify"
 	"github.com/spf13/cobra"
 )
 
@@ -17,8 +25,14 @@ var rootCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
 		defer cancel()
+		if !hasRequiredParameters() {
+			log.Fatal("Missing required parameters")
+		}
 		logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-		riskenService := review.NewReviewService(ctx, &opt, logger)
+		riskenService, err := review.NewReviewService(ctx, &opt, logger)
+		if err != nil {
+			return err
+		}
 		return riskenService.Run(ctx)
 	},
 }
@@ -27,17 +41,67 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
-var opt review.ReviewOption
+var (
+	opt                     review.ReviewOption
+	bitbucketPullRequestURL string
+)
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&opt.GithubToken, "github-token", "", "GitHub token")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubAPIURL, "github-api-url", "", "GitHub API URL for GitHub Enterprise Server, e.g. https://github.example.com/api/v3 (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubUploadURL, "github-upload-url", "", "GitHub upload URL for GitHub Enterprise Server, derived from --github-api-url if empty (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubCABundle, "github-ca-bundle", "", "PEM file of the additional CA certificates to connect to GitHub Enterprise Server (optional)")
+	rootCmd.PersistentFlags().Int64Var(&opt.GithubAppID, "github-app-id", 0, "GitHub App ID to authenticate as the GitHub App instead of --github-token (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubAppPrivateKey, "github-app-private-key", "", "GitHub App private key (PEM or the file path) (optional)")
+	rootCmd.PersistentFlags().Int64Var(&opt.GithubAppInstallationID, "github-app-installation-id", 0, "GitHub App installation ID, found by the repository if empty (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubRepository, "github-repository", "", "GitHub repository (owner/repo) to find the GitHub App installation (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubEventName, "github-event-name", "", "GitHub event name (pull_request, pull_request_target, push, merge_group, workflow_dispatch, schedule) (optional)")
 	rootCmd.PersistentFlags().StringVar(&opt.GithubEventPath, "github-event-path", "", "GitHub event path")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubSHA, "github-sha", "", "Commit SHA to review on workflow_dispatch and schedule events (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GithubRef, "github-ref", "", "Git ref of the commit to review, e.g. refs/heads/main. Stale findings are resolved only on the default branch (optional)")
 	rootCmd.PersistentFlags().StringVar(&opt.GithubWorkspace, "github-workspace", "", "GitHub workspace path")
+	rootCmd.PersistentFlags().StringVar(&opt.SCM, "scm", "", "Source code management: github, gitlab, gitea or bitbucket. Default: detected from the CI environment variables, otherwise github (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GitlabToken, "gitlab-token", "", "GitLab access token with the api scope to comment on the merge request (required for gitlab)")
+	rootCmd.PersistentFlags().StringVar(&opt.GitlabAPIURL, "gitlab-api-url", "", "GitLab API URL, e.g. https://gitlab.example.com/api/v4 (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GitlabCABundle, "gitlab-ca-bundle", "", "PEM file of the additional CA certificates to connect to the self-managed GitLab (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GitlabProjectID, "gitlab-project-id", "", "GitLab project ID or path (group/project) (required for gitlab)")
+	rootCmd.PersistentFlags().IntVar(&opt.GitlabMergeRequestIID, "gitlab-merge-request-iid", 0, "GitLab merge request IID to review. The pipelines not for the merge request are skipped if empty (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GiteaToken, "gitea-token", "", "Gitea (Forgejo) access token with the repository write scope (required for gitea)")
+	rootCmd.PersistentFlags().StringVar(&opt.GiteaAPIURL, "gitea-api-url", "", "Gitea (Forgejo) API URL, e.g. https://gitea.example.com/api/v1 (required for gitea)")
+	rootCmd.PersistentFlags().StringVar(&opt.GiteaCABundle, "gitea-ca-bundle", "", "PEM file of the additional CA certificates to connect to Gitea (Forgejo) (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.GiteaRepository, "gitea-repository", "", "Gitea (Forgejo) repository (owner/repo) (required for gitea)")
+	rootCmd.PersistentFlags().IntVar(&opt.GiteaPullRequest, "gitea-pull-request", 0, "Gitea (Forgejo) pull request number to review. The runs not for the pull request are skipped if empty (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.BitbucketToken, "bitbucket-token", "", "Bitbucket Data Center HTTP access token with the repository write permission (required for bitbucket)")
+	rootCmd.PersistentFlags().StringVar(&bitbucketPullRequestURL, "bitbucket-pull-request-url", "", "Bitbucket Data Center pull request URL to review, which sets the API URL, the project key, the repository and the pull request (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.BitbucketAPIURL, "bitbucket-api-url", "", "Bitbucket Data Center API URL, e.g. https://bitbucket.example.com/rest/api/1.0 (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.BitbucketCABundle, "bitbucket-ca-bundle", "", "PEM file of the additional CA certificates to connect to Bitbucket Data Center (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.BitbucketProjectKey, "bitbucket-project-key", "", "Bitbucket Data Center project key (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.BitbucketRepository, "bitbucket-repository", "", "Bitbucket Data Center repository slug (optional)")
+	rootCmd.PersistentFlags().IntVar(&opt.BitbucketPullRequest, "bitbucket-pull-request", 0, "Bitbucket Data Center pull request ID to review. The builds not for the pull request are skipped if empty (optional)")
 	rootCmd.PersistentFlags().StringVar(&opt.RiskenConsoleURL, "risken-console-url", "", "RISKEN Console URL (optional)")
 	rootCmd.PersistentFlags().StringVar(&opt.RiskenApiEndpoint, "risken-api-endpoint", "", "RISKEN API endpoint (optional)")
 	rootCmd.PersistentFlags().StringVar(&opt.RiskenApiToken, "risken-api-token", "", "RISKEN API token for authentication (optional)")
+	rootCmd.PersistentFlags().BoolVar(&opt.RiskenRequired, "risken-required", false, "If true, exit 1 if any finding failed to be sent to RISKEN. The review comments are posted anyway (optional)")
+	rootCmd.PersistentFlags().Uint32Var(&opt.RiskenProjectID, "risken-project-id", 0, "RISKEN project ID of the findings. Default: the project of the API token. Also RISKEN_PROJECT_ID env (optional)")
+	rootCmd.PersistentFlags().StringSliceVar(&opt.RiskenProjectRoutes, "risken-project-route", nil, "Route the findings to the RISKEN project by the repository pattern and the path prefix, in the form of <repository>[:<path prefix>]=<project ID>, e.g. owner/mono:services/payment=2. The first matched route wins. Repeatable (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.RiskenScorePolicy, "risken-score-policy", "", "YAML file of the score policy to override the RISKEN score of the findings by the scanner, the rule ID, the CWE, the path and the secret verification. Also RISKEN_SCORE_POLICY env (optional)")
+	rootCmd.PersistentFlags().StringSliceVar(&opt.RiskenTags, "risken-tag", nil, "Tag attached to the RISKEN findings in addition to the repository, PR and branch tags, e.g. team:security. Repeatable (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.RiskenTriage, "risken-triage", "", "Handling of the findings pended (archived or marked as false positive) in RISKEN: skip (not commented), annotate (commented with the status) or off (not looked up). The triaged findings do not fail --error. Default: skip (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.RiskenSpoolDir, "risken-spool-dir", "", "Directory to spool the findings failed to be sent to RISKEN by the transient errors, sent later by the risken flush command. Also RISKEN_SPOOL_DIR env (optional)")
 	rootCmd.PersistentFlags().BoolVar(&opt.ErrorFlag, "error", false, "Exit 1 if there are findings (optional)")
 	rootCmd.PersistentFlags().BoolVar(&opt.NoPRComment, "no-pr-comment", false, "If true, do not post PR comments (optional)")
+	rootCmd.PersistentFlags().BoolVar(&opt.NoIncremental, "no-incremental", false, "If true, review all PR files on synchronize events instead of the files changed since the previous review (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.Mode, "mode", "", "Scan mode: diff (changed lines) or full (all tracked files). Default: full on workflow_dispatch and schedule events, otherwise diff (optional)")
+	rootCmd.PersistentFlags().BoolVar(&opt.DryRun, "dry-run", false, "If true, run the review without posting to GitHub or RISKEN, and print the requests that would be sent (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.DryRunOutput, "dry-run-output", "", "Write the requests of --dry-run as JSON to the file instead of stdout (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.Output, "output", "", "Write the review results as JSON to the file, e.g. for the workflow artifact (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.CacheDir, "cache-dir", "", "Directory to cache the semgrep and gitleaks results by file content, e.g. restored by actions/cache (optional)")
+	rootCmd.PersistentFlags().BoolVar(&opt.NoCache, "no-cache", false, "If true, do not use the scan cache even if --cache-dir is set (optional)")
+	rootCmd.PersistentFlags().StringVar(&opt.AdvisoryDBPath, "advisory-db", "", "OSV advisory database path (directory or zip) for dependency scan (optional)")
+	rootCmd.PersistentFlags().BoolVar(&opt.EntropyScan, "entropy", false, "If true, detect high-entropy secrets assigned to suspicious identifiers (optional)")
+	rootCmd.PersistentFlags().BoolVar(&opt.VerifySecrets, "verify-secrets", false, "If true, verify whether the detected secrets are live against the provider APIs (optional)")
+	rootCmd.PersistentFlags().StringToStringVar(&opt.VerifyEndpoints, "verify-endpoint", nil, fmt.Sprintf("Override the verification endpoint by verifier (%s), e.g. github=http://localhost:8080. The bearer verifier is enabled only if set (optional)", strings.Join(verify.Names(), ", ")))
+	rootCmd.PersistentFlags().Float64Var(&opt.EntropyThreshold, "entropy-threshold", scanner.DEFAULT_ENTROPY_THRESHOLD, "Shannon entropy threshold (bits per character) for --entropy (optional)")
 
 	cobra.OnInitialize(initoptig)
 }
@@ -48,14 +112,111 @@ func initoptig() {
 	if opt.GithubToken == "" {
 		opt.GithubToken = getEnv("GITHUB_TOKEN")
 	}
+	if opt.GithubAPIURL == "" {
+		opt.GithubAPIURL = getEnv("GITHUB_API_URL")
+	}
+	if opt.GithubAppID == 0 {
+		if appID, err := strconv.ParseInt(getEnv("GITHUB_APP_ID"), 10, 64); err == nil {
+			opt.GithubAppID = appID
+		}
+	}
+	if opt.GithubAppPrivateKey == "" {
+		opt.GithubAppPrivateKey = getEnv("GITHUB_APP_PRIVATE_KEY")
+	}
+	if opt.GithubRepository == "" {
+		opt.GithubRepository = getEnv("GITHUB_REPOSITORY")
+	}
+	if opt.GithubEventName == "" {
+		opt.GithubEventName = getEnv("GITHUB_EVENT_NAME")
+	}
+	if opt.GithubSHA == "" {
+		opt.GithubSHA = getEnv("GITHUB_SHA")
+	}
+	if opt.GithubRef == "" {
+		opt.GithubRef = getEnv("GITHUB_REF")
+	}
 	if opt.GithubEventPath == "" {
 		opt.GithubEventPath = getEnv("GITHUB_EVENT_PATH")
 	}
 	if opt.GithubWorkspace == "" {
 		opt.GithubWorkspace = getEnv("GITHUB_WORKSPACE")
 	}
-	if opt.GithubToken == "" || opt.GithubEventPath == "" || opt.GithubWorkspace == "" {
-		log.Fatal("Missing required parameters")
+	// https://docs.gitlab.com/ee/ci/variables/predefined_variables.html
+	if opt.SCM == "" && getEnv("GITLAB_CI") == "true" {
+		opt.SCM = scm.PROVIDER_GITLAB
+	}
+	if opt.GitlabToken == "" {
+		opt.GitlabToken = getEnv("GITLAB_TOKEN")
+	}
+	if opt.GitlabAPIURL == "" {
+		opt.GitlabAPIURL = getEnv("CI_API_V4_URL")
+	}
+	if opt.GitlabCABundle == "" {
+		opt.GitlabCABundle = getEnv("CI_SERVER_TLS_CA_FILE")
+	}
+	if opt.GitlabProjectID == "" {
+		opt.GitlabProjectID = getEnv("CI_PROJECT_ID")
+	}
+	if opt.GitlabMergeRequestIID == 0 {
+		if iid, err := strconv.Atoi(getEnv("CI_MERGE_REQUEST_IID")); err == nil {
+			opt.GitlabMergeRequestIID = iid
+		}
+	}
+	if opt.GithubWorkspace == "" && opt.SCM == scm.PROVIDER_GITLAB {
+		opt.GithubWorkspace = getEnv("CI_PROJECT_DIR")
+	}
+	// Gitea (Forgejo) Actions sets the GitHub Actions compatible variables
+	// https://docs.gitea.com/usage/actions/comparison
+	if opt.SCM == "" && (getEnv("GITEA_ACTIONS") == "true" || getEnv("FORGEJO_ACTIONS") == "true") {
+		opt.SCM = scm.PROVIDER_GITEA
+	}
+	if opt.GiteaToken == "" {
+		opt.GiteaToken = getEnv("GITEA_TOKEN")
+	}
+	if opt.SCM == scm.PROVIDER_GITEA {
+		if opt.GiteaToken == "" {
+			opt.GiteaToken = opt.GithubToken
+		}
+		if opt.GiteaAPIURL == "" {
+			opt.GiteaAPIURL = opt.GithubAPIURL
+		}
+		if opt.GiteaRepository == "" {
+			opt.GiteaRepository = opt.GithubRepository
+		}
+		if opt.GiteaPullRequest == 0 {
+			opt.GiteaPullRequest = pullRequestNumber(opt.GithubRef)
+		}
+	}
+	// Jenkins multibranch pipelines (Bitbucket Branch Source) set the pull request URL
+	if bitbucketPullRequestURL == "" {
+		if changeURL := getEnv("CHANGE_URL"); strings.Contains(changeURL, "/pull-requests/") {
+			bitbucketPullRequestURL = changeURL
+		}
+	}
+	if bitbucketPullRequestURL != "" {
+		if opt.SCM == "" {
+			opt.SCM = scm.PROVIDER_BITBUCKET
+		}
+		if ref, err := bitbucket.ParsePullRequestURL(bitbucketPullRequestURL); err == nil {
+			if opt.BitbucketAPIURL == "" {
+				opt.BitbucketAPIURL = ref.APIURL
+			}
+			if opt.BitbucketProjectKey == "" {
+				opt.BitbucketProjectKey = ref.ProjectKey
+			}
+			if opt.BitbucketRepository == "" {
+				opt.BitbucketRepository = ref.RepositorySlug
+			}
+			if opt.BitbucketPullRequest == 0 {
+				opt.BitbucketPullRequest = ref.ID
+			}
+		}
+	}
+	if opt.BitbucketToken == "" {
+		opt.BitbucketToken = getEnv("BITBUCKET_TOKEN")
+	}
+	if opt.GithubWorkspace == "" && opt.SCM == scm.PROVIDER_BITBUCKET {
+		opt.GithubWorkspace = getEnv("WORKSPACE")
 	}
 	if opt.RiskenConsoleURL == "" {
 		opt.RiskenConsoleURL = getEnv("RISKEN_CONSOLE_URL")
@@ -66,6 +227,55 @@ func initoptig() {
 	if opt.RiskenApiToken == "" {
 		opt.RiskenApiToken = getEnv("RISKEN_API_TOKEN")
 	}
+	if opt.RiskenProjectID == 0 {
+		if projectID, err := strconv.ParseUint(getEnv("RISKEN_PROJECT_ID"), 10, 32); err == nil {
+			opt.RiskenProjectID = uint32(projectID)
+		}
+	}
+	if opt.RiskenScorePolicy == "" {
+		opt.RiskenScorePolicy = getEnv("RISKEN_SCORE_POLICY")
+	}
+	if opt.RiskenSpoolDir == "" {
+		opt.RiskenSpoolDir = getEnv("RISKEN_SPOOL_DIR")
+	}
+	if opt.CacheDir == "" {
+		opt.CacheDir = getEnv("RISKEN_CACHE_DIR")
+	}
+	if opt.AdvisoryDBPath == "" {
+		opt.AdvisoryDBPath = getEnv("ADVISORY_DB_PATH")
+	}
+}
+
+func hasRequiredParameters() bool {
+	switch opt.SCM {
+	case scm.PROVIDER_GITLAB:
+		return opt.GitlabToken != "" && opt.GitlabProjectID != "" && opt.GithubWorkspace != ""
+	case scm.PROVIDER_GITEA:
+		return opt.GiteaToken != "" && opt.GiteaAPIURL != "" && opt.GiteaRepository != "" && opt.GithubWorkspace != ""
+	case scm.PROVIDER_BITBUCKET:
+		return opt.BitbucketToken != "" && opt.BitbucketAPIURL != "" && opt.BitbucketProjectKey != "" && opt.BitbucketRepository != "" && opt.GithubWorkspace != ""
+	default:
+		return hasGithubCredential() && opt.GithubEventPath != "" && opt.GithubWorkspace != ""
+	}
+}
+
+var pullRequestRefRegexp = regexp.MustCompile(`^refs/pull/(\d+)/`)
+
+// pullRequestNumber returns the pull request number of the ref (refs/pull/:number/head), or 0 if not the pull request.
+func pullRequestNumber(ref string) int {
+	m := pullRequestRefRegexp.FindStringSubmatch(ref)
+	if m == nil {
+		return 0
+	}
+	number, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+func hasGithubCredential() bool {
+	return opt.GithubToken != "" || (opt.GithubAppID != 0 && opt.GithubAppPrivateKey != "")
 }
 
 func getEnv(key string) string {
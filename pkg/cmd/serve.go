@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ca-risken/security-review/pkg/server"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the webhook server to review pull requests as a GitHub App service",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if serveCfg.WebhookSecret == "" {
+			serveCfg.WebhookSecret = getEnv("GITHUB_WEBHOOK_SECRET")
+		}
+		if !hasGithubCredential() || serveCfg.WebhookSecret == "" {
+			log.Fatal("Missing required parameters")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		serveCfg.ReviewOption = &opt
+		serveCfg.Logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+		s, err := server.New(&serveCfg)
+		if err != nil {
+			return err
+		}
+		return s.Run(ctx)
+	},
+}
+
+var serveCfg server.Config
+
+func init() {
+	serveCmd.Flags().StringVar(&serveCfg.Addr, "addr", server.DEFAULT_ADDR, "Listen address")
+	serveCmd.Flags().StringVar(&serveCfg.WebhookSecret, "webhook-secret", "", "Webhook secret to verify the signature. Also GITHUB_WEBHOOK_SECRET env")
+	serveCmd.Flags().IntVar(&serveCfg.Concurrency, "concurrency", server.DEFAULT_CONCURRENCY, "Number of the reviews running at the same time")
+	serveCmd.Flags().IntVar(&serveCfg.QueueSize, "queue-size", server.DEFAULT_QUEUE_SIZE, "Number of the queued reviews. The webhooks are rejected with 503 if the queue is full")
+	serveCmd.Flags().StringVar(&serveCfg.WorkDir, "work-dir", "", "Parent directory of the scratch workspaces (default: the temporary directory)")
+	serveCmd.Flags().DurationVar(&serveCfg.JobTimeout, "job-timeout", server.DEFAULT_JOB_TIMEOUT, "Timeout of a review including the fetch")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -14,6 +14,60 @@ type GitHubClient struct {
 	mock.Mock
 }
 
+// CompareCommits provides a mock function with given fields: ctx, owner, repoName, base, head
+func (_m *GitHubClient) CompareCommits(ctx context.Context, owner string, repoName string, base string, head string) ([]*github.CommitFile, error) {
+	ret := _m.Called(ctx, owner, repoName, base, head)
+
+	var r0 []*github.CommitFile
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string) ([]*github.CommitFile, error)); ok {
+		return rf(ctx, owner, repoName, base, head)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string) []*github.CommitFile); ok {
+		r0 = rf(ctx, owner, repoName, base, head)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*github.CommitFile)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, string) error); ok {
+		r1 = rf(ctx, owner, repoName, base, head)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateCheckRun provides a mock function with given fields: ctx, owner, repoName, opts
+func (_m *GitHubClient) CreateCheckRun(ctx context.Context, owner string, repoName string, opts *github.CreateCheckRunOptions) error {
+	ret := _m.Called(ctx, owner, repoName, opts)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *github.CreateCheckRunOptions) error); ok {
+		r0 = rf(ctx, owner, repoName, opts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateCommitComment provides a mock function with given fields: ctx, owner, repoName, sha, comment
+func (_m *GitHubClient) CreateCommitComment(ctx context.Context, owner string, repoName string, sha string, comment *github.RepositoryComment) error {
+	ret := _m.Called(ctx, owner, repoName, sha, comment)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, *github.RepositoryComment) error); ok {
+		r0 = rf(ctx, owner, repoName, sha, comment)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CreateIssueComment provides a mock function with given fields: ctx, owner, repoName, prNumber, comment
 func (_m *GitHubClient) CreateIssueComment(ctx context.Context, owner string, repoName string, prNumber int, comment *github.IssueComment) error {
 	ret := _m.Called(ctx, owner, repoName, prNumber, comment)
@@ -94,6 +148,32 @@ func (_m *GitHubClient) GetAllPRComments(ctx context.Context, owner string, repo
 	return r0, r1
 }
 
+// GetRepository provides a mock function with given fields: ctx, owner, repoName
+func (_m *GitHubClient) GetRepository(ctx context.Context, owner string, repoName string) (*github.Repository, error) {
+	ret := _m.Called(ctx, owner, repoName)
+
+	var r0 *github.Repository
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*github.Repository, error)); ok {
+		return rf(ctx, owner, repoName)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *github.Repository); ok {
+		r0 = rf(ctx, owner, repoName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*github.Repository)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, owner, repoName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListFiles provides a mock function with given fields: ctx, owner, repo, number, opts
 func (_m *GitHubClient) ListFiles(ctx context.Context, owner string, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
 	ret := _m.Called(ctx, owner, repo, number, opts)
@@ -16,6 +16,84 @@ type RiskenClient struct {
 	mock.Mock
 }
 
+// GetFinding provides a mock function with given fields: ctx, req
+func (_m *RiskenClient) GetFinding(ctx context.Context, req *finding.GetFindingRequest) (*finding.GetFindingResponse, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *finding.GetFindingResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.GetFindingRequest) (*finding.GetFindingResponse, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.GetFindingRequest) *finding.GetFindingResponse); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*finding.GetFindingResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *finding.GetFindingRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetPendFinding provides a mock function with given fields: ctx, req
+func (_m *RiskenClient) GetPendFinding(ctx context.Context, req *finding.GetPendFindingRequest) (*finding.GetPendFindingResponse, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *finding.GetPendFindingResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.GetPendFindingRequest) (*finding.GetPendFindingResponse, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.GetPendFindingRequest) *finding.GetPendFindingResponse); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*finding.GetPendFindingResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *finding.GetPendFindingRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListFinding provides a mock function with given fields: ctx, req
+func (_m *RiskenClient) ListFinding(ctx context.Context, req *finding.ListFindingRequest) (*finding.ListFindingResponse, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *finding.ListFindingResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.ListFindingRequest) (*finding.ListFindingResponse, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.ListFindingRequest) *finding.ListFindingResponse); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*finding.ListFindingResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *finding.ListFindingRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // PutFinding provides a mock function with given fields: ctx, req
 func (_m *RiskenClient) PutFinding(ctx context.Context, req *finding.PutFindingRequest) (*finding.PutFindingResponse, error) {
 	ret := _m.Called(ctx, req)
@@ -94,6 +172,32 @@ func (_m *RiskenClient) Signin(ctx context.Context) (*risken.SigninResponse, err
 	return r0, r1
 }
 
+// TagFinding provides a mock function with given fields: ctx, req
+func (_m *RiskenClient) TagFinding(ctx context.Context, req *finding.TagFindingRequest) (*finding.TagFindingResponse, error) {
+	ret := _m.Called(ctx, req)
+
+	var r0 *finding.TagFindingResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.TagFindingRequest) (*finding.TagFindingResponse, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *finding.TagFindingRequest) *finding.TagFindingResponse); ok {
+		r0 = rf(ctx, req)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*finding.TagFindingResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *finding.TagFindingRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewRiskenClient creates a new instance of RiskenClient. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRiskenClient(t interface {
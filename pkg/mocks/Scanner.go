@@ -5,10 +5,11 @@ package mocks
 import (
 	context "context"
 
-	github "github.com/google/go-github/v44/github"
 	mock "github.com/stretchr/testify/mock"
 
 	scanner "github.com/ca-risken/security-review/pkg/scanner"
+
+	scm "github.com/ca-risken/security-review/pkg/scm"
 )
 
 // Scanner is an autogenerated mock type for the Scanner type
@@ -16,25 +17,25 @@ type Scanner struct {
 	mock.Mock
 }
 
-// Scan provides a mock function with given fields: ctx, repo, sourceCodePath, changeFiles
-func (_m *Scanner) Scan(ctx context.Context, repo *github.Repository, sourceCodePath string, changeFiles []*github.CommitFile) ([]*scanner.ScanResult, error) {
-	ret := _m.Called(ctx, repo, sourceCodePath, changeFiles)
+// Scan provides a mock function with given fields: ctx, repo, headSHA, sourceCodePath, changeFiles
+func (_m *Scanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*scanner.ScanResult, error) {
+	ret := _m.Called(ctx, repo, headSHA, sourceCodePath, changeFiles)
 
 	var r0 []*scanner.ScanResult
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, *github.Repository, string, []*github.CommitFile) ([]*scanner.ScanResult, error)); ok {
-		return rf(ctx, repo, sourceCodePath, changeFiles)
+	if rf, ok := ret.Get(0).(func(context.Context, *scm.Repository, string, string, []*scm.ChangeFile) ([]*scanner.ScanResult, error)); ok {
+		return rf(ctx, repo, headSHA, sourceCodePath, changeFiles)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, *github.Repository, string, []*github.CommitFile) []*scanner.ScanResult); ok {
-		r0 = rf(ctx, repo, sourceCodePath, changeFiles)
+	if rf, ok := ret.Get(0).(func(context.Context, *scm.Repository, string, string, []*scm.ChangeFile) []*scanner.ScanResult); ok {
+		r0 = rf(ctx, repo, headSHA, sourceCodePath, changeFiles)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*scanner.ScanResult)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, *github.Repository, string, []*github.CommitFile) error); ok {
-		r1 = rf(ctx, repo, sourceCodePath, changeFiles)
+	if rf, ok := ret.Get(1).(func(context.Context, *scm.Repository, string, string, []*scm.ChangeFile) error); ok {
+		r1 = rf(ctx, repo, headSHA, sourceCodePath, changeFiles)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -2,7 +2,13 @@ package review
 
 import (
 	"context"
-	"time"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
 
 	"github.com/google/go-github/v44/github"
 	"golang.org/x/oauth2"
@@ -15,20 +21,104 @@ type GitHubClient interface {
 	GetAllPRComments(ctx context.Context, owner, repo string, prNumber int) ([]*github.PullRequestComment, error)
 	CreateIssueComment(ctx context.Context, owner, repoName string, prNumber int, comment *github.IssueComment) error
 	CreatePRComment(ctx context.Context, owner, repoName string, prNumber int, comment *github.PullRequestComment) error
+	GetRepository(ctx context.Context, owner, repoName string) (*github.Repository, error)
+	CompareCommits(ctx context.Context, owner, repoName, base, head string) ([]*github.CommitFile, error)
+	CreateCheckRun(ctx context.Context, owner, repoName string, opts *github.CreateCheckRunOptions) error
+	CreateCommitComment(ctx context.Context, owner, repoName, sha string, comment *github.RepositoryComment) error
 }
 
 type githubClient struct {
 	*github.Client
 }
 
-func NewGitHubClient(ctx context.Context, token string) GitHubClient {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	return &githubClient{
-		github.NewClient(tc),
+const DEFAULT_GITHUB_API_URL = "https://api.github.com"
+
+// GitHubClientConfig is the configuration of the GitHub API client.
+// Either Token or the GitHub App (AppID and AppPrivateKey) is required.
+type GitHubClientConfig struct {
+	Token     string
+	APIURL    string // e.g. https://github.example.com/api/v3 (GitHub Enterprise Server)
+	UploadURL string // derived from APIURL if empty
+	CABundle  string // PEM file of the additional CA certificates
+
+	AppID             int64
+	AppPrivateKey     string // PEM or the file path
+	AppInstallationID int64  // found by Repository if empty
+	Repository        string // owner/repo
+
+	Logger *slog.Logger
+}
+
+func NewGitHubClient(ctx context.Context, cfg *GitHubClientConfig) (GitHubClient, error) {
+	baseClient, err := newBaseHTTPClient(cfg.CABundle, cfg.Logger)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, baseClient)
+	ts, err := newTokenSource(ctx, cfg, baseClient)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newGitHubAPIClient(cfg, oauth2.NewClient(ctx, ts))
+	if err != nil {
+		return nil, err
+	}
+	return &githubClient{client}, nil
+}
+
+// NewGitHubTokenSource returns the token source of the GitHub API client, e.g. to fetch the repository with git.
+func NewGitHubTokenSource(ctx context.Context, cfg *GitHubClientConfig) (oauth2.TokenSource, error) {
+	baseClient, err := newBaseHTTPClient(cfg.CABundle, cfg.Logger)
+	if err != nil {
+		return nil, err
+	}
+	return newTokenSource(context.WithValue(ctx, oauth2.HTTPClient, baseClient), cfg, baseClient)
+}
+
+func newTokenSource(ctx context.Context, cfg *GitHubClientConfig, baseClient *http.Client) (oauth2.TokenSource, error) {
+	if cfg.AppID != 0 {
+		return newAppTokenSource(ctx, cfg, baseClient)
+	}
+	return oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: cfg.Token},
+	), nil
+}
+
+func newGitHubAPIClient(cfg *GitHubClientConfig, httpClient *http.Client) (*github.Client, error) {
+	apiURL := strings.TrimSuffix(cfg.APIURL, "/")
+	if apiURL == "" || apiURL == DEFAULT_GITHUB_API_URL {
+		return github.NewClient(httpClient), nil
+	}
+	uploadURL := cfg.UploadURL
+	if uploadURL == "" {
+		uploadURL = strings.TrimSuffix(apiURL, "/api/v3") + "/api/uploads"
+	}
+	client, err := github.NewEnterpriseClient(apiURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create github enterprise client: api_url=%s, err=%w", apiURL, err)
+	}
+	return client, nil
+}
+
+// newBaseHTTPClient returns the HTTP client retrying the transient errors, and trusting the system and the additional CA certificates.
+func newBaseHTTPClient(caBundle string, logger *slog.Logger) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caBundle == "" {
+		return &http.Client{Transport: newRetryTransport(transport, logger)}, nil
+	}
+	pem, err := os.ReadFile(caBundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: path=%s, err=%w", caBundle, err)
 	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle: path=%s", caBundle)
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: newRetryTransport(transport, logger)}, nil
 }
 
 func (c *githubClient) ListFiles(ctx context.Context, owner string, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
@@ -70,7 +160,6 @@ func (c *githubClient) GetAllPRComments(ctx context.Context, owner, repo string,
 			break
 		}
 		opts.Page = resp.NextPage
-		time.Sleep(500 * time.Millisecond)
 	}
 	return allComments, nil
 }
@@ -84,3 +173,61 @@ func (c *githubClient) CreatePRComment(ctx context.Context, owner, repoName stri
 	_, _, err := c.PullRequests.CreateComment(ctx, owner, repoName, prNumber, comment)
 	return err
 }
+
+func (c *githubClient) GetRepository(ctx context.Context, owner, repoName string) (*github.Repository, error) {
+	repo, _, err := c.Repositories.Get(ctx, owner, repoName)
+	return repo, err
+}
+
+// COMPARE_MAX_FILES is the max number of the files returned by the compare API, which truncates the files over it.
+const COMPARE_MAX_FILES = 300
+
+// CompareCommits returns the changed files between the commits (up to COMPARE_MAX_FILES by the GitHub API limit).
+func (c *githubClient) CompareCommits(ctx context.Context, owner, repoName, base, head string) ([]*github.CommitFile, error) {
+	comparison, _, err := c.Repositories.CompareCommits(ctx, owner, repoName, base, head, nil)
+	if err != nil {
+		return nil, err
+	}
+	return comparison.Files, nil
+}
+
+// checkRunMaxAnnotations is the limit of the annotations per request.
+const checkRunMaxAnnotations = 50
+
+// CreateCheckRun creates the check run, and adds the annotations over the limit by updating it.
+func (c *githubClient) CreateCheckRun(ctx context.Context, owner, repoName string, opts *github.CreateCheckRunOptions) error {
+	var annotations []*github.CheckRunAnnotation
+	if opts.Output != nil && len(opts.Output.Annotations) > checkRunMaxAnnotations {
+		annotations = opts.Output.Annotations[checkRunMaxAnnotations:]
+		output := *opts.Output
+		output.Annotations = output.Annotations[:checkRunMaxAnnotations]
+		createOpts := *opts
+		createOpts.Output = &output
+		opts = &createOpts
+	}
+	checkRun, _, err := c.Checks.CreateCheckRun(ctx, owner, repoName, *opts)
+	if err != nil {
+		return err
+	}
+	for len(annotations) > 0 {
+		n := min(len(annotations), checkRunMaxAnnotations)
+		_, _, err := c.Checks.UpdateCheckRun(ctx, owner, repoName, checkRun.GetID(), github.UpdateCheckRunOptions{
+			Name: opts.Name,
+			Output: &github.CheckRunOutput{
+				Title:       opts.Output.Title,
+				Summary:     opts.Output.Summary,
+				Annotations: annotations[:n],
+			},
+		})
+		if err != nil {
+			return err
+		}
+		annotations = annotations[n:]
+	}
+	return nil
+}
+
+func (c *githubClient) CreateCommitComment(ctx context.Context, owner, repoName, sha string, comment *github.RepositoryComment) error {
+	_, _, err := c.Repositories.CreateComment(ctx, owner, repoName, sha, comment)
+	return err
+}
@@ -0,0 +1,155 @@
+package review
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v44/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	appJWTClockSkew  = 60 * time.Second
+	appJWTExpiration = 9 * time.Minute // must be less than 10 minutes
+)
+
+// appJWT generates the JWT to authenticate as a GitHub App.
+// ref: https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+type appJWT struct {
+	appID int64
+	key   *rsa.PrivateKey
+	now   func() time.Time
+}
+
+func (j *appJWT) sign() (string, error) {
+	now := j.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-appJWTClockSkew).Unix(),
+		"exp": now.Add(appJWTExpiration).Unix(),
+		"iss": fmt.Sprint(j.appID),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, j.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the github app jwt: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// appJWTTransport authenticates the requests as the GitHub App itself.
+type appJWTTransport struct {
+	jwt  *appJWT
+	base http.RoundTripper
+}
+
+func (t *appJWTTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.jwt.sign()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+// installationTokenSource issues the installation access token, which expires in an hour.
+type installationTokenSource struct {
+	ctx            context.Context
+	client         *github.Client
+	installationID int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.client.Apps.CreateInstallationToken(s.ctx, s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token: installation_id=%d, err=%w", s.installationID, err)
+	}
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "token",
+		Expiry:      token.GetExpiresAt(),
+	}, nil
+}
+
+// newAppTokenSource returns the token source of the GitHub App installation, refreshed before the token expires.
+func newAppTokenSource(ctx context.Context, cfg *GitHubClientConfig, baseClient *http.Client) (oauth2.TokenSource, error) {
+	key, err := parseAppPrivateKey(cfg.AppPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	base := baseClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	appClient, err := newGitHubAPIClient(cfg, &http.Client{
+		Transport: &appJWTTransport{jwt: &appJWT{appID: cfg.AppID, key: key, now: time.Now}, base: base},
+	})
+	if err != nil {
+		return nil, err
+	}
+	installationID := cfg.AppInstallationID
+	if installationID == 0 {
+		owner, repo, found := strings.Cut(cfg.Repository, "/")
+		if !found || owner == "" || repo == "" {
+			return nil, fmt.Errorf("github app installation id or repository (owner/repo) is required: repository=%s", cfg.Repository)
+		}
+		installation, _, err := appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find github app installation: repository=%s, err=%w", cfg.Repository, err)
+		}
+		installationID = installation.GetID()
+	}
+	return oauth2.ReuseTokenSource(nil, &installationTokenSource{
+		ctx:            ctx,
+		client:         appClient,
+		installationID: installationID,
+	}), nil
+}
+
+// parseAppPrivateKey parses the PEM encoded private key (PKCS#1 or PKCS#8), or reads it from the file.
+func parseAppPrivateKey(keyOrPath string) (*rsa.PrivateKey, error) {
+	data := []byte(keyOrPath)
+	if !strings.Contains(keyOrPath, "-----BEGIN") {
+		b, err := os.ReadFile(keyOrPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read github app private key: %w", err)
+		}
+		data = b
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode github app private key: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse github app private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app private key must be RSA: type=%T", parsed)
+	}
+	return key, nil
+}
@@ -0,0 +1,131 @@
+package review
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-github/v44/github"
+)
+
+// githubProvider is the SCM provider of the GitHub pull requests.
+type githubProvider struct {
+	client GitHubClient
+}
+
+var _ scm.Provider = (*githubProvider)(nil)
+
+func (p *githubProvider) ListChangeFiles(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.ChangeFile, error) {
+	owner, repoName := splitFullName(cr.Repository.FullName)
+	opts := &github.ListOptions{
+		PerPage: 100,
+	}
+	changeFiles := []*scm.ChangeFile{}
+	for {
+		files, resp, err := p.client.ListFiles(ctx, owner, repoName, cr.Number, opts)
+		if err != nil {
+			return nil, err
+		}
+		// https://docs.github.com/ja/rest/pulls/pulls?apiVersion=2022-11-28#list-pull-requests-files
+		changeFiles = append(changeFiles, newChangeFiles(files)...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return changeFiles, nil
+}
+
+func (p *githubProvider) ListComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	owner, repoName := splitFullName(cr.Repository.FullName)
+	issueComments, err := p.client.GetAllIssueComments(ctx, owner, repoName, cr.Number)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*scm.Comment
+	for _, c := range issueComments {
+		comments = append(comments, &scm.Comment{Body: c.GetBody(), URL: c.GetHTMLURL()})
+	}
+	return comments, nil
+}
+
+// ListLineComments returns the review comments on the latest diff. The line of the outdated comment is nil.
+func (p *githubProvider) ListLineComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	owner, repoName := splitFullName(cr.Repository.FullName)
+	prComments, err := p.client.GetAllPRComments(ctx, owner, repoName, cr.Number)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*scm.Comment
+	for _, c := range prComments {
+		if c.Path == nil || c.Line == nil {
+			continue
+		}
+		comments = append(comments, &scm.Comment{Path: c.GetPath(), Line: c.GetLine(), Body: c.GetBody(), URL: c.GetHTMLURL()})
+	}
+	return comments, nil
+}
+
+func (p *githubProvider) CreateComment(ctx context.Context, cr *scm.ChangeRequest, body string) error {
+	owner, repoName := splitFullName(cr.Repository.FullName)
+	return p.client.CreateIssueComment(ctx, owner, repoName, cr.Number, &github.IssueComment{
+		Body: github.String(body),
+	})
+}
+
+func (p *githubProvider) CreateLineComment(ctx context.Context, cr *scm.ChangeRequest, comment *scm.Comment) error {
+	owner, repoName := splitFullName(cr.Repository.FullName)
+	return p.client.CreatePRComment(ctx, owner, repoName, cr.Number, &github.PullRequestComment{
+		Body:     github.String(comment.Body),
+		CommitID: github.String(cr.HeadSHA),
+		Path:     github.String(comment.Path),
+		Line:     github.Int(comment.Line),
+	})
+}
+
+func splitFullName(fullName string) (owner, repoName string) {
+	owner, repoName, _ = strings.Cut(fullName, "/")
+	return owner, repoName
+}
+
+// newRepository converts the GitHub repository into the SCM model.
+func newRepository(repo *github.Repository) *scm.Repository {
+	visibility := repo.GetVisibility()
+	if visibility == "" {
+		// Not in the payloads of the older GitHub Enterprise Server
+		visibility = "public"
+		if repo.GetPrivate() {
+			visibility = "private"
+		}
+	}
+	return &scm.Repository{
+		Provider:      scm.PROVIDER_GITHUB,
+		ID:            repo.GetID(),
+		Name:          repo.GetName(),
+		FullName:      repo.GetFullName(),
+		Description:   repo.GetDescription(),
+		URL:           repo.GetHTMLURL(),
+		CloneURL:      repo.GetCloneURL(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		Visibility:    visibility,
+		Private:       repo.GetPrivate(),
+		Archived:      repo.GetArchived(),
+		Topics:        repo.Topics,
+	}
+}
+
+// newChangeFiles converts the GitHub commit files into the SCM model, except the removed files which can not be scanned.
+func newChangeFiles(files []*github.CommitFile) []*scm.ChangeFile {
+	changeFiles := []*scm.ChangeFile{}
+	for _, f := range files {
+		if f.GetStatus() == scm.FILE_REMOVED {
+			continue
+		}
+		changeFiles = append(changeFiles, &scm.ChangeFile{
+			Filename: f.GetFilename(),
+			Status:   f.GetStatus(),
+			Patch:    f.GetPatch(),
+		})
+	}
+	return changeFiles
+}
@@ -0,0 +1,209 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	githubMaxRetries             = 5
+	githubMinBackoff             = 1 * time.Second
+	githubMaxBackoff             = 30 * time.Second
+	githubMaxRateLimitWait       = 10 * time.Minute
+	githubSecondaryRateLimitWait = 1 * time.Minute // ref: https://docs.github.com/en/rest/using-the-rest-api/best-practices-for-using-the-rest-api#handle-rate-limit-errors-appropriately
+)
+
+// retryTransport retries the transient errors (network errors, 5xx) with the jittered exponential backoff,
+// and waits for the primary and secondary rate limits of the GitHub API.
+// The non-idempotent requests (POST, PATCH) are retried only on the rate limits, which reject the request without processing it,
+// because the request failed by the network error or 5xx may have been processed (e.g. the duplicated comment).
+type retryTransport struct {
+	base          http.RoundTripper
+	logger        *slog.Logger
+	maxRetries    int
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	maxWait       time.Duration // give up if the rate limit resets later than this
+	secondaryWait time.Duration
+	now           func() time.Time
+	sleep         func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryTransport(base http.RoundTripper, logger *slog.Logger) *retryTransport {
+	return &retryTransport{
+		base:          base,
+		logger:        logger,
+		maxRetries:    githubMaxRetries,
+		minBackoff:    githubMinBackoff,
+		maxBackoff:    githubMaxBackoff,
+		maxWait:       githubMaxRateLimitWait,
+		secondaryWait: githubSecondaryRateLimitWait,
+		now:           time.Now,
+		sleep:         sleepContext,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	idempotent := isIdempotentMethod(req.Method)
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+		resp, err := t.base.RoundTrip(r)
+
+		var wait time.Duration
+		var retryable bool
+		var reason string
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			wait, retryable, reason = t.backoff(attempt), idempotent && isTransientError(err), err.Error()
+		} else {
+			wait, retryable = t.retryWait(resp, attempt)
+			retryable = retryable && (idempotent || isRateLimitStatus(resp.StatusCode))
+			reason = resp.Status
+		}
+		if !retryable || !rewindable || attempt >= t.maxRetries {
+			return resp, err
+		}
+		if resp != nil {
+			drainBody(resp)
+		}
+		if t.logger != nil {
+			t.logger.WarnContext(ctx, "Retry GitHub API request",
+				slog.String("method", req.Method), slog.String("url", req.URL.String()), slog.String("reason", reason),
+				slog.Int("attempt", attempt+1), slog.Duration("wait", wait))
+		}
+		if err := t.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryWait returns the duration to wait before the next attempt, and whether the response is retryable.
+func (t *retryTransport) retryWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return t.backoff(attempt), true
+	case http.StatusTooManyRequests, http.StatusForbidden:
+	default:
+		return 0, false
+	}
+
+	// rate limits
+	var wait time.Duration
+	switch {
+	case resp.Header.Get("Retry-After") != "":
+		seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+	case resp.Header.Get("X-RateLimit-Remaining") == "0" && resp.Header.Get("X-RateLimit-Reset") != "":
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		wait = max(time.Unix(reset, 0).Sub(t.now()), 0) + time.Second
+	case isSecondaryRateLimit(resp):
+		wait = t.secondaryWait << attempt
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait = t.backoff(attempt)
+	default:
+		return 0, false // permission error
+	}
+	if wait > t.maxWait {
+		return 0, false
+	}
+	return wait, true
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// isRateLimitStatus returns true for the status codes of the rate limits, which are retryable only if retryWait detects the rate limit.
+func isRateLimitStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusForbidden
+}
+
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	return jitteredBackoff(attempt, t.minBackoff, t.maxBackoff)
+}
+
+// jitteredBackoff returns the exponential backoff with the jitter (50-100%).
+func jitteredBackoff(attempt int, minBackoff, maxBackoff time.Duration) time.Duration {
+	d := maxBackoff
+	if attempt < 30 {
+		d = min(minBackoff<<attempt, maxBackoff)
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// isSecondaryRateLimit checks the error message, keeping the body readable by the caller.
+func isSecondaryRateLimit(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(body)), "secondary rate limit")
+}
+
+// isTransientError excludes the errors which will not be resolved by retrying (e.g. the untrusted certificate).
+func isTransientError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var hostErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	return !errors.As(err, &certErr) && !errors.As(err, &hostErr) && !errors.As(err, &authorityErr)
+}
+
+func drainBody(resp *http.Response) {
+	if resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,255 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type stubResponse struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// newRetryTestTransport returns the transport recording the waits instead of sleeping.
+func newRetryTestTransport(now time.Time, waits *[]time.Duration) *retryTransport {
+	t := newRetryTransport(http.DefaultTransport, nil)
+	t.maxRetries = 3
+	t.minBackoff = 100 * time.Millisecond
+	t.maxBackoff = 400 * time.Millisecond
+	t.now = func() time.Time { return now }
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	return t
+}
+
+func TestRetryTransport(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	testCases := []struct {
+		name       string
+		method     string
+		responses  []stubResponse
+		wantStatus int
+		wantCalls  int
+		wantWaits  []time.Duration // 0 means the jittered backoff
+	}{
+		{
+			name:       "OK",
+			method:     http.MethodGet,
+			responses:  []stubResponse{{status: http.StatusOK}},
+			wantStatus: http.StatusOK,
+			wantCalls:  1,
+		},
+		{
+			name:       "Retry 5xx",
+			method:     http.MethodPut,
+			responses:  []stubResponse{{status: http.StatusBadGateway}, {status: http.StatusServiceUnavailable}, {status: http.StatusCreated}},
+			wantStatus: http.StatusCreated,
+			wantCalls:  3,
+			wantWaits:  []time.Duration{0, 0},
+		},
+		{
+			name:       "Not retry 5xx of POST",
+			method:     http.MethodPost,
+			responses:  []stubResponse{{status: http.StatusBadGateway}, {status: http.StatusCreated}},
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  1,
+		},
+		{
+			name:   "Retry 429 of POST",
+			method: http.MethodPost,
+			responses: []stubResponse{
+				{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "2"}},
+				{status: http.StatusCreated},
+			},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+			wantWaits:  []time.Duration{2 * time.Second},
+		},
+		{
+			name:       "Give up after max retries",
+			method:     http.MethodGet,
+			responses:  []stubResponse{{status: http.StatusInternalServerError}},
+			wantStatus: http.StatusInternalServerError,
+			wantCalls:  4,
+			wantWaits:  []time.Duration{0, 0, 0},
+		},
+		{
+			name:       "Not retry client errors",
+			method:     http.MethodPost,
+			responses:  []stubResponse{{status: http.StatusUnprocessableEntity}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCalls:  1,
+		},
+		{
+			name:       "Not retry permission errors",
+			method:     http.MethodGet,
+			responses:  []stubResponse{{status: http.StatusForbidden, body: `{"message":"Resource not accessible by integration"}`}},
+			wantStatus: http.StatusForbidden,
+			wantCalls:  1,
+		},
+		{
+			name:   "Retry-After",
+			method: http.MethodPost,
+			responses: []stubResponse{
+				{status: http.StatusForbidden, header: map[string]string{"Retry-After": "3"}, body: `{"message":"You have exceeded a secondary rate limit."}`},
+				{status: http.StatusCreated},
+			},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+			wantWaits:  []time.Duration{3 * time.Second},
+		},
+		{
+			name:   "Primary rate limit reset",
+			method: http.MethodGet,
+			responses: []stubResponse{
+				{status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(now.Add(30 * time.Second).Unix())}},
+				{status: http.StatusOK},
+			},
+			wantStatus: http.StatusOK,
+			wantCalls:  2,
+			wantWaits:  []time.Duration{31 * time.Second},
+		},
+		{
+			name:   "Primary rate limit reset too late",
+			method: http.MethodGet,
+			responses: []stubResponse{
+				{status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": fmt.Sprint(now.Add(time.Hour).Unix())}},
+			},
+			wantStatus: http.StatusForbidden,
+			wantCalls:  1,
+		},
+		{
+			name:   "Secondary rate limit without Retry-After",
+			method: http.MethodPost,
+			responses: []stubResponse{
+				{status: http.StatusForbidden, body: `{"message":"You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`},
+				{status: http.StatusCreated},
+			},
+			wantStatus: http.StatusCreated,
+			wantCalls:  2,
+			wantWaits:  []time.Duration{githubSecondaryRateLimitWait},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost || r.Method == http.MethodPut {
+					body, _ := io.ReadAll(r.Body)
+					if string(body) != `{"body":"comment"}` {
+						t.Errorf("unexpected request body: %s", body)
+					}
+				}
+				i := int(calls.Add(1)) - 1
+				res := tc.responses[min(i, len(tc.responses)-1)]
+				for k, v := range res.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(res.status)
+				_, _ = w.Write([]byte(res.body))
+			}))
+			defer server.Close()
+
+			var waits []time.Duration
+			client := &http.Client{Transport: newRetryTestTransport(now, &waits)}
+			req, err := http.NewRequest(tc.method, server.URL, strings.NewReader(`{"body":"comment"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+			if got := int(calls.Load()); got != tc.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tc.wantCalls)
+			}
+			if last := tc.responses[min(tc.wantCalls, len(tc.responses))-1]; string(body) != last.body {
+				t.Errorf("body = %s, want %s", body, last.body)
+			}
+			for i, w := range tc.wantWaits {
+				if w == 0 && i < len(waits) && waits[i] > 0 && waits[i] <= 400*time.Millisecond {
+					tc.wantWaits[i] = waits[i] // jittered backoff
+				}
+			}
+			if diff := cmp.Diff(tc.wantWaits, waits); diff != "" {
+				t.Errorf("waits mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRetryTransportNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close() // connection refused
+
+	var waits []time.Duration
+	client := &http.Client{Transport: newRetryTestTransport(time.Now(), &waits)}
+	if _, err := client.Get(url); err == nil {
+		t.Fatal("Get() error = nil, want error")
+	}
+	if len(waits) != 3 {
+		t.Errorf("retries = %d, want 3", len(waits))
+	}
+
+	waits = nil
+	if _, err := client.Post(url, "application/json", strings.NewReader(`{"body":"comment"}`)); err == nil {
+		t.Fatal("Post() error = nil, want error")
+	}
+	if len(waits) != 0 {
+		t.Errorf("retries of POST = %d, want 0", len(waits))
+	}
+}
+
+func TestRetryTransportContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := newRetryTransport(http.DefaultTransport, nil)
+	transport.minBackoff = time.Hour
+	transport.maxBackoff = time.Hour
+	client := &http.Client{Transport: transport}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Do() error = nil, want context canceled")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Do() was not canceled: elapsed=%s", elapsed)
+	}
+}
+
+func TestRetryTransportBackoff(t *testing.T) {
+	transport := newRetryTransport(http.DefaultTransport, nil)
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second} {
+		got := transport.backoff(attempt)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %s, want %s-%s", attempt, got, want/2, want)
+		}
+	}
+}
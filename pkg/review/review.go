@@ -2,10 +2,12 @@ package review
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
 	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
 )
 
 type ReviewService interface {
@@ -13,24 +15,134 @@ type ReviewService interface {
 }
 
 type ReviewOption struct {
-	GithubToken       string
-	GithubEventPath   string
-	GithubWorkspace   string
-	RiskenConsoleURL  string
-	RiskenApiEndpoint string
-	RiskenApiToken    string
-	ErrorFlag         bool
-	NoPRComment       bool
+	GithubToken             string
+	GithubAPIURL            string
+	GithubUploadURL         string
+	GithubCABundle          string
+	GithubAppID             int64
+	GithubAppPrivateKey     string
+	GithubAppInstallationID int64
+	GithubRepository        string
+	GithubEventName         string
+	GithubEventPath         string
+	GithubSHA               string
+	GithubRef               string
+	GithubWorkspace         string
+	RiskenConsoleURL        string
+	RiskenApiEndpoint       string
+	RiskenApiToken          string
+	RiskenRequired          bool
+	RiskenProjectID         uint32   // project of the findings, the project of the API token if 0
+	RiskenProjectRoutes     []string // <repository>[:<path prefix>]=<project ID>
+	RiskenTags              []string // user-defined tags attached to the findings
+	RiskenTriage            string   // skip (default), annotate or off
+	RiskenSpoolDir          string   // spool the findings failed to be sent to RISKEN (disabled if empty)
+	RiskenScorePolicy       string   // YAML file of the score policy (optional)
+	ErrorFlag               bool
+	NoPRComment             bool
+	NoIncremental           bool
+	AdvisoryDBPath          string
+	EntropyScan             bool
+	EntropyThreshold        float64
+	VerifySecrets           bool
+	VerifyEndpoints         map[string]string
+	Output                  string
+	Mode                    string
+	CacheDir                string
+	NoCache                 bool
+	DryRun                  bool
+	DryRunOutput            string
+	SCM                     string // github (default), gitlab, gitea or bitbucket
+	GitlabToken             string
+	GitlabAPIURL            string
+	GitlabCABundle          string
+	GitlabProjectID         string
+	GitlabMergeRequestIID   int
+	GiteaToken              string
+	GiteaAPIURL             string
+	GiteaCABundle           string
+	GiteaRepository         string
+	GiteaPullRequest        int
+	BitbucketToken          string
+	BitbucketAPIURL         string
+	BitbucketCABundle       string
+	BitbucketProjectKey     string
+	BitbucketRepository     string
+	BitbucketPullRequest    int
 }
 
 type reviewService struct {
-	opt          *ReviewOption
-	githubClient GitHubClient
-	riskenClient RiskenClient
-	logger       *slog.Logger
+	opt           *ReviewOption
+	githubClient  GitHubClient        // nil on the other SCMs
+	scmClient     changeRequestClient // nil on GitHub
+	scm           scm.Provider
+	riskenClient  RiskenClient
+	projectRoutes []*riskenProjectRoute
+	projects      *riskenProjects      // set on the first access to RISKEN
+	scorePolicy   *scorePolicy         // nil if not set
+	cache         *scanner.ResultCache // nil if disabled
+	dryRun        *dryRunRecorder      // nil if not dry run
+	spool         *riskenSpool         // nil if disabled
+	logger        *slog.Logger
+	summary       reviewSummary
 }
 
-func NewReviewService(ctx context.Context, opt *ReviewOption, logger *slog.Logger) ReviewService {
+// reviewSummary is the result of the run, logged at the end.
+type reviewSummary struct {
+	Findings        int
+	Resolved        int
+	Carried         int
+	Comments        int
+	CommentFailures int // failed even after the retries
+	RiskenUploaded  int
+	RiskenFailures  int // failed even after the retries
+	RiskenSpooled   int // failed and spooled to be flushed later
+	Triaged         int // skipped as triaged in RISKEN
+}
+
+func NewReviewService(ctx context.Context, opt *ReviewOption, logger *slog.Logger) (ReviewService, error) {
+	var (
+		githubClient GitHubClient
+		scmClient    changeRequestClient
+	)
+	switch opt.SCM {
+	case "", scm.PROVIDER_GITHUB:
+		client, err := NewGitHubClient(ctx, &GitHubClientConfig{
+			Token:             opt.GithubToken,
+			APIURL:            opt.GithubAPIURL,
+			UploadURL:         opt.GithubUploadURL,
+			CABundle:          opt.GithubCABundle,
+			AppID:             opt.GithubAppID,
+			AppPrivateKey:     opt.GithubAppPrivateKey,
+			AppInstallationID: opt.GithubAppInstallationID,
+			Repository:        opt.GithubRepository,
+			Logger:            logger,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create github client: %w", err)
+		}
+		githubClient = client
+	default:
+		client, err := newChangeRequestClient(opt, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s client: %w", opt.SCM, err)
+		}
+		scmClient = client
+	}
+	if err := validateRiskenTags(opt.RiskenTags); err != nil {
+		return nil, err
+	}
+	if err := validateTriageMode(opt.RiskenTriage); err != nil {
+		return nil, err
+	}
+	projectRoutes, err := parseRiskenProjectRoutes(opt.RiskenProjectRoutes)
+	if err != nil {
+		return nil, err
+	}
+	scorePolicy, err := loadScorePolicy(opt.RiskenScorePolicy)
+	if err != nil {
+		return nil, err
+	}
 	var riskenClient RiskenClient
 	if opt.RiskenApiEndpoint != "" && opt.RiskenApiToken != "" {
 		riskenClient = NewRiskenClient(opt.RiskenApiToken, opt.RiskenApiEndpoint)
@@ -39,77 +151,201 @@ func NewReviewService(ctx context.Context, opt *ReviewOption, logger *slog.Logge
 			opt.RiskenConsoleURL = opt.RiskenApiEndpoint
 		}
 	}
-	return &reviewService{
-		opt:          opt,
-		githubClient: NewGitHubClient(ctx, opt.GithubToken),
-		riskenClient: riskenClient,
-		logger:       logger,
+	var cache *scanner.ResultCache
+	if opt.CacheDir != "" && !opt.NoCache {
+		cache = scanner.NewResultCache(opt.CacheDir, logger)
+	}
+	var dryRun *dryRunRecorder
+	if opt.DryRun {
+		// 読み取りはそのまま、書き込みは記録のみ
+		dryRun = &dryRunRecorder{}
+		if githubClient != nil {
+			githubClient = &dryRunGitHubClient{GitHubClient: githubClient, recorder: dryRun}
+		}
+		if riskenClient != nil {
+			riskenClient = newDryRunRiskenClient(riskenClient, dryRun)
+		}
+	}
+	var provider scm.Provider = &githubProvider{client: githubClient}
+	if scmClient != nil {
+		provider = scmClient
+		if dryRun != nil {
+			provider = &dryRunProvider{Provider: scmClient, name: opt.SCM, recorder: dryRun}
+		}
 	}
+	return &reviewService{
+		opt:           opt,
+		githubClient:  githubClient,
+		scmClient:     scmClient,
+		scm:           provider,
+		riskenClient:  riskenClient,
+		projectRoutes: projectRoutes,
+		scorePolicy:   scorePolicy,
+		cache:         cache,
+		dryRun:        dryRun,
+		spool:         newRiskenSpool(opt.RiskenSpoolDir),
+		logger:        logger,
+	}, nil
 }
 
 func (r *reviewService) Run(ctx context.Context) error {
-	// PR情報を取得（なければ終了）
-	pr, err := r.GetGithubPREvent()
+	err := r.review(ctx)
+	if r.dryRun != nil {
+		r.logger.InfoContext(ctx, "Dry run", slog.Int("requests", len(r.dryRun.Requests)), slog.String("output", r.opt.DryRunOutput))
+		if writeErr := r.dryRun.write(r.opt.DryRunOutput); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
+	}
+	return err
+}
+
+func (r *reviewService) review(ctx context.Context) error {
+	// レビュー対象を取得（なければ終了）
+	target, err := r.getReviewTarget(ctx)
 	if err != nil {
-		r.logger.WarnContext(ctx, "Failed to get PR info.", slog.String("err", err.Error()))
+		r.logger.WarnContext(ctx, "Failed to get review target.", slog.String("err", err.Error()))
 		return nil
 	}
-	if pr == nil || pr.PullRequest == nil {
-		r.logger.WarnContext(ctx, "PR info is nil.")
+	if target == nil {
 		return nil
 	}
+	r.logger.InfoContext(ctx, "Start review", slog.String("event", target.Event), slog.String("head", target.HeadSHA), slog.String("base", target.BaseSHA))
+
+	// RISKENのプロジェクトへのアクセスを確認(optional)
+	if r.riskenClient != nil {
+		if err := r.validateRiskenProjects(ctx); err != nil {
+			return err
+		}
+	}
 
 	// ソースコードの差分を取得
-	changeFiles, err := r.ListPRFiles(ctx, pr)
+	changeFiles, err := r.listChangeFiles(ctx, target)
 	if err != nil {
 		return err
 	}
 
 	// スキャン
-	semgrep := scanner.NewSemgrepScanner(r.logger)
-	semgrepResults, err := semgrep.Scan(ctx, pr.Repository, pr.PullRequest, r.opt.GithubWorkspace, changeFiles)
+	var scanResult []*scanner.ScanResult
+	for _, s := range r.scanners() {
+		results, err := s.scanner.Scan(ctx, target.Repository, target.HeadSHA, r.opt.GithubWorkspace, changeFiles)
+		if err != nil {
+			return err
+		}
+		r.logger.InfoContext(ctx, fmt.Sprintf("Success %s scan", s.name), slog.Int("results", len(results)))
+		scanResult = append(scanResult, results...)
+	}
+	r.cache.LogStats(ctx)
+
+	// フィンガープリントを付与し、無視ファイルで抑制された指摘を除外
+	scanner.AssignFingerprints(r.opt.GithubWorkspace, scanResult)
+	suppressed, err := loadIgnoreFingerprints(r.opt.GithubWorkspace)
 	if err != nil {
 		return err
 	}
-	r.logger.InfoContext(ctx, "Success semgrep scan", slog.Int("results", len(semgrepResults)))
+	scanResult = filterSuppressedResults(suppressed, scanResult)
+
+	// シークレットの有効性を検証(optional)
+	if r.opt.VerifySecrets {
+		if err := r.verifySecrets(ctx, scanResult); err != nil {
+			return err
+		}
+	}
 
-	gitleaks := scanner.NewGitleaksScanner(r.logger)
-	gitleaksResults, err := gitleaks.Scan(ctx, pr.Repository, pr.PullRequest, r.opt.GithubWorkspace, changeFiles)
+	// 差分レビューで未スキャンのファイルの指摘を引き継ぐ
+	carried, err := r.carryForwardFindings(ctx, target)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to carry forward findings: %w", err)
 	}
-	r.logger.InfoContext(ctx, "Success gitleaks scan", slog.Int("results", len(gitleaksResults)))
-	scanResult := append(semgrepResults, gitleaksResults...)
+	carried = filterSuppressedResults(suppressed, carried)
 
 	// RISKNEN APIを叩く(optional)
-	if r.riskenClient != nil && len(scanResult) > 0 {
-		projectID, err := r.getProjectID(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get project ID: %w", err)
-		}
-		for i, result := range scanResult {
-			resp, err := r.putFinding(ctx, *projectID, result)
-			if err != nil {
-				return fmt.Errorf("failed to put finding: %w", err)
-			}
-			scanResult[i].RiskenURL = scanner.GenerateRiskenURL(r.opt.RiskenConsoleURL, resp.Finding.ProjectId, resp.Finding.FindingId)
+	// フルスキャン、PRのレビューは検出0件でも解決済みの判定のため実行する
+	// 失敗してもRISKENのリンクなしでレビューを続ける（--risken-required の場合は最後にエラーにする）
+	var riskenErr error
+	if r.riskenClient != nil && (len(scanResult) > 0 || target.Full || target.ChangeRequest != nil) {
+		riskenErr = r.integrateRisken(ctx, target, scanResult, carried)
+		if riskenErr != nil {
+			r.logger.WarnContext(ctx, "Failed RISKEN integration, continue the review", slog.String("err", riskenErr.Error()))
 		}
 	} else {
 		r.logger.InfoContext(ctx, "Skip RISKEN integration")
 	}
+	// RISKENでトリアージ済みの指摘を除外(annotateの場合はコメントに注記)
+	scanResult = r.filterTriagedResults(ctx, scanResult)
+
+	scanResult = append(scanResult, carried...)
+	r.summary.Carried = len(carried)
+
+	r.summary.Findings = len(scanResult)
+	defer r.logSummary(ctx)
 
-	// PRコメント
-	if r.opt.NoPRComment {
+	// 結果ファイル(optional)
+	if r.opt.Output != "" {
+		if err := writeOutput(r.opt.Output, target, scanResult); err != nil {
+			return err
+		}
+		r.logger.InfoContext(ctx, "Success output", slog.String("path", r.opt.Output))
+	}
+
+	// PRコメント / チェックラン
+	switch {
+	case r.opt.NoPRComment:
 		r.logger.InfoContext(ctx, "Skip PR comment")
-	} else {
-		if err := r.PullRequestComment(ctx, pr, scanResult); err != nil {
+	case target.Full:
+		r.logger.InfoContext(ctx, "Skip PR comment on full scan")
+	case target.ChangeRequest != nil:
+		if err := r.ChangeRequestComment(ctx, target.ChangeRequest, scanResult); err != nil {
 			return err
 		}
 		r.logger.InfoContext(ctx, "Success PR comment")
+	default:
+		if err := r.CheckRunReport(ctx, target, scanResult); err != nil {
+			return err
+		}
+		r.logger.InfoContext(ctx, "Success check run")
 	}
 
-	if r.opt.ErrorFlag && len(scanResult) > 0 {
-		return fmt.Errorf("there are findings(%d)", len(scanResult))
+	if riskenErr != nil && r.opt.RiskenRequired {
+		return fmt.Errorf("failed RISKEN integration: %w", riskenErr)
+	}
+	if untriaged := untriagedResults(scanResult); r.failOnFindings(target) && len(untriaged) > 0 {
+		return fmt.Errorf("there are findings(%d)", len(untriaged))
 	}
 	return nil
 }
+
+func (r *reviewService) logSummary(ctx context.Context) {
+	r.logger.InfoContext(ctx, "Review summary",
+		slog.Int("findings", r.summary.Findings),
+		slog.Int("resolved", r.summary.Resolved),
+		slog.Int("carried", r.summary.Carried),
+		slog.Int("comments", r.summary.Comments),
+		slog.Int("comment_failures", r.summary.CommentFailures),
+		slog.Int("risken_uploaded", r.summary.RiskenUploaded),
+		slog.Int("risken_failures", r.summary.RiskenFailures),
+		slog.Int("risken_spooled", r.summary.RiskenSpooled),
+		slog.Int("triaged", r.summary.Triaged),
+	)
+}
+
+type namedScanner struct {
+	name    string
+	scanner scanner.Scanner
+}
+
+func (r *reviewService) scanners() []*namedScanner {
+	scanners := []*namedScanner{
+		{name: "semgrep", scanner: scanner.NewSemgrepScanner(r.logger, r.cache)},
+		{name: "gitleaks", scanner: scanner.NewGitleaksScanner(r.logger, r.cache)},
+		{name: "workflow", scanner: scanner.NewWorkflowScanner(r.logger)},
+		{name: "dockerfile", scanner: scanner.NewDockerfileScanner(r.logger)},
+		{name: "iac", scanner: scanner.NewIaCScanner(r.logger)},
+	}
+	if r.opt.EntropyScan {
+		scanners = append(scanners, &namedScanner{name: "entropy", scanner: scanner.NewEntropyScanner(r.logger, r.opt.EntropyThreshold)})
+	}
+	if r.opt.AdvisoryDBPath != "" {
+		scanners = append(scanners, &namedScanner{name: "dependency", scanner: scanner.NewDependencyScanner(r.logger, r.opt.AdvisoryDBPath)})
+	}
+	return scanners
+}
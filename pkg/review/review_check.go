@@ -0,0 +1,118 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/google/go-github/v44/github"
+)
+
+const (
+	CHECK_RUN_NAME = "RISKEN Security Review"
+
+	CHECK_RUN_CONCLUSION_SUCCESS = "success"
+	CHECK_RUN_CONCLUSION_NEUTRAL = "neutral"
+	CHECK_RUN_CONCLUSION_FAILURE = "failure"
+)
+
+const (
+	NO_FINDING_SUMMARY = "セキュリティレビューを実施しました。\n特に問題は見つかりませんでした👏"
+	FINDING_SUMMARY    = "セキュリティレビューを実施しました。\n%d件の指摘があります。\n"
+)
+
+// CheckRunReport reports the results of the commit review (push, merge_group) as the check run.
+// If the token can not create the check run (no checks:write permission), it posts the commit comment instead.
+func (r *reviewService) CheckRunReport(ctx context.Context, target *reviewTarget, scanResults []*scanner.ScanResult) error {
+	conclusion := CHECK_RUN_CONCLUSION_SUCCESS
+	title := "No findings"
+	if len(scanResults) > 0 {
+		conclusion = CHECK_RUN_CONCLUSION_NEUTRAL
+		if r.failOnFindings(target) {
+			conclusion = CHECK_RUN_CONCLUSION_FAILURE
+		}
+		title = fmt.Sprintf("%d findings", len(scanResults))
+	}
+	summary := generateFindingSummary(scanResults)
+	var annotations []*github.CheckRunAnnotation
+	for _, result := range scanResults {
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            github.String(result.File),
+			StartLine:       github.Int(result.Line),
+			EndLine:         github.Int(result.Line),
+			AnnotationLevel: github.String(annotationLevel(result)),
+			Title:           github.String(result.ScanID),
+			Message:         github.String(generatePRReviewComment(result)),
+		})
+	}
+	err := r.githubClient.CreateCheckRun(ctx, target.Owner, target.RepoName, &github.CreateCheckRunOptions{
+		Name:        CHECK_RUN_NAME,
+		HeadSHA:     target.HeadSHA,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(conclusion),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output: &github.CheckRunOutput{
+			Title:       github.String(title),
+			Summary:     github.String(summary),
+			Annotations: annotations,
+		},
+	})
+	if err == nil {
+		r.summary.Comments++
+		return nil
+	}
+	r.logger.WarnContext(ctx, "failed to create check run, post commit comment instead", slog.String("err", err.Error()))
+	if len(scanResults) == 0 {
+		return nil
+	}
+	comment := &github.RepositoryComment{
+		Body: github.String(summary + "\n\n_By RISKEN review_"),
+	}
+	if err := r.githubClient.CreateCommitComment(ctx, target.Owner, target.RepoName, target.HeadSHA, comment); err != nil {
+		r.summary.CommentFailures++
+		return fmt.Errorf("failed to create commit comment: err=%w", err)
+	}
+	r.summary.Comments++
+	return nil
+}
+
+// annotationLevel returns the check run annotation level of the finding by the effective severity.
+func annotationLevel(result *scanner.ScanResult) string {
+	switch result.Severity() {
+	case scanner.SEVERITY_CRITICAL, scanner.SEVERITY_HIGH:
+		return "failure"
+	case scanner.SEVERITY_MEDIUM, "": // no finding (carried forward)
+		return "warning"
+	default:
+		return "notice"
+	}
+}
+
+// failOnFindings returns true if the findings fail the run. The merge queue is always gated.
+func (r *reviewService) failOnFindings(target *reviewTarget) bool {
+	return r.opt.ErrorFlag || target.Event == EVENT_MERGE_GROUP
+}
+
+func generateFindingSummary(scanResults []*scanner.ScanResult) string {
+	if len(scanResults) == 0 {
+		return NO_FINDING_SUMMARY
+	}
+	var summary strings.Builder
+	fmt.Fprintf(&summary, FINDING_SUMMARY, len(scanResults))
+	for _, result := range scanResults {
+		fmt.Fprintf(&summary, "\n- [%s#L%d](%s) `%s`", result.File, result.Line, result.GitHubURL, result.ScanID)
+		if result.RiskenURL != "" {
+			fmt.Fprintf(&summary, " ([RISKEN](%s))", result.RiskenURL)
+		}
+		if result.RiskenStatus == RISKEN_STATUS_FAILED || result.RiskenStatus == RISKEN_STATUS_SPOOLED {
+			fmt.Fprintf(&summary, " (RISKEN: %s)", result.RiskenStatus)
+		}
+		if result.RiskenTriage != "" {
+			fmt.Fprintf(&summary, " (RISKEN: %s)", result.RiskenTriage)
+		}
+	}
+	return summary.String()
+}
@@ -0,0 +1,156 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/verify"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v44/github"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCheckRunReport(t *testing.T) {
+	ctx := context.Background()
+	findings := []*scanner.ScanResult{
+		{ScanID: "scan_id", File: "main.go", Line: 3, ReviewComment: "review_comment", GitHubURL: "https://github.com/owner/repo/blob/head/main.go#L3"},
+	}
+	testCases := []struct {
+		name           string
+		event          string
+		errorFlag      bool
+		scanResults    []*scanner.ScanResult
+		checkRunErr    error
+		wantConclusion string
+		wantAnnotation int
+		wantCommit     bool
+		wantSummary    reviewSummary
+		wantErr        bool
+	}{
+		{
+			name:           "No findings",
+			event:          EVENT_PUSH,
+			wantConclusion: CHECK_RUN_CONCLUSION_SUCCESS,
+			wantSummary:    reviewSummary{Comments: 1},
+		},
+		{
+			name:           "Findings on push",
+			event:          EVENT_PUSH,
+			scanResults:    findings,
+			wantConclusion: CHECK_RUN_CONCLUSION_NEUTRAL,
+			wantAnnotation: 1,
+			wantSummary:    reviewSummary{Comments: 1},
+		},
+		{
+			name:           "Findings on push with error flag",
+			event:          EVENT_PUSH,
+			errorFlag:      true,
+			scanResults:    findings,
+			wantConclusion: CHECK_RUN_CONCLUSION_FAILURE,
+			wantAnnotation: 1,
+			wantSummary:    reviewSummary{Comments: 1},
+		},
+		{
+			name:           "Findings on merge_group",
+			event:          EVENT_MERGE_GROUP,
+			scanResults:    findings,
+			wantConclusion: CHECK_RUN_CONCLUSION_FAILURE,
+			wantAnnotation: 1,
+			wantSummary:    reviewSummary{Comments: 1},
+		},
+		{
+			name:           "Fallback to commit comment",
+			event:          EVENT_PUSH,
+			scanResults:    findings,
+			checkRunErr:    errors.New("403 Resource not accessible by integration"),
+			wantConclusion: CHECK_RUN_CONCLUSION_NEUTRAL,
+			wantAnnotation: 1,
+			wantCommit:     true,
+			wantSummary:    reviewSummary{Comments: 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := &reviewTarget{Event: tc.event, Owner: "owner", RepoName: "repo", HeadSHA: "head", BaseSHA: "base"}
+			mockClient := mocks.NewGitHubClient(t)
+			mockClient.
+				On("CreateCheckRun", ctx, "owner", "repo", mock.MatchedBy(func(opts *github.CreateCheckRunOptions) bool {
+					return opts.Name == CHECK_RUN_NAME && opts.HeadSHA == "head" &&
+						opts.GetConclusion() == tc.wantConclusion && len(opts.Output.Annotations) == tc.wantAnnotation
+				})).
+				Return(tc.checkRunErr).Once()
+			if tc.wantCommit {
+				mockClient.On("CreateCommitComment", ctx, "owner", "repo", "head", mock.Anything).Return(nil).Once()
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{ErrorFlag: tc.errorFlag},
+				githubClient: mockClient,
+				logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+			}
+			err := r.CheckRunReport(ctx, target, tc.scanResults)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("CheckRunReport() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantSummary, r.summary); diff != "" {
+				t.Errorf("CheckRunReport() summary mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateFindingSummary(t *testing.T) {
+	testCases := []struct {
+		name        string
+		scanResults []*scanner.ScanResult
+		want        string
+	}{
+		{
+			name: "No findings",
+			want: NO_FINDING_SUMMARY,
+		},
+		{
+			name: "Findings",
+			scanResults: []*scanner.ScanResult{
+				{ScanID: "rule1", File: "main.go", Line: 3, GitHubURL: "https://github.com/owner/repo/blob/head/main.go#L3", RiskenURL: "https://risken/finding"},
+				{ScanID: "rule2", File: "Dockerfile", Line: 1, GitHubURL: "https://github.com/owner/repo/blob/head/Dockerfile#L1"},
+			},
+			want: "セキュリティレビューを実施しました。\n2件の指摘があります。\n" +
+				"\n- [main.go#L3](https://github.com/owner/repo/blob/head/main.go#L3) `rule1` ([RISKEN](https://risken/finding))" +
+				"\n- [Dockerfile#L1](https://github.com/owner/repo/blob/head/Dockerfile#L1) `rule2`",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, generateFindingSummary(tc.scanResults)); diff != "" {
+				t.Errorf("generateFindingSummary() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAnnotationLevel(t *testing.T) {
+	testCases := []struct {
+		name   string
+		result *scanner.ScanResult
+		want   string
+	}{
+		{name: "Critical", result: &scanner.ScanResult{Finding: &scanner.DependencyFinding{Level: scanner.SEVERITY_CRITICAL}}, want: "failure"},
+		{name: "High", result: &scanner.ScanResult{Finding: &scanner.RuleFinding{Level: scanner.SEVERITY_HIGH}}, want: "failure"},
+		{name: "Medium", result: &scanner.ScanResult{Finding: &scanner.RuleFinding{Level: scanner.SEVERITY_MEDIUM}}, want: "warning"},
+		{name: "Live secret", result: &scanner.ScanResult{Finding: &scanner.RuleFinding{Level: scanner.SEVERITY_LOW}, Verification: &verify.Result{Status: verify.STATUS_LIVE}}, want: "failure"},
+		{name: "Low", result: &scanner.ScanResult{Finding: &scanner.RuleFinding{Level: scanner.SEVERITY_LOW}}, want: "notice"},
+		{name: "No finding", result: &scanner.ScanResult{}, want: "warning"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := annotationLevel(tc.result); got != tc.want {
+				t.Errorf("annotationLevel() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-github/v44/github"
+)
+
+// dryRunRecorder records the requests of the side effects instead of sending them.
+type dryRunRecorder struct {
+	mu       sync.Mutex
+	Requests []*dryRunRequest `json:"requests"`
+}
+
+type dryRunRequest struct {
+	Client string `json:"client"` // github, gitlab or risken
+	Method string `json:"method"`
+	Target string `json:"target,omitempty"`
+	Body   any    `json:"body"`
+}
+
+func (d *dryRunRecorder) record(client, method, target string, body any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Requests = append(d.Requests, &dryRunRequest{Client: client, Method: method, Target: target, Body: body})
+}
+
+// write writes the recorded requests to the file, or stdout if the path is empty.
+func (d *dryRunRecorder) write(path string) error {
+	if d.Requests == nil {
+		d.Requests = []*dryRunRequest{}
+	}
+	content, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dry run requests: %w", err)
+	}
+	if path == "" {
+		_, err = fmt.Fprintln(os.Stdout, string(content))
+		return err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write dry run requests: path=%s, err=%w", path, err)
+	}
+	return nil
+}
+
+// dryRunGitHubClient reads from GitHub, and records the writes.
+type dryRunGitHubClient struct {
+	GitHubClient
+	recorder *dryRunRecorder
+}
+
+func (c *dryRunGitHubClient) CreateIssueComment(ctx context.Context, owner, repoName string, prNumber int, comment *github.IssueComment) error {
+	c.recorder.record("github", "CreateIssueComment", fmt.Sprintf("%s/%s#%d", owner, repoName, prNumber), comment)
+	return nil
+}
+
+func (c *dryRunGitHubClient) CreatePRComment(ctx context.Context, owner, repoName string, prNumber int, comment *github.PullRequestComment) error {
+	c.recorder.record("github", "CreatePRComment", fmt.Sprintf("%s/%s#%d", owner, repoName, prNumber), comment)
+	return nil
+}
+
+func (c *dryRunGitHubClient) CreateCheckRun(ctx context.Context, owner, repoName string, opts *github.CreateCheckRunOptions) error {
+	c.recorder.record("github", "CreateCheckRun", fmt.Sprintf("%s/%s@%s", owner, repoName, opts.HeadSHA), opts)
+	return nil
+}
+
+func (c *dryRunGitHubClient) CreateCommitComment(ctx context.Context, owner, repoName, sha string, comment *github.RepositoryComment) error {
+	c.recorder.record("github", "CreateCommitComment", fmt.Sprintf("%s/%s@%s", owner, repoName, sha), comment)
+	return nil
+}
+
+// dryRunProvider reads from the SCM (other than GitHub, which is recorded by dryRunGitHubClient), and records the comments.
+type dryRunProvider struct {
+	scm.Provider
+	name     string
+	recorder *dryRunRecorder
+}
+
+func (p *dryRunProvider) CreateComment(ctx context.Context, cr *scm.ChangeRequest, body string) error {
+	p.recorder.record(p.name, "CreateComment", cr.String(), &scm.Comment{Body: body})
+	return nil
+}
+
+func (p *dryRunProvider) CreateLineComment(ctx context.Context, cr *scm.ChangeRequest, comment *scm.Comment) error {
+	p.recorder.record(p.name, "CreateLineComment", cr.String(), comment)
+	return nil
+}
+
+// dryRunRiskenClient reads from RISKEN, and records the writes.
+// The findings are not created, so the dummy finding IDs (counted down from the max, not to collide with the real IDs) are returned.
+type dryRunRiskenClient struct {
+	RiskenClient
+	recorder      *dryRunRecorder
+	nextFindingID atomic.Uint64
+}
+
+func newDryRunRiskenClient(client RiskenClient, recorder *dryRunRecorder) *dryRunRiskenClient {
+	c := &dryRunRiskenClient{RiskenClient: client, recorder: recorder}
+	c.nextFindingID.Store(math.MaxUint64)
+	return c
+}
+
+func (c *dryRunRiskenClient) PutFinding(ctx context.Context, req *finding.PutFindingRequest) (*finding.PutFindingResponse, error) {
+	c.recorder.record("risken", "PutFinding", fmt.Sprintf("project_id=%d", req.ProjectId), req)
+	findingID := c.nextFindingID.Add(^uint64(0)) + 1 // decrement
+	f := req.Finding
+	return &finding.PutFindingResponse{Finding: &finding.Finding{
+		FindingId:        findingID,
+		ProjectId:        req.ProjectId,
+		Description:      f.Description,
+		DataSource:       f.DataSource,
+		DataSourceId:     f.DataSourceId,
+		ResourceName:     f.ResourceName,
+		OriginalScore:    f.OriginalScore,
+		OriginalMaxScore: f.OriginalMaxScore,
+		Data:             f.Data,
+	}}, nil
+}
+
+func (c *dryRunRiskenClient) PutRecommend(ctx context.Context, req *finding.PutRecommendRequest) (*finding.PutRecommendResponse, error) {
+	c.recorder.record("risken", "PutRecommend", fmt.Sprintf("project_id=%d", req.ProjectId), req)
+	return &finding.PutRecommendResponse{}, nil
+}
+
+func (c *dryRunRiskenClient) TagFinding(ctx context.Context, req *finding.TagFindingRequest) (*finding.TagFindingResponse, error) {
+	c.recorder.record("risken", "TagFinding", fmt.Sprintf("project_id=%d", req.ProjectId), req)
+	return &finding.TagFindingResponse{Tag: &finding.FindingTag{FindingId: req.Tag.FindingId, ProjectId: req.ProjectId, Tag: req.Tag.Tag}}, nil
+}
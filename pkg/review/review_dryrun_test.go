@@ -0,0 +1,86 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v44/github"
+)
+
+func TestDryRunClients(t *testing.T) {
+	ctx := context.Background()
+	recorder := &dryRunRecorder{}
+
+	// GitHub: reads are passed through, writes are recorded
+	mockGitHub := mocks.NewGitHubClient(t)
+	repo := &github.Repository{FullName: github.String("owner/repo")}
+	mockGitHub.On("GetRepository", ctx, "owner", "repo").Return(repo, nil).Once()
+	githubClient := &dryRunGitHubClient{GitHubClient: mockGitHub, recorder: recorder}
+	gotRepo, err := githubClient.GetRepository(ctx, "owner", "repo")
+	if err != nil || gotRepo != repo {
+		t.Fatalf("GetRepository() = %v, %v", gotRepo, err)
+	}
+	if err := githubClient.CreatePRComment(ctx, "owner", "repo", 1, &github.PullRequestComment{Body: github.String("comment")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := githubClient.CreateCheckRun(ctx, "owner", "repo", &github.CreateCheckRunOptions{Name: CHECK_RUN_NAME, HeadSHA: "head"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// RISKEN: writes are recorded with the dummy finding IDs
+	riskenClient := newDryRunRiskenClient(mocks.NewRiskenClient(t), recorder)
+	var findingIDs []uint64
+	for _, id := range []string{"ds1", "ds2"} {
+		resp, err := riskenClient.PutFinding(ctx, &finding.PutFindingRequest{ProjectId: 1, Finding: &finding.FindingForUpsert{DataSource: "code:codescan", DataSourceId: id, OriginalScore: 0.6, OriginalMaxScore: 1.0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		findingIDs = append(findingIDs, resp.Finding.FindingId)
+	}
+	if diff := cmp.Diff([]uint64{math.MaxUint64, math.MaxUint64 - 1}, findingIDs); diff != "" {
+		t.Errorf("PutFinding() finding IDs mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := riskenClient.PutRecommend(ctx, &finding.PutRecommendRequest{ProjectId: 1, FindingId: findingIDs[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "dry-run.json")
+	if err := recorder.write(path); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Requests []struct {
+			Client string `json:"client"`
+			Method string `json:"method"`
+			Target string `json:"target"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"github CreatePRComment owner/repo#1",
+		"github CreateCheckRun owner/repo@head",
+		"risken PutFinding project_id=1",
+		"risken PutFinding project_id=1",
+		"risken PutRecommend project_id=1",
+	}
+	var gotRequests []string
+	for _, r := range got.Requests {
+		gotRequests = append(gotRequests, r.Client+" "+r.Method+" "+r.Target)
+	}
+	if diff := cmp.Diff(want, gotRequests); diff != "" {
+		t.Errorf("recorded requests mismatch (-want +got):\n%s", diff)
+	}
+}
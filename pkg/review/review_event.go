@@ -0,0 +1,324 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-github/v44/github"
+)
+
+// GitHub Actions event names
+// ref: https://docs.github.com/en/actions/using-workflows/events-that-trigger-workflows
+const (
+	EVENT_PULL_REQUEST        = "pull_request"
+	EVENT_PULL_REQUEST_TARGET = "pull_request_target"
+	EVENT_PUSH                = "push"
+	EVENT_MERGE_GROUP         = "merge_group"
+	EVENT_WORKFLOW_DISPATCH   = "workflow_dispatch"
+	EVENT_SCHEDULE            = "schedule"
+)
+
+// Scan modes
+const (
+	MODE_DIFF = "diff" // changed lines only
+	MODE_FULL = "full" // all tracked files
+)
+
+const (
+	zeroSHA          = "0000000000000000000000000000000000000000"
+	fullScanMaxBytes = 1024 * 1024
+)
+
+// githubEvent is the subset of the push, merge_group, workflow_dispatch and schedule event payloads.
+type githubEvent struct {
+	Ref        string             `json:"ref"`
+	Before     string             `json:"before"`
+	After      string             `json:"after"`
+	Deleted    bool               `json:"deleted"`
+	MergeGroup *githubMergeGroup  `json:"merge_group"`
+	Repository *github.Repository `json:"repository"`
+}
+
+type githubMergeGroup struct {
+	HeadSHA string `json:"head_sha"`
+	BaseSHA string `json:"base_sha"`
+}
+
+// reviewTarget is the commit (range) to review, resolved from the event.
+type reviewTarget struct {
+	Event      string
+	Owner      string
+	RepoName   string
+	Repository *scm.Repository
+	Ref        string
+	HeadSHA    string
+	BaseSHA    string
+	Full       bool
+	PR         *GithubPREvent // only for the GitHub pull request events
+
+	// ChangeRequest is the pull request or the merge request to comment on
+	ChangeRequest *scm.ChangeRequest
+
+	// CarriedFiles are the PR files not scanned in the incremental review
+	CarriedFiles []string
+}
+
+// isDefaultBranch returns true if the target is the default branch, which RISKEN reflects the state of.
+func (t *reviewTarget) isDefaultBranch() bool {
+	return t.Ref != "" && t.Ref == "refs/heads/"+t.Repository.DefaultBranch
+}
+
+// getReviewTarget resolves the review target from the event, and applies the scan mode.
+// It returns nil if the event is not reviewed.
+func (r *reviewService) getReviewTarget(ctx context.Context) (*reviewTarget, error) {
+	getTarget := r.getEventTarget
+	if r.scmClient != nil {
+		getTarget = r.getChangeRequestTarget
+	}
+	target, err := getTarget(ctx)
+	if err != nil || target == nil {
+		return nil, err
+	}
+	switch r.opt.Mode {
+	case MODE_FULL:
+		target.Full = true
+	case MODE_DIFF:
+		if target.Full && target.Event != EVENT_PUSH {
+			return nil, fmt.Errorf("diff mode is not supported on %s event", target.Event)
+		}
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown mode: %s", r.opt.Mode)
+	}
+	return target, nil
+}
+
+func (r *reviewService) getEventTarget(ctx context.Context) (*reviewTarget, error) {
+	eventName := r.opt.GithubEventName
+	if eventName == "" {
+		eventName = EVENT_PULL_REQUEST
+	}
+	switch eventName {
+	case EVENT_PULL_REQUEST_TARGET:
+		// The workflow runs with the write token and the secrets of the base repository, and reviewing the PR needs its code
+		// in the workspace. Checking out the PR head there is the vulnerability reported by WORKFLOW_PR_TARGET_CHECKOUT, so refuse it.
+		r.logger.WarnContext(ctx, "Skip pull_request_target review. Use the pull_request trigger to review the PR.")
+		return nil, nil
+
+	case EVENT_PULL_REQUEST:
+		pr, err := r.GetGithubPREvent()
+		if err != nil {
+			return nil, err
+		}
+		if pr == nil || pr.PullRequest == nil || pr.PullRequest.Head == nil {
+			r.logger.WarnContext(ctx, "PR info is nil.")
+			return nil, nil
+		}
+		target := &reviewTarget{
+			Event:      eventName,
+			Ref:        r.opt.GithubRef,
+			Owner:      pr.Owner,
+			RepoName:   pr.RepoName,
+			Repository: newRepository(pr.Repository),
+			HeadSHA:    pr.PullRequest.Head.GetSHA(),
+			PR:         pr,
+		}
+		target.ChangeRequest = &scm.ChangeRequest{
+			Number:     pr.Number,
+			URL:        pr.PullRequest.GetHTMLURL(),
+			HeadSHA:    target.HeadSHA,
+			BaseSHA:    pr.PullRequest.GetBase().GetSHA(),
+			HeadBranch: pr.PullRequest.GetHead().GetRef(),
+			BaseBranch: pr.PullRequest.GetBase().GetRef(),
+			Author:     pr.PullRequest.GetUser().GetLogin(),
+			Repository: target.Repository,
+		}
+		if pr.Action == PR_ACTION_SYNCHRONIZE && pr.Before != "" && !r.opt.NoIncremental {
+			// Review only the commits pushed since the previous review
+			target.BaseSHA = pr.Before
+		}
+		return target, nil
+
+	case EVENT_PUSH, EVENT_MERGE_GROUP, EVENT_WORKFLOW_DISPATCH, EVENT_SCHEDULE:
+		event, err := r.readGithubEvent()
+		if err != nil {
+			return nil, err
+		}
+		target := &reviewTarget{Event: eventName, Ref: event.Ref}
+		if target.Ref == "" {
+			target.Ref = r.opt.GithubRef
+		}
+		if err := r.resolveRepository(ctx, target, event.Repository); err != nil {
+			return nil, err
+		}
+		switch eventName {
+		case EVENT_PUSH:
+			if event.Deleted || event.After == zeroSHA {
+				r.logger.InfoContext(ctx, "Skip review for the deleted ref", slog.String("ref", event.Ref))
+				return nil, nil
+			}
+			target.HeadSHA, target.BaseSHA = event.After, event.Before
+			if event.Before == zeroSHA {
+				// New branch: compare with the default branch, or scan all if it is the default branch itself.
+				target.BaseSHA = target.Repository.DefaultBranch
+				target.Full = target.isDefaultBranch()
+			}
+		case EVENT_MERGE_GROUP:
+			if event.MergeGroup == nil {
+				return nil, fmt.Errorf("invalid merge_group event: merge_group is empty")
+			}
+			target.HeadSHA, target.BaseSHA = event.MergeGroup.HeadSHA, event.MergeGroup.BaseSHA
+		default:
+			target.Full = true
+			target.HeadSHA = r.opt.GithubSHA
+			if target.HeadSHA == "" {
+				if target.HeadSHA, err = r.workspaceHeadSHA(ctx); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return target, nil
+
+	default:
+		r.logger.WarnContext(ctx, "Unsupported event", slog.String("event", eventName))
+		return nil, nil
+	}
+}
+
+func (r *reviewService) readGithubEvent() (*githubEvent, error) {
+	content, err := os.ReadFile(r.opt.GithubEventPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: path=%s, err=%w", r.opt.GithubEventPath, err)
+	}
+	var event githubEvent
+	if err := json.Unmarshal(content, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode json: err=%w", err)
+	}
+	return &event, nil
+}
+
+// resolveRepository sets the repository of the payload, or gets it if the payload does not have it (schedule).
+func (r *reviewService) resolveRepository(ctx context.Context, target *reviewTarget, repo *github.Repository) error {
+	fullName := repo.GetFullName()
+	if fullName == "" {
+		fullName = r.opt.GithubRepository
+	}
+	owner, repoName, found := strings.Cut(fullName, "/")
+	if !found {
+		return fmt.Errorf("invalid repository name: %s", fullName)
+	}
+	target.Owner, target.RepoName = owner, repoName
+	if repo.GetHTMLURL() == "" {
+		var err error
+		repo, err = r.githubClient.GetRepository(ctx, owner, repoName)
+		if err != nil {
+			return fmt.Errorf("failed to get repository: repository=%s, err=%w", fullName, err)
+		}
+	}
+	target.Repository = newRepository(repo)
+	return nil
+}
+
+// listChangeFiles returns the files to scan, excluding the files in the ignore file.
+// The scanners only report the added lines in the patch, so all lines of the tracked files are marked as added for the full scan.
+func (r *reviewService) listChangeFiles(ctx context.Context, target *reviewTarget) ([]*scm.ChangeFile, error) {
+	rules, err := loadIgnoreRules(r.opt.GithubWorkspace)
+	if err != nil {
+		return nil, err
+	}
+	var files []*scm.ChangeFile
+	switch {
+	case target.Full:
+		files, err = r.listRepositoryFiles(ctx)
+	case target.PR != nil:
+		files, err = r.listPRChangeFiles(ctx, target)
+	case target.ChangeRequest != nil:
+		files, err = r.scm.ListChangeFiles(ctx, target.ChangeRequest)
+	default:
+		files, err = r.listCompareFiles(ctx, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return filterIgnoredFiles(rules, files), nil
+}
+
+func (r *reviewService) listCompareFiles(ctx context.Context, target *reviewTarget) ([]*scm.ChangeFile, error) {
+	files, err := r.githubClient.CompareCommits(ctx, target.Owner, target.RepoName, target.BaseSHA, target.HeadSHA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare commits: base=%s, head=%s, err=%w", target.BaseSHA, target.HeadSHA, err)
+	}
+	return newChangeFiles(files), nil
+}
+
+func (r *reviewService) listRepositoryFiles(ctx context.Context) ([]*scm.ChangeFile, error) {
+	out, err := r.git(ctx, "ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+	changeFiles := []*scm.ChangeFile{}
+	for _, name := range strings.Split(out, "\x00") {
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(r.opt.GithubWorkspace, name)
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() == 0 || info.Size() > fullScanMaxBytes {
+			continue // deleted in the work tree, symlink, submodule, empty or too large
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue // binary
+		}
+		changeFiles = append(changeFiles, &scm.ChangeFile{
+			Filename: name,
+			Status:   scm.FILE_ADDED,
+			Patch:    wholeFilePatch(string(content)),
+		})
+	}
+	r.logger.InfoContext(ctx, "Full scan", slog.Int("files", len(changeFiles)))
+	return changeFiles, nil
+}
+
+// wholeFilePatch returns the patch adding all lines of the file.
+func wholeFilePatch(content string) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	var patch strings.Builder
+	fmt.Fprintf(&patch, "@@ -0,0 +1,%d @@", len(lines))
+	for _, line := range lines {
+		patch.WriteString("\n+" + line)
+	}
+	return patch.String()
+}
+
+func (r *reviewService) workspaceHeadSHA(ctx context.Context) (string, error) {
+	out, err := r.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (r *reviewService) git(ctx context.Context, args ...string) (string, error) {
+	// The workspace is owned by the other user in the container action.
+	args = append([]string{"-c", "safe.directory=*", "-C", r.opt.GithubWorkspace}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to execute git %s: err=%w, stderr=%s", strings.Join(args[4:], " "), err, stderr.String())
+	}
+	return stdout.String(), nil
+}
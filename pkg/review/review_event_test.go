@@ -0,0 +1,259 @@
+package review
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v44/github"
+)
+
+// initTestGitRepo creates the git repository with the files committed, and returns the HEAD SHA.
+func initTestGitRepo(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v, %s", args, err, out)
+		}
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGetReviewTarget(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	workspaceHead := initTestGitRepo(t, workspace, map[string]string{"main.go": "package main\n"})
+	repo := &github.Repository{FullName: github.String("owner/repo"), HTMLURL: github.String("https://github.com/owner/repo"), DefaultBranch: github.String("main")}
+	scmRepo := &scm.Repository{Provider: scm.PROVIDER_GITHUB, FullName: "owner/repo", URL: "https://github.com/owner/repo", DefaultBranch: "main", Visibility: "public"}
+	prRepo := &scm.Repository{Provider: scm.PROVIDER_GITHUB, FullName: "owner/repo", Visibility: "public"}
+	testCases := []struct {
+		name      string
+		eventName string
+		event     string
+		sha       string
+		mode      string
+		mockRepo  bool
+		want      *reviewTarget
+		wantErr   bool
+	}{
+		{
+			name:  "pull_request (default)",
+			event: `{"number":1,"pull_request":{"head":{"sha":"head"}},"repository":{"full_name":"owner/repo"}}`,
+			want: &reviewTarget{
+				Event: EVENT_PULL_REQUEST, Owner: "owner", RepoName: "repo", Repository: prRepo, HeadSHA: "head",
+				ChangeRequest: &scm.ChangeRequest{Number: 1, HeadSHA: "head", Repository: prRepo},
+				PR:            &GithubPREvent{Number: 1, PullRequest: &github.PullRequest{Head: &github.PullRequestBranch{SHA: github.String("head")}}, Repository: &github.Repository{FullName: github.String("owner/repo")}, Owner: "owner", RepoName: "repo"},
+			},
+		},
+		{
+			name:  "pull_request synchronize (incremental)",
+			event: `{"action":"synchronize","number":1,"before":"before","after":"head","pull_request":{"head":{"sha":"head"}},"repository":{"full_name":"owner/repo"}}`,
+			want: &reviewTarget{
+				Event: EVENT_PULL_REQUEST, Owner: "owner", RepoName: "repo", Repository: prRepo, HeadSHA: "head", BaseSHA: "before",
+				ChangeRequest: &scm.ChangeRequest{Number: 1, HeadSHA: "head", Repository: prRepo},
+				PR:            &GithubPREvent{Action: "synchronize", Number: 1, Before: "before", After: "head", PullRequest: &github.PullRequest{Head: &github.PullRequestBranch{SHA: github.String("head")}}, Repository: &github.Repository{FullName: github.String("owner/repo")}, Owner: "owner", RepoName: "repo"},
+			},
+		},
+		{
+			name:      "pull_request_target is refused",
+			eventName: EVENT_PULL_REQUEST_TARGET,
+			event:     `{"number":1,"pull_request":{"head":{"sha":"` + workspaceHead + `"}},"repository":{"full_name":"owner/repo"}}`,
+			want:      nil,
+		},
+		{
+			name:      "push",
+			eventName: EVENT_PUSH,
+			event:     `{"ref":"refs/heads/feature","before":"before","after":"after","repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main","created_at":1700000000}}`,
+			want:      &reviewTarget{Event: EVENT_PUSH, Owner: "owner", RepoName: "repo", Repository: scmRepo, Ref: "refs/heads/feature", HeadSHA: "after", BaseSHA: "before"},
+		},
+		{
+			name:      "push (new branch)",
+			eventName: EVENT_PUSH,
+			event:     `{"ref":"refs/heads/feature","before":"` + zeroSHA + `","after":"after","repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main"}}`,
+			want:      &reviewTarget{Event: EVENT_PUSH, Owner: "owner", RepoName: "repo", Repository: scmRepo, Ref: "refs/heads/feature", HeadSHA: "after", BaseSHA: "main"},
+		},
+		{
+			name:      "push (new default branch)",
+			eventName: EVENT_PUSH,
+			event:     `{"ref":"refs/heads/main","before":"` + zeroSHA + `","after":"after","repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main"}}`,
+			want:      &reviewTarget{Event: EVENT_PUSH, Owner: "owner", RepoName: "repo", Repository: scmRepo, Ref: "refs/heads/main", HeadSHA: "after", BaseSHA: "main", Full: true},
+		},
+		{
+			name:      "push (deleted branch)",
+			eventName: EVENT_PUSH,
+			event:     `{"ref":"refs/heads/feature","before":"before","after":"` + zeroSHA + `","deleted":true,"repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo"}}`,
+			want:      nil,
+		},
+		{
+			name:      "merge_group",
+			eventName: EVENT_MERGE_GROUP,
+			event:     `{"merge_group":{"head_sha":"head","base_sha":"base"},"repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main"}}`,
+			want:      &reviewTarget{Event: EVENT_MERGE_GROUP, Owner: "owner", RepoName: "repo", Repository: scmRepo, HeadSHA: "head", BaseSHA: "base"},
+		},
+		{
+			name:      "merge_group (invalid)",
+			eventName: EVENT_MERGE_GROUP,
+			event:     `{"repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo"}}`,
+			wantErr:   true,
+		},
+		{
+			name:      "schedule (no repository in the payload)",
+			eventName: EVENT_SCHEDULE,
+			event:     `{"schedule":"0 0 * * *"}`,
+			sha:       "sha",
+			mockRepo:  true,
+			want:      &reviewTarget{Event: EVENT_SCHEDULE, Owner: "owner", RepoName: "repo", Repository: scmRepo, HeadSHA: "sha", Full: true},
+		},
+		{
+			name:      "workflow_dispatch (workspace HEAD)",
+			eventName: EVENT_WORKFLOW_DISPATCH,
+			event:     `{"ref":"refs/heads/main","repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main"}}`,
+			want:      &reviewTarget{Event: EVENT_WORKFLOW_DISPATCH, Owner: "owner", RepoName: "repo", Repository: scmRepo, Ref: "refs/heads/main", HeadSHA: workspaceHead, Full: true},
+		},
+		{
+			name:      "push (full mode)",
+			eventName: EVENT_PUSH,
+			event:     `{"ref":"refs/heads/main","before":"before","after":"after","repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main"}}`,
+			mode:      MODE_FULL,
+			want:      &reviewTarget{Event: EVENT_PUSH, Owner: "owner", RepoName: "repo", Repository: scmRepo, Ref: "refs/heads/main", HeadSHA: "after", BaseSHA: "before", Full: true},
+		},
+		{
+			name:      "schedule (diff mode)",
+			eventName: EVENT_SCHEDULE,
+			event:     `{"repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main"}}`,
+			sha:       "sha",
+			mode:      MODE_DIFF,
+			wantErr:   true,
+		},
+		{
+			name:      "Unknown mode",
+			eventName: EVENT_PUSH,
+			event:     `{"ref":"refs/heads/main","before":"before","after":"after","repository":{"full_name":"owner/repo","html_url":"https://github.com/owner/repo","default_branch":"main"}}`,
+			mode:      "all",
+			wantErr:   true,
+		},
+		{
+			name:      "Unsupported event",
+			eventName: "issues",
+			event:     `{}`,
+			want:      nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			eventPath := filepath.Join(t.TempDir(), "event.json")
+			if err := os.WriteFile(eventPath, []byte(tc.event), 0644); err != nil {
+				t.Fatal(err)
+			}
+			mockClient := mocks.NewGitHubClient(t)
+			if tc.mockRepo {
+				mockClient.On("GetRepository", ctx, "owner", "repo").Return(repo, nil).Once()
+			}
+			r := &reviewService{
+				opt: &ReviewOption{
+					GithubEventName:  tc.eventName,
+					GithubEventPath:  eventPath,
+					GithubWorkspace:  workspace,
+					GithubRepository: "owner/repo",
+					GithubSHA:        tc.sha,
+					Mode:             tc.mode,
+				},
+				githubClient: mockClient,
+				logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+			}
+			got, err := r.getReviewTarget(ctx)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("getReviewTarget() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("getReviewTarget() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListChangeFiles(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	initTestGitRepo(t, workspace, map[string]string{
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"empty.txt":      "",
+		"image.png":      "\x89PNG\x00\x00",
+		"dir/config.yml": "key: value",
+		"vendor/lib.go":  "package lib\n",
+		".riskenignore":  "# third party\nvendor/\n*.md\n",
+	})
+	testCases := []struct {
+		name      string
+		target    *reviewTarget
+		mockFiles []*github.CommitFile
+		want      []*scm.ChangeFile
+	}{
+		{
+			name:   "Full scan",
+			target: &reviewTarget{Event: EVENT_SCHEDULE, HeadSHA: "head", Full: true},
+			want: []*scm.ChangeFile{
+				{Filename: ".riskenignore", Status: "added", Patch: "@@ -0,0 +1,3 @@\n+# third party\n+vendor/\n+*.md"},
+				{Filename: "dir/config.yml", Status: "added", Patch: "@@ -0,0 +1,1 @@\n+key: value"},
+				{Filename: "main.go", Status: "added", Patch: "@@ -0,0 +1,3 @@\n+package main\n+\n+func main() {}"},
+			},
+		},
+		{
+			name:   "Compare commits",
+			target: &reviewTarget{Event: EVENT_PUSH, Owner: "owner", RepoName: "repo", HeadSHA: "head", BaseSHA: "base"},
+			mockFiles: []*github.CommitFile{
+				{Filename: github.String("main.go"), Status: github.String("modified")},
+				{Filename: github.String("old.go"), Status: github.String("removed")},
+				{Filename: github.String("README.md"), Status: github.String("modified")},
+				{Filename: github.String("vendor/lib.go"), Status: github.String("modified")},
+			},
+			want: []*scm.ChangeFile{
+				{Filename: "main.go", Status: "modified"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockClient := mocks.NewGitHubClient(t)
+			if tc.mockFiles != nil {
+				mockClient.On("CompareCommits", ctx, "owner", "repo", "base", "head").Return(tc.mockFiles, nil).Once()
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{GithubWorkspace: workspace},
+				githubClient: mockClient,
+				logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+			}
+			got, err := r.listChangeFiles(ctx, tc.target)
+			if err != nil {
+				t.Fatalf("listChangeFiles() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("listChangeFiles() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -6,10 +6,11 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"regexp"
 	"strings"
-	"time"
 
 	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
 	"github.com/google/go-github/v44/github"
 )
 
@@ -19,6 +20,8 @@ import (
 type GithubPREvent struct {
 	Action      string              `json:"action"`
 	Number      int                 `json:"number"`
+	Before      string              `json:"before"` // only for the synchronize action
+	After       string              `json:"after"`  // only for the synchronize action
 	PullRequest *github.PullRequest `json:"pull_request"`
 	Repository  *github.Repository  `json:"repository"`
 	Owner       string              `json:"owner"`
@@ -55,41 +58,15 @@ func (r *reviewService) GetGithubPREvent() (*GithubPREvent, error) {
 	return &event, nil
 }
 
-func (r *reviewService) ListPRFiles(ctx context.Context, pr *GithubPREvent) ([]*github.CommitFile, error) {
-	opts := &github.ListOptions{
-		PerPage: 100,
-	}
-	changeFiles := []*github.CommitFile{}
-	for {
-		files, resp, err := r.githubClient.ListFiles(ctx, pr.Owner, pr.RepoName, pr.Number, opts)
-		if err != nil {
-			return nil, err
-		}
-		for _, f := range files {
-			// https://docs.github.com/ja/rest/pulls/pulls?apiVersion=2022-11-28#list-pull-requests-files
-			if f.Status != nil && *f.Status == *github.String("removed") {
-				// Can not scan removed files
-				continue
-			}
-			changeFiles = append(changeFiles, f)
-		}
-		if resp.NextPage == 0 {
-			break
-		}
-		opts.Page = resp.NextPage
-		time.Sleep(1 * time.Second)
-	}
-	return changeFiles, nil
-}
-
 const (
 	NO_REVIEW_COMMENT = "セキュリティレビューを実施しました。\n特に問題は見つかりませんでした👏\n\n_By RISKEN review_"
 )
 
-func (r *reviewService) PullRequestComment(ctx context.Context, pr *GithubPREvent, scanResults []*scanner.ScanResult) error {
+// ChangeRequestComment comments the results on the diff lines of the pull request (merge request), or the no problem comment if no results.
+func (r *reviewService) ChangeRequestComment(ctx context.Context, cr *scm.ChangeRequest, scanResults []*scanner.ScanResult) error {
 	// No Review Comment
 	if len(scanResults) == 0 {
-		comments, err := r.githubClient.GetAllIssueComments(ctx, pr.Owner, pr.RepoName, pr.Number)
+		comments, err := r.scm.ListComments(ctx, cr)
 		if err != nil {
 			return fmt.Errorf("failed to get all issue comments: err=%w", err)
 		}
@@ -98,17 +75,16 @@ func (r *reviewService) PullRequestComment(ctx context.Context, pr *GithubPREven
 			return nil
 		}
 
-		comment := &github.IssueComment{
-			Body: github.String(NO_REVIEW_COMMENT),
-		}
-		if err = r.githubClient.CreateIssueComment(ctx, pr.Owner, pr.RepoName, pr.Number, comment); err != nil {
+		if err = r.scm.CreateComment(ctx, cr, NO_REVIEW_COMMENT); err != nil {
+			r.summary.CommentFailures++
 			return fmt.Errorf("failed to create comment: err=%w", err)
 		}
+		r.summary.Comments++
 		return nil
 	}
 
 	// Review Comment
-	comments, err := r.githubClient.GetAllPRComments(ctx, pr.Owner, pr.RepoName, pr.Number)
+	comments, err := r.scm.ListLineComments(ctx, cr)
 	if err != nil {
 		return fmt.Errorf("failed to get all comments: err=%w", err)
 	}
@@ -117,56 +93,90 @@ func (r *reviewService) PullRequestComment(ctx context.Context, pr *GithubPREven
 			r.logger.WarnContext(ctx, "already exists similar comment", slog.String("file", result.File), slog.Int("line", result.Line), slog.String("ID", result.ScanID))
 			continue
 		}
-		comment := &github.PullRequestComment{
-			Body:     github.String(generatePRReviewComment(result)),
-			CommitID: github.String(*pr.PullRequest.Head.SHA),
-			Path:     github.String(result.File),
-			Line:     github.Int(result.Line),
+		comment := &scm.Comment{
+			Body: generatePRReviewComment(result),
+			Path: result.File,
+			Line: result.Line,
 		}
-		if err := r.githubClient.CreatePRComment(ctx, pr.Owner, pr.RepoName, pr.Number, comment); err != nil {
+		if err := r.scm.CreateLineComment(ctx, cr, comment); err != nil {
 			r.logger.WarnContext(ctx, "failed to create comment", slog.String("file", result.File), slog.Int("line", result.Line), slog.String("err", err.Error()))
+			r.summary.CommentFailures++
 			continue
 		}
+		r.summary.Comments++
 	}
 	return nil
 }
 
-func existsSimilarPRComment(scanResult *scanner.ScanResult, comments []*github.PullRequestComment) bool {
+func existsSimilarPRComment(scanResult *scanner.ScanResult, comments []*scm.Comment) bool {
 	for _, c := range comments {
-		if c.Path == nil || c.Line == nil {
+		// The fingerprint identifies the same finding on the shifted line
+		if m := fingerprintPattern.FindStringSubmatch(c.Body); m != nil && scanResult.Fingerprint != "" {
+			if m[1] == scanResult.Fingerprint {
+				return true
+			}
 			continue
 		}
-		if strings.Contains(*c.Body, scanResult.ScanID) && *c.Path == scanResult.File && *c.Line == scanResult.Line {
+		if strings.Contains(c.Body, scanResult.ScanID) && c.Path == scanResult.File && c.Line == scanResult.Line {
 			return true
 		}
 	}
 	return false
 }
 
-func existsSimilarIssueComment(comments []*github.IssueComment, key string) bool {
+func existsSimilarIssueComment(comments []*scm.Comment, key string) bool {
 	for _, c := range comments {
-		if strings.Contains(*c.Body, key) {
+		if strings.Contains(c.Body, key) {
 			return true
 		}
 	}
 	return false
 }
 
+var fingerprintPattern = regexp.MustCompile("Fingerprint: `([0-9a-f]+)`")
+
 const (
-	RISKEN_COMMENT_TEMPLATE = `
+	REVIEW_COMMENT_SIGNATURE = "_By RISKEN review_"
+	SCAN_ID_MARKER_TEMPLATE  = "<!-- risken-review:scan_id=%s -->"
+	FINGERPRINT_TEMPLATE     = "Fingerprint: `%s`"
+	RISKEN_COMMENT_TEMPLATE  = `
 
 #### RISKENで確認
 
 より詳細な情報や生成AIによる解説はRISKENコンソール上で確認できます。
 
 - %s`
+	RISKEN_TRIAGE_COMMENT_TEMPLATE = `
+
+#### RISKENでトリアージ済み
+
+この指摘はRISKENで「%s」としてトリアージされています。`
 )
 
+var riskenTriageLabels = map[string]string{
+	RISKEN_TRIAGE_PENDING:        "保留",
+	RISKEN_TRIAGE_FALSE_POSITIVE: "誤検知",
+}
+
 func generatePRReviewComment(result *scanner.ScanResult) string {
 	reviewComment := result.ReviewComment
 	if result.RiskenURL != "" {
 		reviewComment += fmt.Sprintf(RISKEN_COMMENT_TEMPLATE, result.RiskenURL)
 	}
-	reviewComment += "\n\n_By RISKEN review_"
+	if result.RiskenTriage != "" {
+		reviewComment += fmt.Sprintf(RISKEN_TRIAGE_COMMENT_TEMPLATE, riskenTriageLabels[result.RiskenTriage])
+		if result.RiskenTriageNote != "" {
+			reviewComment += "\n\n> " + strings.ReplaceAll(result.RiskenTriageNote, "\n", "\n> ")
+		}
+	}
+	reviewComment += "\n\n" + REVIEW_COMMENT_SIGNATURE
+	if result.Fingerprint != "" {
+		// Shown to suppress the finding in the ignore file
+		reviewComment += "\n" + fmt.Sprintf(FINGERPRINT_TEMPLATE, result.Fingerprint)
+	}
+	if result.ScanID != "" {
+		// The hidden marker to carry forward the finding on the incremental review
+		reviewComment += "\n" + fmt.Sprintf(SCAN_ID_MARKER_TEMPLATE, result.ScanID)
+	}
 	return reviewComment
 }
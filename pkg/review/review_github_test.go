@@ -3,11 +3,13 @@ package review
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"os"
 	"testing"
 
 	"github.com/ca-risken/security-review/pkg/mocks"
 	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-github/v44/github"
 	"github.com/stretchr/testify/mock"
@@ -75,7 +77,7 @@ func TestGetGithubPREvent(t *testing.T) {
 	}
 }
 
-func TestListPRFiles(t *testing.T) {
+func TestGitHubProviderListChangeFiles(t *testing.T) {
 	ctx := context.Background()
 	type MockResp struct {
 		files []*github.CommitFile
@@ -85,17 +87,16 @@ func TestListPRFiles(t *testing.T) {
 
 	testCases := []struct {
 		name     string
-		args     *GithubPREvent
+		args     *scm.ChangeRequest
 		mockResp *MockResp
-		want     []*github.CommitFile
+		want     []*scm.ChangeFile
 		wantErr  bool
 	}{
 		{
 			name: "OK",
-			args: &GithubPREvent{
-				Owner:    "owner",
-				RepoName: "repo",
-				Number:   1,
+			args: &scm.ChangeRequest{
+				Number:     1,
+				Repository: &scm.Repository{FullName: "owner/repo"},
 			},
 			mockResp: &MockResp{
 				files: []*github.CommitFile{
@@ -107,18 +108,17 @@ func TestListPRFiles(t *testing.T) {
 				},
 				err: nil,
 			},
-			want: []*github.CommitFile{
-				{Filename: github.String("file1.txt")},
-				{Filename: github.String("file2.txt")},
+			want: []*scm.ChangeFile{
+				{Filename: "file1.txt"},
+				{Filename: "file2.txt"},
 			},
 			wantErr: false,
 		},
 		{
 			name: "Error",
-			args: &GithubPREvent{
-				Owner:    "owner",
-				RepoName: "repo",
-				Number:   1,
+			args: &scm.ChangeRequest{
+				Number:     1,
+				Repository: &scm.Repository{FullName: "owner/repo"},
 			},
 			mockResp: &MockResp{
 				files: []*github.CommitFile{},
@@ -133,25 +133,23 @@ func TestListPRFiles(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			mockClient := mocks.NewGitHubClient(t)
 			mockClient.
-				On("ListFiles", ctx, tc.args.Owner, tc.args.RepoName, tc.args.Number, mock.Anything).
+				On("ListFiles", ctx, "owner", "repo", tc.args.Number, mock.Anything).
 				Return(tc.mockResp.files, tc.mockResp.resp, tc.mockResp.err).
 				Once()
 
-			service := &reviewService{
-				githubClient: mockClient,
-			}
-			files, err := service.ListPRFiles(ctx, tc.args)
+			provider := &githubProvider{client: mockClient}
+			files, err := provider.ListChangeFiles(ctx, tc.args)
 			if (err != nil) != tc.wantErr {
-				t.Errorf("Client.Signin() error = %v, wantErr %v", err, tc.wantErr)
+				t.Errorf("ListChangeFiles() error = %v, wantErr %v", err, tc.wantErr)
 			}
 			if diff := cmp.Diff(files, tc.want); diff != "" {
-				t.Errorf("ListPRFiles() mismatch (-want +got):\n%s", diff)
+				t.Errorf("ListChangeFiles() mismatch (-want +got):\n%s", diff)
 			}
 		})
 	}
 }
 
-func TestPullRequestComment(t *testing.T) {
+func TestChangeRequestComment(t *testing.T) {
 	ctx := context.Background()
 	type MockRespIssueComments struct {
 		comments []*github.IssueComment
@@ -162,7 +160,7 @@ func TestPullRequestComment(t *testing.T) {
 		err      error
 	}
 	type Args struct {
-		pr          *GithubPREvent
+		cr          *scm.ChangeRequest
 		scanResults []*scanner.ScanResult
 	}
 
@@ -171,20 +169,17 @@ func TestPullRequestComment(t *testing.T) {
 		args                  *Args
 		mockRespIssueComments *MockRespIssueComments
 		mockRespPRComments    *MockRespPRComments
+		mockCreateErr         error
+		wantSummary           reviewSummary
 		wantErr               bool
 	}{
 		{
 			name: "OK",
 			args: &Args{
-				pr: &GithubPREvent{
-					Owner:    "owner",
-					RepoName: "repo",
-					Number:   1,
-					PullRequest: &github.PullRequest{
-						Head: &github.PullRequestBranch{
-							SHA: github.String("sha"),
-						},
-					},
+				cr: &scm.ChangeRequest{
+					Number:     1,
+					HeadSHA:    "sha",
+					Repository: &scm.Repository{FullName: "owner/repo"},
 				},
 				scanResults: []*scanner.ScanResult{
 					{
@@ -194,32 +189,52 @@ func TestPullRequestComment(t *testing.T) {
 						DiffHunk:      "diff_hunk",
 						ReviewComment: "review_comment",
 						GitHubURL:     "github_url",
-						ScanResult:    "scan_result",
+						Finding:       &scanner.RuleFinding{},
 						RiskenURL:     "risken_url",
 					},
 				},
 			},
 			mockRespIssueComments: &MockRespIssueComments{comments: []*github.IssueComment{}},
 			mockRespPRComments:    &MockRespPRComments{comments: []*github.PullRequestComment{}},
+			wantSummary:           reviewSummary{Comments: 1},
 			wantErr:               false,
 		},
 		{
-			name: "OK(no review comment))",
+			name: "OK(failed to create review comment)",
 			args: &Args{
-				pr: &GithubPREvent{
-					Owner:    "owner",
-					RepoName: "repo",
-					Number:   1,
-					PullRequest: &github.PullRequest{
-						Head: &github.PullRequestBranch{
-							SHA: github.String("sha"),
-						},
+				cr: &scm.ChangeRequest{
+					Number:     1,
+					HeadSHA:    "sha",
+					Repository: &scm.Repository{FullName: "owner/repo"},
+				},
+				scanResults: []*scanner.ScanResult{
+					{
+						ScanID:        "scan_id",
+						File:          "file1.txt",
+						Line:          1,
+						ReviewComment: "review_comment",
 					},
 				},
+			},
+			mockRespIssueComments: &MockRespIssueComments{comments: []*github.IssueComment{}},
+			mockRespPRComments:    &MockRespPRComments{comments: []*github.PullRequestComment{}},
+			mockCreateErr:         errors.New("502 Bad Gateway"),
+			wantSummary:           reviewSummary{CommentFailures: 1},
+			wantErr:               false,
+		},
+		{
+			name: "OK(no review comment))",
+			args: &Args{
+				cr: &scm.ChangeRequest{
+					Number:     1,
+					HeadSHA:    "sha",
+					Repository: &scm.Repository{FullName: "owner/repo"},
+				},
 				scanResults: []*scanner.ScanResult{},
 			},
 			mockRespIssueComments: &MockRespIssueComments{comments: []*github.IssueComment{}},
 			mockRespPRComments:    &MockRespPRComments{comments: []*github.PullRequestComment{}},
+			wantSummary:           reviewSummary{Comments: 1},
 			wantErr:               false,
 		},
 	}
@@ -230,27 +245,32 @@ func TestPullRequestComment(t *testing.T) {
 			if len(tc.args.scanResults) == 0 {
 				// No review comment
 				mockClient.
-					On("GetAllIssueComments", ctx, tc.args.pr.Owner, tc.args.pr.RepoName, tc.args.pr.Number).
+					On("GetAllIssueComments", ctx, "owner", "repo", tc.args.cr.Number).
 					Return(tc.mockRespIssueComments.comments, tc.mockRespIssueComments.err).Once()
 				mockClient.
-					On("CreateIssueComment", ctx, tc.args.pr.Owner, tc.args.pr.RepoName, tc.args.pr.Number, mock.Anything).
+					On("CreateIssueComment", ctx, "owner", "repo", tc.args.cr.Number, mock.Anything).
 					Return(nil).Once()
 			} else {
 				// Review comment
 				mockClient.
-					On("GetAllPRComments", ctx, tc.args.pr.Owner, tc.args.pr.RepoName, tc.args.pr.Number).
+					On("GetAllPRComments", ctx, "owner", "repo", tc.args.cr.Number).
 					Return(tc.mockRespPRComments.comments, tc.mockRespPRComments.err).Once()
 				mockClient.
-					On("CreatePRComment", ctx, tc.args.pr.Owner, tc.args.pr.RepoName, tc.args.pr.Number, mock.Anything).
-					Return(nil).Once()
+					On("CreatePRComment", ctx, "owner", "repo", tc.args.cr.Number, mock.Anything).
+					Return(tc.mockCreateErr).Once()
 			}
 
 			service := &reviewService{
 				githubClient: mockClient,
+				scm:          &githubProvider{client: mockClient},
+				logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
 			}
-			err := service.PullRequestComment(ctx, tc.args.pr, tc.args.scanResults)
+			err := service.ChangeRequestComment(ctx, tc.args.cr, tc.args.scanResults)
 			if (err != nil) != tc.wantErr {
-				t.Errorf("PullRequestComment() error = %v, wantErr %v", err, tc.wantErr)
+				t.Errorf("ChangeRequestComment() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantSummary, service.summary); diff != "" {
+				t.Errorf("ChangeRequestComment() summary mismatch (-want +got):\n%s", diff)
 			}
 		})
 	}
@@ -260,7 +280,7 @@ func TestExistsSimilarPRComment(t *testing.T) {
 	testCases := []struct {
 		name       string
 		scanResult *scanner.ScanResult
-		comments   []*github.PullRequestComment
+		comments   []*scm.Comment
 		want       bool
 	}{
 		{
@@ -270,11 +290,11 @@ func TestExistsSimilarPRComment(t *testing.T) {
 				File:   "file.go",
 				Line:   10,
 			},
-			comments: []*github.PullRequestComment{
+			comments: []*scm.Comment{
 				{
-					Body: github.String("Issue found ID123"),
-					Path: github.String("file.go"),
-					Line: github.Int(10),
+					Body: "Issue found ID123",
+					Path: "file.go",
+					Line: 10,
 				},
 			},
 			want: true,
@@ -286,11 +306,45 @@ func TestExistsSimilarPRComment(t *testing.T) {
 				File:   "file.go",
 				Line:   10,
 			},
-			comments: []*github.PullRequestComment{
+			comments: []*scm.Comment{
+				{
+					Body: "Different issue ID456",
+					Path: "file.go",
+					Line: 10,
+				},
+			},
+			want: false,
+		},
+		{
+			name: "Same fingerprint on shifted line",
+			scanResult: &scanner.ScanResult{
+				ScanID:      "ID123",
+				Fingerprint: "abc123",
+				File:        "file.go",
+				Line:        12,
+			},
+			comments: []*scm.Comment{
 				{
-					Body: github.String("Different issue ID456"),
-					Path: github.String("file.go"),
-					Line: github.Int(10),
+					Body: "Issue found ID123\n\n_By RISKEN review_\nFingerprint: `abc123`",
+					Path: "file.go",
+					Line: 10,
+				},
+			},
+			want: true,
+		},
+		{
+			name: "Different fingerprint on same line",
+			scanResult: &scanner.ScanResult{
+				ScanID:      "ID123",
+				Fingerprint: "abc123",
+				File:        "file.go",
+				Line:        10,
+			},
+			comments: []*scm.Comment{
+				{
+					Body: "Issue found ID123\n\n_By RISKEN review_\nFingerprint: `def456`",
+					Path: "file.go",
+					Line: 10,
 				},
 			},
 			want: false,
@@ -309,24 +363,24 @@ func TestExistsSimilarPRComment(t *testing.T) {
 func TestExistsSimilarIssueComment(t *testing.T) {
 	testCases := []struct {
 		name     string
-		comments []*github.IssueComment
+		comments []*scm.Comment
 		key      string
 		want     bool
 	}{
 		{
 			name: "Comment Contains Key",
-			comments: []*github.IssueComment{
-				{Body: github.String("This is a comment containing the key: key123")},
-				{Body: github.String("Another comment")},
+			comments: []*scm.Comment{
+				{Body: "This is a comment containing the key: key123"},
+				{Body: "Another comment"},
 			},
 			key:  "key123",
 			want: true,
 		},
 		{
 			name: "No Comment Contains Key",
-			comments: []*github.IssueComment{
-				{Body: github.String("This is a comment")},
-				{Body: github.String("Another comment")},
+			comments: []*scm.Comment{
+				{Body: "This is a comment"},
+				{Body: "Another comment"},
 			},
 			key:  "key123",
 			want: false,
@@ -375,6 +429,44 @@ _By RISKEN review_`,
 
 _By RISKEN review_`,
 		},
+		{
+			name: "With ScanID",
+			scanResult: &scanner.ScanResult{
+				ScanID:        "rule_id",
+				ReviewComment: "Initial review comment.",
+			},
+			wantComment: `Initial review comment.
+
+_By RISKEN review_
+<!-- risken-review:scan_id=rule_id -->`,
+		},
+		{
+			name: "With RISKEN triage",
+			scanResult: &scanner.ScanResult{
+				ReviewComment:    "Initial review comment.",
+				RiskenTriage:     RISKEN_TRIAGE_FALSE_POSITIVE,
+				RiskenTriageNote: "Test data.\nNot a real key.",
+			},
+			wantComment: `Initial review comment.
+
+#### RISKENでトリアージ済み
+
+この指摘はRISKENで「誤検知」としてトリアージされています。
+
+> Test data.
+> Not a real key.
+
+_By RISKEN review_`,
+		},
+		{
+			name: "With Fingerprint",
+			scanResult: &scanner.ScanResult{
+				ScanID:        "rule_id",
+				Fingerprint:   "abc123",
+				ReviewComment: "Initial review comment.",
+			},
+			wantComment: "Initial review comment.\n\n_By RISKEN review_\nFingerprint: `abc123`\n<!-- risken-review:scan_id=rule_id -->",
+		},
 	}
 
 	for _, tc := range testCases {
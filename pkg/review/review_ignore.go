@@ -0,0 +1,153 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+// RISKEN_IGNORE_FILE lists the files excluded from all scanners, in the subset of the gitignore syntax.
+const RISKEN_IGNORE_FILE = ".riskenignore"
+
+// IGNORE_FINGERPRINT_PREFIX is the prefix of the lines in the ignore file to suppress the findings by the fingerprint.
+const IGNORE_FINGERPRINT_PREFIX = "fingerprint:"
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreRules supports `*`, `?`, `[...]`, `dir/`, `/anchored`, `**/` prefix and `!negation`.
+func parseIgnoreRules(content string) []*ignoreRule {
+	var rules []*ignoreRule
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, IGNORE_FINGERPRINT_PREFIX) {
+			continue
+		}
+		rule := &ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimSuffix(line, "/**")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		line = strings.TrimPrefix(line, "**/")
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// match checks the file path and its parent directories.
+func (r *ignoreRule) match(name string) bool {
+	parts := strings.Split(name, "/")
+	for i := 1; i <= len(parts); i++ {
+		if r.dirOnly && i == len(parts) {
+			continue
+		}
+		target := parts[i-1]
+		if r.anchored {
+			target = strings.Join(parts[:i], "/")
+		}
+		if ok, _ := path.Match(r.pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isIgnored(rules []*ignoreRule, name string) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.match(name) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnoreFingerprints returns the fingerprints of the `fingerprint:<fingerprint>` lines.
+func parseIgnoreFingerprints(content string) map[string]bool {
+	fingerprints := map[string]bool{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if fingerprint, ok := strings.CutPrefix(line, IGNORE_FINGERPRINT_PREFIX); ok && strings.TrimSpace(fingerprint) != "" {
+			fingerprints[strings.TrimSpace(fingerprint)] = true
+		}
+	}
+	return fingerprints
+}
+
+func readIgnoreFile(workspace string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(workspace, RISKEN_IGNORE_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", RISKEN_IGNORE_FILE, err)
+	}
+	return string(content), nil
+}
+
+func loadIgnoreRules(workspace string) ([]*ignoreRule, error) {
+	content, err := readIgnoreFile(workspace)
+	if err != nil {
+		return nil, err
+	}
+	return parseIgnoreRules(content), nil
+}
+
+func loadIgnoreFingerprints(workspace string) (map[string]bool, error) {
+	content, err := readIgnoreFile(workspace)
+	if err != nil {
+		return nil, err
+	}
+	return parseIgnoreFingerprints(content), nil
+}
+
+func filterIgnoredFiles(rules []*ignoreRule, files []*scm.ChangeFile) []*scm.ChangeFile {
+	if len(rules) == 0 {
+		return files
+	}
+	filtered := []*scm.ChangeFile{}
+	for _, f := range files {
+		if !isIgnored(rules, f.Filename) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+// filterSuppressedResults excludes the scan results of which the fingerprints are in the ignore file.
+func filterSuppressedResults(fingerprints map[string]bool, results []*scanner.ScanResult) []*scanner.ScanResult {
+	if len(fingerprints) == 0 {
+		return results
+	}
+	filtered := []*scanner.ScanResult{}
+	for _, r := range results {
+		if !fingerprints[r.Fingerprint] {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
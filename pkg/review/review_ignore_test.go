@@ -0,0 +1,71 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestIsIgnored(t *testing.T) {
+	rules := parseIgnoreRules(`
+# comment
+vendor/
+*.min.js
+/docs/*.md
+**/testdata/**
+build
+!build/keep.go
+`)
+	testCases := []struct {
+		name string
+		file string
+		want bool
+	}{
+		{name: "Not matched", file: "main.go", want: false},
+		{name: "Directory", file: "vendor/lib/lib.go", want: true},
+		{name: "Nested directory", file: "pkg/vendor/lib.go", want: true},
+		{name: "Directory only rule does not match file", file: "vendor", want: false},
+		{name: "Wildcard", file: "web/app.min.js", want: true},
+		{name: "Anchored", file: "docs/README.md", want: true},
+		{name: "Anchored (nested)", file: "pkg/docs/README.md", want: false},
+		{name: "Double star", file: "pkg/scanner/testdata/key.txt", want: true},
+		{name: "File or directory", file: "build/out.go", want: true},
+		{name: "Negation", file: "build/keep.go", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, isIgnored(rules, tc.file)); diff != "" {
+				t.Errorf("isIgnored(%s) mismatch (-want +got):\n%s", tc.file, diff)
+			}
+		})
+	}
+}
+
+func TestFilterSuppressedResults(t *testing.T) {
+	content := `
+vendor/
+# accepted risk
+fingerprint: abc123
+fingerprint:
+`
+	if diff := cmp.Diff([]*ignoreRule{{pattern: "vendor", dirOnly: true}}, parseIgnoreRules(content), cmp.AllowUnexported(ignoreRule{})); diff != "" {
+		t.Errorf("parseIgnoreRules() mismatch (-want +got):\n%s", diff)
+	}
+	fingerprints := parseIgnoreFingerprints(content)
+	if diff := cmp.Diff(map[string]bool{"abc123": true}, fingerprints); diff != "" {
+		t.Errorf("parseIgnoreFingerprints() mismatch (-want +got):\n%s", diff)
+	}
+	results := []*scanner.ScanResult{
+		{ScanID: "rule1", Fingerprint: "abc123"},
+		{ScanID: "rule2", Fingerprint: "def456"},
+		{ScanID: "rule3"}, // carried from the legacy comment
+	}
+	want := []*scanner.ScanResult{
+		{ScanID: "rule2", Fingerprint: "def456"},
+		{ScanID: "rule3"},
+	}
+	if diff := cmp.Diff(want, filterSuppressedResults(fingerprints, results)); diff != "" {
+		t.Errorf("filterSuppressedResults() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,91 @@
+package review
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+const PR_ACTION_SYNCHRONIZE = "synchronize"
+
+var scanIDMarkerPattern = regexp.MustCompile(`<!-- risken-review:scan_id=(.+?) -->`)
+
+// listPRChangeFiles returns the PR files. On the incremental review, only the PR files changed since the previous review (BaseSHA) are returned,
+// and the others are recorded in the target to carry forward the findings.
+func (r *reviewService) listPRChangeFiles(ctx context.Context, target *reviewTarget) ([]*scm.ChangeFile, error) {
+	files, err := r.scm.ListChangeFiles(ctx, target.ChangeRequest)
+	if err != nil {
+		return nil, err
+	}
+	if target.BaseSHA == "" {
+		return files, nil
+	}
+	compareFiles, err := r.githubClient.CompareCommits(ctx, target.Owner, target.RepoName, target.BaseSHA, target.HeadSHA)
+	if err != nil {
+		// e.g. the previous head is unreachable after the force push
+		r.logger.WarnContext(ctx, "Failed to compare with the previous review, review all PR files", slog.String("base", target.BaseSHA), slog.String("err", err.Error()))
+		target.BaseSHA = ""
+		return files, nil
+	}
+	if len(compareFiles) >= COMPARE_MAX_FILES {
+		// The files over the limit are truncated, which would be carried forward without scanning
+		r.logger.WarnContext(ctx, "Too many files changed since the previous review, review all PR files", slog.String("base", target.BaseSHA), slog.Int("files", len(compareFiles)))
+		target.BaseSHA = ""
+		return files, nil
+	}
+	changed := map[string]bool{}
+	for _, f := range compareFiles {
+		changed[f.GetFilename()] = true
+	}
+	// Use the PR files (patch of the PR) to comment on the lines in the PR diff, not the files only changed by merging the base branch.
+	changeFiles := []*scm.ChangeFile{}
+	for _, f := range files {
+		if changed[f.Filename] {
+			changeFiles = append(changeFiles, f)
+			continue
+		}
+		target.CarriedFiles = append(target.CarriedFiles, f.Filename)
+	}
+	r.logger.InfoContext(ctx, "Incremental review", slog.Int("files", len(changeFiles)), slog.Int("carried_files", len(target.CarriedFiles)))
+	return changeFiles, nil
+}
+
+// carryForwardFindings returns the findings of the previous reviews on the files not scanned in the incremental review.
+// The findings are restored from the review comments which are still on the PR diff (not outdated).
+func (r *reviewService) carryForwardFindings(ctx context.Context, target *reviewTarget) ([]*scanner.ScanResult, error) {
+	if target.ChangeRequest == nil || len(target.CarriedFiles) == 0 {
+		return nil, nil
+	}
+	carriedFiles := map[string]bool{}
+	for _, f := range target.CarriedFiles {
+		carriedFiles[f] = true
+	}
+	comments, err := r.scm.ListLineComments(ctx, target.ChangeRequest)
+	if err != nil {
+		return nil, err
+	}
+	var results []*scanner.ScanResult
+	for _, c := range comments {
+		if !carriedFiles[c.Path] || !strings.Contains(c.Body, REVIEW_COMMENT_SIGNATURE) {
+			continue
+		}
+		result := &scanner.ScanResult{
+			File:      c.Path,
+			Line:      c.Line,
+			GitHubURL: c.URL,
+		}
+		result.ReviewComment, _, _ = strings.Cut(c.Body, "\n\n"+REVIEW_COMMENT_SIGNATURE)
+		if m := scanIDMarkerPattern.FindStringSubmatch(c.Body); m != nil {
+			result.ScanID = m[1]
+		}
+		if m := fingerprintPattern.FindStringSubmatch(c.Body); m != nil {
+			result.Fingerprint = m[1]
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
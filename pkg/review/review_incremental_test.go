@@ -0,0 +1,182 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v44/github"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListPRChangeFiles(t *testing.T) {
+	ctx := context.Background()
+	prFiles := []*github.CommitFile{
+		{Filename: github.String("main.go"), Status: github.String("modified")},
+		{Filename: github.String("util.go"), Status: github.String("added")},
+	}
+	changeFiles := []*scm.ChangeFile{
+		{Filename: "main.go", Status: "modified"},
+		{Filename: "util.go", Status: "added"},
+	}
+	truncatedFiles := []*github.CommitFile{{Filename: github.String("main.go"), Status: github.String("modified")}}
+	for i := len(truncatedFiles); i < COMPARE_MAX_FILES; i++ {
+		truncatedFiles = append(truncatedFiles, &github.CommitFile{Filename: github.String(fmt.Sprintf("merged_from_base_%d.go", i)), Status: github.String("modified")})
+	}
+	testCases := []struct {
+		name             string
+		baseSHA          string
+		compareFiles     []*github.CommitFile
+		compareErr       error
+		want             []*scm.ChangeFile
+		wantBaseSHA      string
+		wantCarriedFiles []string
+	}{
+		{
+			name: "All PR files",
+			want: changeFiles,
+		},
+		{
+			name:    "Incremental",
+			baseSHA: "before",
+			compareFiles: []*github.CommitFile{
+				{Filename: github.String("main.go"), Status: github.String("modified")},
+				{Filename: github.String("merged_from_base.go"), Status: github.String("modified")},
+			},
+			want:             []*scm.ChangeFile{changeFiles[0]},
+			wantBaseSHA:      "before",
+			wantCarriedFiles: []string{"util.go"},
+		},
+		{
+			name:         "Fallback to all PR files (truncated compare)",
+			baseSHA:      "before",
+			compareFiles: truncatedFiles,
+			want:         changeFiles,
+		},
+		{
+			name:       "Fallback to all PR files (force push)",
+			baseSHA:    "before",
+			compareErr: errors.New("404 Not Found"),
+			want:       changeFiles,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockClient := mocks.NewGitHubClient(t)
+			mockClient.
+				On("ListFiles", ctx, "owner", "repo", 1, mock.Anything).
+				Return(prFiles, &github.Response{}, nil).Once()
+			if tc.baseSHA != "" {
+				mockClient.On("CompareCommits", ctx, "owner", "repo", tc.baseSHA, "after").Return(tc.compareFiles, tc.compareErr).Once()
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{},
+				githubClient: mockClient,
+				scm:          &githubProvider{client: mockClient},
+				logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+			}
+			target := &reviewTarget{
+				Event: EVENT_PULL_REQUEST, Owner: "owner", RepoName: "repo", HeadSHA: "after", BaseSHA: tc.baseSHA,
+				PR:            &GithubPREvent{Number: 1, Owner: "owner", RepoName: "repo"},
+				ChangeRequest: &scm.ChangeRequest{Number: 1, HeadSHA: "after", Repository: &scm.Repository{FullName: "owner/repo"}},
+			}
+			got, err := r.listPRChangeFiles(ctx, target)
+			if err != nil {
+				t.Fatalf("listPRChangeFiles() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("listPRChangeFiles() mismatch (-want +got):\n%s", diff)
+			}
+			if target.BaseSHA != tc.wantBaseSHA {
+				t.Errorf("listPRChangeFiles() BaseSHA = %s, want %s", target.BaseSHA, tc.wantBaseSHA)
+			}
+			if diff := cmp.Diff(tc.wantCarriedFiles, target.CarriedFiles); diff != "" {
+				t.Errorf("listPRChangeFiles() CarriedFiles mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCarryForwardFindings(t *testing.T) {
+	ctx := context.Background()
+	comments := []*github.PullRequestComment{
+		{
+			Path: github.String("util.go"), Line: github.Int(3), HTMLURL: github.String("https://github.com/owner/repo/pull/1#discussion_r1"),
+			Body: github.String(generatePRReviewComment(&scanner.ScanResult{ScanID: "rule1", Fingerprint: "abc123", ReviewComment: "review comment"})),
+		},
+		{
+			Path: github.String("legacy.go"), Line: github.Int(5), HTMLURL: github.String("https://github.com/owner/repo/pull/1#discussion_r2"),
+			Body: github.String("legacy comment\n\n_By RISKEN review_"),
+		},
+		{
+			Path: github.String("util.go"), Line: nil, // outdated
+			Body: github.String(generatePRReviewComment(&scanner.ScanResult{ScanID: "rule2", ReviewComment: "outdated"})),
+		},
+		{
+			Path: github.String("util.go"), Line: github.Int(4),
+			Body: github.String("LGTM"), // not a review comment
+		},
+		{
+			Path: github.String("main.go"), Line: github.Int(1), // rescanned
+			Body: github.String(generatePRReviewComment(&scanner.ScanResult{ScanID: "rule3", ReviewComment: "rescanned"})),
+		},
+	}
+	testCases := []struct {
+		name         string
+		carriedFiles []string
+		commentsErr  error
+		want         []*scanner.ScanResult
+		wantErr      bool
+	}{
+		{
+			name: "No carried files",
+		},
+		{
+			name:         "Carry forward",
+			carriedFiles: []string{"util.go", "legacy.go"},
+			want: []*scanner.ScanResult{
+				{ScanID: "rule1", Fingerprint: "abc123", File: "util.go", Line: 3, ReviewComment: "review comment", GitHubURL: "https://github.com/owner/repo/pull/1#discussion_r1"},
+				{File: "legacy.go", Line: 5, ReviewComment: "legacy comment", GitHubURL: "https://github.com/owner/repo/pull/1#discussion_r2"},
+			},
+		},
+		{
+			name:         "Get comments error",
+			carriedFiles: []string{"util.go"},
+			commentsErr:  errors.New("500 Internal Server Error"),
+			wantErr:      true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockClient := mocks.NewGitHubClient(t)
+			if len(tc.carriedFiles) > 0 {
+				mockClient.On("GetAllPRComments", ctx, "owner", "repo", 1).Return(comments, tc.commentsErr).Once()
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{},
+				githubClient: mockClient,
+				scm:          &githubProvider{client: mockClient},
+				logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+			}
+			target := &reviewTarget{
+				Owner: "owner", RepoName: "repo", CarriedFiles: tc.carriedFiles,
+				PR:            &GithubPREvent{Number: 1, Owner: "owner", RepoName: "repo"},
+				ChangeRequest: &scm.ChangeRequest{Number: 1, Repository: &scm.Repository{FullName: "owner/repo"}},
+			}
+			got, err := r.carryForwardFindings(ctx, target)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("carryForwardFindings() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("carryForwardFindings() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/verify"
+)
+
+// reviewOutput is the result file uploaded as the workflow artifact.
+type reviewOutput struct {
+	Event      string           `json:"event"`
+	Repository string           `json:"repository"`
+	HeadSHA    string           `json:"head_sha"`
+	BaseSHA    string           `json:"base_sha,omitempty"`
+	Findings   []*outputFinding `json:"findings"`
+}
+
+type outputFinding struct {
+	ScanID        string         `json:"scan_id"`
+	Fingerprint   string         `json:"fingerprint,omitempty"`
+	Scanner       string         `json:"scanner,omitempty"`  // empty for the findings carried forward from the PR comments
+	Severity      string         `json:"severity,omitempty"` // empty for the findings carried forward from the PR comments
+	File          string         `json:"file"`
+	Line          int            `json:"line"`
+	GitHubURL     string         `json:"github_url"`
+	RiskenURL     string         `json:"risken_url,omitempty"`
+	RiskenStatus  string         `json:"risken_status,omitempty"`
+	RiskenTriage  string         `json:"risken_triage,omitempty"`
+	ReviewComment string         `json:"review_comment"`
+	Verification  *verify.Result `json:"verification,omitempty"`
+}
+
+func writeOutput(path string, target *reviewTarget, scanResults []*scanner.ScanResult) error {
+	output := &reviewOutput{
+		Event:      target.Event,
+		Repository: target.Repository.FullName,
+		HeadSHA:    target.HeadSHA,
+		BaseSHA:    target.BaseSHA,
+		Findings:   []*outputFinding{},
+	}
+	for _, result := range scanResults {
+		f := &outputFinding{
+			ScanID:        result.ScanID,
+			Fingerprint:   result.Fingerprint,
+			File:          result.File,
+			Line:          result.Line,
+			GitHubURL:     result.GitHubURL,
+			RiskenURL:     result.RiskenURL,
+			RiskenStatus:  result.RiskenStatus,
+			RiskenTriage:  result.RiskenTriage,
+			ReviewComment: result.ReviewComment,
+			Severity:      result.Severity(),
+			Verification:  result.Verification,
+		}
+		if result.Finding != nil {
+			f.Scanner = result.Finding.Scanner()
+		}
+		output.Findings = append(output.Findings, f)
+	}
+	content, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: path=%s, err=%w", path, err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write output: path=%s, err=%w", path, err)
+	}
+	return nil
+}
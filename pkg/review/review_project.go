@@ -0,0 +1,164 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/go-risken"
+	"github.com/ca-risken/security-review/pkg/scanner"
+)
+
+// riskenProjectRoute maps the findings of the repository (and the path prefix in the monorepo) to the RISKEN project.
+type riskenProjectRoute struct {
+	Repository string // glob pattern of owner/repo, e.g. owner/*
+	PathPrefix string // directory of the files, all the files if empty
+	ProjectID  uint32
+}
+
+// parseRiskenProjectRoutes parses the routes in the form of <repository>[:<path prefix>]=<project ID>, e.g. owner/mono:services/payment=2.
+func parseRiskenProjectRoutes(routes []string) ([]*riskenProjectRoute, error) {
+	var parsed []*riskenProjectRoute
+	for _, route := range routes {
+		pattern, id, ok := cutLast(route, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid RISKEN project route (<repository>[:<path prefix>]=<project ID>): %s", route)
+		}
+		projectID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil || projectID == 0 {
+			return nil, fmt.Errorf("invalid RISKEN project ID: route=%s", route)
+		}
+		repository, prefix, _ := strings.Cut(pattern, ":")
+		if _, err := path.Match(repository, ""); err != nil {
+			return nil, fmt.Errorf("invalid repository pattern: route=%s, err=%w", route, err)
+		}
+		parsed = append(parsed, &riskenProjectRoute{
+			Repository: repository,
+			PathPrefix: strings.Trim(prefix, "/"),
+			ProjectID:  uint32(projectID),
+		})
+	}
+	return parsed, nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func (p *riskenProjectRoute) matchRepository(repository string) bool {
+	ok, _ := path.Match(p.Repository, repository)
+	return ok
+}
+
+func (p *riskenProjectRoute) match(repository, file string) bool {
+	if !p.matchRepository(repository) {
+		return false
+	}
+	return p.PathPrefix == "" || file == p.PathPrefix || strings.HasPrefix(file, p.PathPrefix+"/")
+}
+
+// riskenProjects selects the RISKEN project of the findings by the routes, the first matched route wins.
+// The default project is --risken-project-id, or the project of the API token. It is 0 if unknown (the signin failed).
+type riskenProjects struct {
+	defaultID uint32
+	routes    []*riskenProjectRoute
+}
+
+func (p *riskenProjects) projectID(repository, file string) uint32 {
+	for _, route := range p.routes {
+		if route.match(repository, file) {
+			return route.ProjectID
+		}
+	}
+	return p.defaultID
+}
+
+// repositoryProjects returns the projects which may have the findings of the repository, to resolve the stale findings in all of them.
+func (p *riskenProjects) repositoryProjects(repository string) []uint32 {
+	ids := []uint32{p.defaultID}
+	for _, route := range p.routes {
+		if route.matchRepository(repository) && !slices.Contains(ids, route.ProjectID) {
+			ids = append(ids, route.ProjectID)
+		}
+	}
+	return ids
+}
+
+// allProjects returns the default project and the projects of all the routes.
+func (p *riskenProjects) allProjects() []uint32 {
+	ids := []uint32{p.defaultID}
+	for _, route := range p.routes {
+		if !slices.Contains(ids, route.ProjectID) {
+			ids = append(ids, route.ProjectID)
+		}
+	}
+	return ids
+}
+
+// group groups the scan results by the project, in the order of the first appearance.
+func (p *riskenProjects) group(repository string, scanResults []*scanner.ScanResult) ([]uint32, map[uint32][]*scanner.ScanResult) {
+	var ids []uint32
+	groups := map[uint32][]*scanner.ScanResult{}
+	for _, result := range scanResults {
+		id := p.projectID(repository, result.File)
+		if _, ok := groups[id]; !ok {
+			ids = append(ids, id)
+		}
+		groups[id] = append(groups[id], result)
+	}
+	return ids, groups
+}
+
+// getRiskenProjects returns the projects, signing in to RISKEN if --risken-project-id is not set.
+func (r *reviewService) getRiskenProjects(ctx context.Context) (*riskenProjects, error) {
+	if r.projects != nil {
+		return r.projects, nil
+	}
+	defaultID := r.opt.RiskenProjectID
+	if defaultID == 0 {
+		projectID, err := r.getProjectID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get project ID: %w", err)
+		}
+		defaultID = *projectID
+	}
+	r.projects = &riskenProjects{defaultID: defaultID, routes: r.projectRoutes}
+	return r.projects, nil
+}
+
+// validateRiskenProjects checks that the API token has access to all the projects before the review.
+// The transient errors are not returned, so that the review continues (and the findings are spooled) on the RISKEN outage.
+func (r *reviewService) validateRiskenProjects(ctx context.Context) error {
+	projects, err := r.getRiskenProjects(ctx)
+	if err == nil {
+		for _, projectID := range projects.allProjects() {
+			if _, err = r.riskenClient.ListFinding(ctx, &finding.ListFindingRequest{ProjectId: projectID, ToScore: 1.0, Limit: 1}); err != nil {
+				err = fmt.Errorf("failed to access RISKEN project: project_id=%d, err=%w", projectID, err)
+				break
+			}
+		}
+	}
+	if err == nil {
+		r.logger.InfoContext(ctx, "RISKEN projects", slog.Any("project_ids", projects.allProjects()))
+		return nil
+	}
+	if isRetryableRiskenError(err) {
+		r.logger.WarnContext(ctx, "Failed to validate RISKEN projects, continue the review", slog.String("err", err.Error()))
+		return nil
+	}
+	var apiErr risken.APIError
+	if errors.As(err, &apiErr) && (apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden) {
+		return fmt.Errorf("RISKEN API token has no access to the project: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package review
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/go-risken"
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseRiskenProjectRoutes(t *testing.T) {
+	testCases := []struct {
+		name    string
+		routes  []string
+		want    []*riskenProjectRoute
+		wantErr bool
+	}{
+		{
+			name:   "OK",
+			routes: []string{"owner/mono:services/payment/=2", "owner/*=3"},
+			want: []*riskenProjectRoute{
+				{Repository: "owner/mono", PathPrefix: "services/payment", ProjectID: 2},
+				{Repository: "owner/*", ProjectID: 3},
+			},
+		},
+		{name: "No routes"},
+		{name: "No project ID", routes: []string{"owner/repo"}, wantErr: true},
+		{name: "Invalid project ID", routes: []string{"owner/repo=0"}, wantErr: true},
+		{name: "No repository", routes: []string{"=1"}, wantErr: true},
+		{name: "Invalid pattern", routes: []string{"owner/[repo=1"}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseRiskenProjectRoutes(tc.routes)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseRiskenProjectRoutes() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseRiskenProjectRoutes() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRiskenProjects(t *testing.T) {
+	routes, err := parseRiskenProjectRoutes([]string{"owner/mono:services/payment=2", "owner/mono:services=3", "owner/*=4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &riskenProjects{defaultID: 1, routes: routes}
+	testCases := []struct {
+		repository string
+		file       string
+		want       uint32
+	}{
+		{repository: "owner/mono", file: "services/payment/main.go", want: 2},
+		{repository: "owner/mono", file: "services/payment-v2/main.go", want: 3}, // not the directory
+		{repository: "owner/mono", file: "README.md", want: 4},
+		{repository: "owner/repo", file: "services/payment/main.go", want: 4},
+		{repository: "other/repo", file: "main.go", want: 1},
+	}
+	for _, tc := range testCases {
+		if got := p.projectID(tc.repository, tc.file); got != tc.want {
+			t.Errorf("projectID(%s, %s) = %d, want %d", tc.repository, tc.file, got, tc.want)
+		}
+	}
+	if diff := cmp.Diff([]uint32{1, 2, 3, 4}, p.repositoryProjects("owner/mono")); diff != "" {
+		t.Errorf("repositoryProjects() mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]uint32{1}, p.repositoryProjects("other/repo")); diff != "" {
+		t.Errorf("repositoryProjects() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateRiskenProjects(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name        string
+		projectID   uint32
+		listErr     error
+		wantErr     bool
+		wantProject uint32
+	}{
+		{name: "Project of the token", wantProject: 1},
+		{name: "Explicit project", projectID: 5, wantProject: 5},
+		{name: "No access", projectID: 5, listErr: risken.APIError{Status: http.StatusForbidden}, wantErr: true},
+		{name: "Transient error", projectID: 5, listErr: risken.APIError{Status: http.StatusServiceUnavailable}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mocks.NewRiskenClient(t)
+			if tc.projectID == 0 {
+				m.On("Signin", ctx).Return(&risken.SigninResponse{ProjectID: 1}, nil).Once()
+			}
+			m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool {
+				return req.ProjectId == tc.wantProject || tc.listErr != nil
+			})).
+				Return(&finding.ListFindingResponse{}, tc.listErr).Once()
+			m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool { return req.ProjectId == 9 })).
+				Return(&finding.ListFindingResponse{}, nil).Maybe()
+			r := &reviewService{
+				opt:           &ReviewOption{RiskenProjectID: tc.projectID},
+				riskenClient:  m,
+				projectRoutes: []*riskenProjectRoute{{Repository: "owner/mono", PathPrefix: "app", ProjectID: 9}},
+				logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			if err := r.validateRiskenProjects(ctx); (err != nil) != tc.wantErr {
+				t.Fatalf("validateRiskenProjects() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.listErr == nil {
+				m.AssertNumberOfCalls(t, "ListFinding", 2)
+			}
+			if tc.wantProject != 0 && r.projects.defaultID != tc.wantProject {
+				t.Errorf("default project = %d, want %d", r.projects.defaultID, tc.wantProject)
+			}
+		})
+	}
+}
+
+func TestIntegrateRiskenRouting(t *testing.T) {
+	ctx := context.Background()
+	m := mocks.NewRiskenClient(t)
+	putProject := func(projectID uint32, file string) any {
+		return mock.MatchedBy(func(req *finding.PutFindingRequest) bool {
+			return req.ProjectId == projectID && req.Finding.ResourceName == "owner/mono" && req.Finding.DataSourceId == file
+		})
+	}
+	m.On("PutFinding", ctx, putProject(1, "fp-root")).Return(&finding.PutFindingResponse{Finding: &finding.Finding{FindingId: 10, ProjectId: 1}}, nil).Once()
+	m.On("PutFinding", ctx, putProject(2, "fp-payment")).Return(&finding.PutFindingResponse{Finding: &finding.Finding{FindingId: 20, ProjectId: 2}}, nil).Once()
+	m.On("PutRecommend", ctx, mock.Anything).Return(&finding.PutRecommendResponse{}, nil).Twice()
+	m.On("TagFinding", ctx, mock.Anything).Return(&finding.TagFindingResponse{}, nil)
+	// Triage lookup by project
+	for _, projectID := range []uint32{1, 2} {
+		m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool {
+			return req.ProjectId == projectID && req.Status == finding.FindingStatus_FINDING_PENDING
+		})).Return(&finding.ListFindingResponse{}, nil).Once()
+	}
+	// Resolution in both projects: the finding 11 moved to the project 2 is resolved in the project 1
+	m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool {
+		return req.ProjectId == 1 && req.Status != finding.FindingStatus_FINDING_PENDING
+	})).Return(&finding.ListFindingResponse{FindingId: []uint64{10, 11}, Total: 2}, nil).Once()
+	m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool {
+		return req.ProjectId == 2 && req.Status != finding.FindingStatus_FINDING_PENDING
+	})).Return(&finding.ListFindingResponse{FindingId: []uint64{20}, Total: 1}, nil).Once()
+	m.On("GetFinding", ctx, mock.MatchedBy(func(req *finding.GetFindingRequest) bool { return req.ProjectId == 1 && req.FindingId == 11 })).
+		Return(&finding.GetFindingResponse{Finding: &finding.Finding{FindingId: 11, ProjectId: 1, DataSource: "code:codescan", DataSourceId: "fp-payment", Data: `{"fingerprint":"fp-payment"}`, OriginalScore: 0.5}}, nil).Once()
+	m.On("PutFinding", ctx, mock.MatchedBy(func(req *finding.PutFindingRequest) bool { return req.ProjectId == 1 && req.Finding.OriginalScore == 0 })).
+		Return(&finding.PutFindingResponse{Finding: &finding.Finding{FindingId: 11, ProjectId: 1}}, nil).Once()
+
+	r := &reviewService{
+		opt:           &ReviewOption{RiskenProjectID: 1},
+		riskenClient:  m,
+		projectRoutes: []*riskenProjectRoute{{Repository: "owner/mono", PathPrefix: "services/payment", ProjectID: 2}},
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	result := func(file, fingerprint string) *scanner.ScanResult {
+		return &scanner.ScanResult{ScanID: "rule", File: file, Fingerprint: fingerprint, Finding: &scanner.RuleFinding{RuleID: "rule", Repository: "owner/mono"}}
+	}
+	results := []*scanner.ScanResult{result("main.go", "fp-root"), result("services/payment/main.go", "fp-payment")}
+	target := &reviewTarget{Full: true, Ref: "refs/heads/main", Repository: &scm.Repository{FullName: "owner/mono", DefaultBranch: "main"}}
+	if err := r.integrateRisken(ctx, target, results, nil); err != nil {
+		t.Fatalf("integrateRisken() error = %v", err)
+	}
+	if diff := cmp.Diff(reviewSummary{RiskenUploaded: 2, Resolved: 1}, r.summary); diff != "" {
+		t.Errorf("summary mismatch (-want +got):\n%s", diff)
+	}
+}
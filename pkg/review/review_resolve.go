@@ -0,0 +1,174 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/datasource-api/pkg/message"
+	"github.com/ca-risken/security-review/pkg/scanner"
+)
+
+// resolvableDataSources are the data sources of which the findings are resolved by the full scan.
+// Secrets (code:gitleaks) are excluded, because they remain in the git history until the key is rotated.
+var resolvableDataSources = []string{
+	message.CodeScanDataSource,
+	message.DependencyDataSource,
+	scanner.IAC_DATA_SOURCE,
+}
+
+const listFindingLimit = 200
+
+// activeFindings are the findings put in the current run.
+// The data source IDs identify the findings on the dry run, which returns the dummy finding IDs.
+type activeFindings struct {
+	findingIDs    map[uint64]bool
+	dataSourceIDs map[string]bool
+	fingerprints  map[string]bool // the findings carried forward without scanning, not put in the current run
+}
+
+func newActiveFindings() *activeFindings {
+	return &activeFindings{findingIDs: map[uint64]bool{}, dataSourceIDs: map[string]bool{}, fingerprints: map[string]bool{}}
+}
+
+func (a *activeFindings) addFingerprint(fingerprint string) {
+	if fingerprint != "" {
+		a.fingerprints[fingerprint] = true
+	}
+}
+
+func (a *activeFindings) add(f *finding.Finding) {
+	a.findingIDs[f.FindingId] = true
+	a.dataSourceIDs[f.DataSource+"/"+f.DataSourceId] = true
+}
+
+func (a *activeFindings) contains(f *finding.Finding) bool {
+	return a.findingIDs[f.FindingId] || a.dataSourceIDs[f.DataSource+"/"+f.DataSourceId] || a.fingerprints[f.DataSourceId]
+}
+
+// isOwnedFinding returns true if the finding is put by this tool, which has the fingerprint in the finding data.
+// The other findings of the same data sources (e.g. the RISKEN code scanner) are not resolved by the review.
+func isOwnedFinding(f *finding.Finding) bool {
+	var data struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+	if err := json.Unmarshal([]byte(f.Data), &data); err != nil {
+		return false
+	}
+	return data.Fingerprint != "" && data.Fingerprint == f.DataSourceId
+}
+
+// findingResolution is set to the finding data of the resolved finding, to track the remediation in RISKEN.
+type findingResolution struct {
+	Note          string `json:"note"`
+	Commit        string `json:"commit"`
+	ChangeRequest string `json:"change_request,omitempty"` // e.g. owner/repo#1
+	URL           string `json:"url,omitempty"`
+}
+
+func newFindingResolution(target *reviewTarget) *findingResolution {
+	if cr := target.ChangeRequest; cr != nil {
+		return &findingResolution{
+			Note:          fmt.Sprintf("Fixed in %s at %s", cr.String(), target.HeadSHA),
+			Commit:        target.HeadSHA,
+			ChangeRequest: cr.String(),
+			URL:           cr.URL,
+		}
+	}
+	return &findingResolution{
+		Note:   fmt.Sprintf("No longer detected at %s", target.HeadSHA),
+		Commit: target.HeadSHA,
+	}
+}
+
+// resolveStaleFindings resolves (sets the score to 0) the findings of the repository which are no longer detected.
+// The findings are narrowed down by the tags (all the findings of the repository if empty), e.g. the findings reported on the PR,
+// and the findings with any of the kept tags are not resolved, e.g. the findings reported on the default branch.
+func (r *reviewService) resolveStaleFindings(ctx context.Context, projectID uint32, repository string, tags, keptTags []string, active *activeFindings, resolution *findingResolution) (int, error) {
+	findingIDs, err := r.listUnresolvedFindings(ctx, projectID, repository, tags)
+	if err != nil {
+		return 0, err
+	}
+	kept := map[uint64]bool{}
+	for _, tag := range keptTags {
+		ids, err := r.listUnresolvedFindings(ctx, projectID, repository, []string{tag})
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			kept[id] = true
+		}
+	}
+	var staleFindingIDs []uint64
+	for _, id := range findingIDs {
+		if !active.findingIDs[id] && !kept[id] {
+			staleFindingIDs = append(staleFindingIDs, id)
+		}
+	}
+
+	resolved := 0
+	for _, id := range staleFindingIDs {
+		resp, err := r.riskenClient.GetFinding(ctx, &finding.GetFindingRequest{ProjectId: projectID, FindingId: id})
+		if err != nil {
+			return resolved, fmt.Errorf("failed to get finding: finding_id=%d, err=%w", id, err)
+		}
+		f := resp.Finding
+		if f == nil || f.OriginalScore == 0 || active.contains(f) || !isOwnedFinding(f) {
+			continue
+		}
+		req := &finding.PutFindingRequest{
+			ProjectId: projectID,
+			Finding: &finding.FindingForUpsert{
+				Description:      f.Description,
+				DataSource:       f.DataSource,
+				DataSourceId:     f.DataSourceId,
+				ResourceName:     f.ResourceName,
+				ProjectId:        projectID,
+				OriginalScore:    0,
+				OriginalMaxScore: f.OriginalMaxScore,
+				Data:             f.Data,
+			},
+		}
+		if req.Finding.Data == "" {
+			req.Finding.Data = "{}"
+		}
+		if err := setFindingData(req, "resolution", resolution); err != nil {
+			return resolved, fmt.Errorf("failed to set resolution: finding_id=%d, err=%w", id, err)
+		}
+		if _, err := r.riskenClient.PutFinding(ctx, req); err != nil {
+			return resolved, fmt.Errorf("failed to resolve finding: finding_id=%d, err=%w", id, err)
+		}
+		r.logger.InfoContext(ctx, "Resolved finding", slog.Uint64("finding_id", id), slog.String("data_source", f.DataSource), slog.String("commit", resolution.Commit))
+		resolved++
+	}
+	return resolved, nil
+}
+
+// listUnresolvedFindings returns the IDs of the unresolved findings of the repository with the tags.
+func (r *reviewService) listUnresolvedFindings(ctx context.Context, projectID uint32, repository string, tags []string) ([]uint64, error) {
+	var findingIDs []uint64
+	var offset int32
+	for {
+		resp, err := r.riskenClient.ListFinding(ctx, &finding.ListFindingRequest{
+			ProjectId:    projectID,
+			DataSource:   resolvableDataSources,
+			ResourceName: []string{repository},
+			Tag:          tags,
+			FromScore:    0.01, // exclude the resolved findings
+			ToScore:      1.0,
+			Offset:       offset,
+			Limit:        listFindingLimit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list findings: repository=%s, tags=%v, err=%w", repository, tags, err)
+		}
+		findingIDs = append(findingIDs, resp.FindingId...)
+		offset += int32(len(resp.FindingId))
+		if len(resp.FindingId) == 0 || uint32(offset) >= resp.Total {
+			break
+		}
+	}
+	return findingIDs, nil
+}
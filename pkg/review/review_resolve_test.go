@@ -0,0 +1,163 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestResolveStaleFindings(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name        string
+		tags        []string
+		keptTags    []string
+		listResp    *finding.ListFindingResponse
+		keptResp    *finding.ListFindingResponse // the findings with the kept tags
+		listErr     error
+		findings    map[uint64]*finding.Finding
+		putErr      error
+		active      []*finding.Finding
+		carried     []string // fingerprints
+		wantPut     []uint64
+		wantResolve int
+		wantErr     bool
+	}{
+		{
+			name:     "No stale findings",
+			listResp: &finding.ListFindingResponse{FindingId: []uint64{1, 2}, Total: 2},
+			active:   []*finding.Finding{{FindingId: 1}, {FindingId: 2}},
+		},
+		{
+			name:     "Resolve stale findings",
+			listResp: &finding.ListFindingResponse{FindingId: []uint64{1, 2, 3}, Total: 3},
+			findings: map[uint64]*finding.Finding{
+				2: {FindingId: 2, DataSource: "code:codescan", DataSourceId: "ds2", ResourceName: "owner/repo", Data: `{"fingerprint":"ds2"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+				3: {FindingId: 3, DataSource: "code:dependency", DataSourceId: "ds3", ResourceName: "owner/repo", Data: `{"fingerprint":"ds3"}`, OriginalScore: 0, OriginalMaxScore: 1.0},
+			},
+			active:      []*finding.Finding{{FindingId: 1}},
+			wantPut:     []uint64{2},
+			wantResolve: 1,
+		},
+		{
+			name:     "Dry run (dummy finding IDs)",
+			listResp: &finding.ListFindingResponse{FindingId: []uint64{1, 2}, Total: 2},
+			findings: map[uint64]*finding.Finding{
+				1: {FindingId: 1, DataSource: "code:codescan", DataSourceId: "ds1", ResourceName: "owner/repo", Data: `{"fingerprint":"ds1"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+				2: {FindingId: 2, DataSource: "code:codescan", DataSourceId: "ds2", ResourceName: "owner/repo", Data: `{"fingerprint":"ds2"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+			},
+			active:      []*finding.Finding{{FindingId: math.MaxUint64, DataSource: "code:codescan", DataSourceId: "ds1"}},
+			wantPut:     []uint64{2},
+			wantResolve: 1,
+		},
+		{
+			name:     "Resolve findings of the PR",
+			tags:     []string{"pr:1"},
+			listResp: &finding.ListFindingResponse{FindingId: []uint64{1, 2, 3}, Total: 3},
+			findings: map[uint64]*finding.Finding{
+				2: {FindingId: 2, DataSource: "code:codescan", DataSourceId: "ds2", ResourceName: "owner/repo", Data: `{"fingerprint":"ds2"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+				3: {FindingId: 3, DataSource: "code:codescan", DataSourceId: "ds3", ResourceName: "owner/repo", Data: `{"fingerprint":"ds3"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+			},
+			active:      []*finding.Finding{{FindingId: 1}},
+			carried:     []string{"ds3"},
+			wantPut:     []uint64{2},
+			wantResolve: 1,
+		},
+		{
+			name:     "Keep findings of the PR reported on the default branch",
+			tags:     []string{"pr:1"},
+			keptTags: []string{"head_branch:main"},
+			listResp: &finding.ListFindingResponse{FindingId: []uint64{1, 2, 3}, Total: 3},
+			keptResp: &finding.ListFindingResponse{FindingId: []uint64{3, 4}, Total: 2},
+			findings: map[uint64]*finding.Finding{
+				2: {FindingId: 2, DataSource: "code:codescan", DataSourceId: "ds2", ResourceName: "owner/repo", Data: `{"fingerprint":"ds2"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+			},
+			active:      []*finding.Finding{{FindingId: 1}},
+			wantPut:     []uint64{2},
+			wantResolve: 1,
+		},
+		{
+			name:     "Skip findings not put by the review",
+			listResp: &finding.ListFindingResponse{FindingId: []uint64{1, 2, 3, 4}, Total: 4},
+			findings: map[uint64]*finding.Finding{
+				2: {FindingId: 2, DataSource: "code:codescan", DataSourceId: "ds2", ResourceName: "owner/repo", Data: `{"fingerprint":"ds2"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+				3: {FindingId: 3, DataSource: "code:codescan", DataSourceId: "risken-code-scan", ResourceName: "owner/repo", Data: `{"repository":"owner/repo"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+				4: {FindingId: 4, DataSource: "code:dependency", DataSourceId: "ds4", ResourceName: "owner/repo", OriginalScore: 0.6, OriginalMaxScore: 1.0},
+			},
+			active:      []*finding.Finding{{FindingId: 1}},
+			wantPut:     []uint64{2},
+			wantResolve: 1,
+		},
+		{
+			name:     "List error",
+			listResp: nil,
+			listErr:  errors.New("list error"),
+			wantErr:  true,
+		},
+		{
+			name:     "Put error",
+			listResp: &finding.ListFindingResponse{FindingId: []uint64{2}, Total: 1},
+			findings: map[uint64]*finding.Finding{
+				2: {FindingId: 2, DataSource: "code:codescan", DataSourceId: "ds2", ResourceName: "owner/repo", Data: `{"fingerprint":"ds2"}`, OriginalScore: 0.6, OriginalMaxScore: 1.0},
+			},
+			putErr:  errors.New("put error"),
+			wantPut: []uint64{2},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRiskenClient := mocks.NewRiskenClient(t)
+			mockRiskenClient.
+				On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool {
+					return req.ProjectId == 1 && cmp.Equal(req.ResourceName, []string{"owner/repo"}) && cmp.Equal(req.DataSource, resolvableDataSources) && cmp.Equal(req.Tag, tc.tags)
+				})).
+				Return(tc.listResp, tc.listErr).Once()
+			for _, tag := range tc.keptTags {
+				mockRiskenClient.
+					On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool { return cmp.Equal(req.Tag, []string{tag}) })).
+					Return(tc.keptResp, nil).Once()
+			}
+			for id, f := range tc.findings {
+				mockRiskenClient.
+					On("GetFinding", ctx, &finding.GetFindingRequest{ProjectId: 1, FindingId: id}).
+					Return(&finding.GetFindingResponse{Finding: f}, nil).Once()
+			}
+			for _, id := range tc.wantPut {
+				f := tc.findings[id]
+				mockRiskenClient.
+					On("PutFinding", ctx, mock.MatchedBy(func(req *finding.PutFindingRequest) bool {
+						return req.Finding.DataSourceId == f.DataSourceId && req.Finding.OriginalScore == 0 &&
+							req.Finding.Data == `{"fingerprint":"`+f.DataSourceId+`","resolution":{"note":"Fixed in owner/repo#1 at abc","commit":"abc","change_request":"owner/repo#1"}}`
+					})).
+					Return(&finding.PutFindingResponse{}, tc.putErr).Once()
+			}
+			r := &reviewService{
+				riskenClient: mockRiskenClient,
+				logger:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+			}
+			active := newActiveFindings()
+			for _, f := range tc.active {
+				active.add(f)
+			}
+			for _, fingerprint := range tc.carried {
+				active.addFingerprint(fingerprint)
+			}
+			got, err := r.resolveStaleFindings(ctx, 1, "owner/repo", tc.tags, tc.keptTags, active, &findingResolution{Note: "Fixed in owner/repo#1 at abc", Commit: "abc", ChangeRequest: "owner/repo#1"})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("resolveStaleFindings() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.wantResolve, got); diff != "" {
+				t.Errorf("resolveStaleFindings() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
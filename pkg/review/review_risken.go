@@ -2,12 +2,11 @@ package review
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 
-	"github.com/ca-risken/code/pkg/codescan"
-	"github.com/ca-risken/code/pkg/gitleaks"
 	"github.com/ca-risken/core/proto/finding"
-	"github.com/ca-risken/datasource-api/pkg/message"
 	"github.com/ca-risken/security-review/pkg/scanner"
 )
 
@@ -19,92 +18,200 @@ func (r *reviewService) getProjectID(ctx context.Context) (*uint32, error) {
 	return &signinResp.ProjectID, nil
 }
 
-func (r *reviewService) getPutFindingRequest(ctx context.Context, projectID uint32, s *scanner.ScanResult) (*finding.PutFindingRequest, error) {
-	var putReq *finding.PutFindingRequest
-	switch scanResult := s.ScanResult.(type) {
-	case *codescan.SemgrepFinding:
-		req, err := codescan.GeneratePutFindingRequest(projectID, scanResult)
-		if err != nil {
-			return nil, err
+// integrateRisken puts the findings to RISKEN, and resolves the findings no longer detected by the full scan of the default branch or the PR rescan.
+// The findings are put to the projects selected by the routes. The carried findings are not put, but kept unresolved.
+// It continues on the failures of the individual findings, and returns the error if any finding failed and was not spooled.
+func (r *reviewService) integrateRisken(ctx context.Context, target *reviewTarget, scanResults, carried []*scanner.ScanResult) error {
+	repository := target.Repository.FullName
+	uploader := newRiskenUploader(r, findingTags(target, r.opt.RiskenTags))
+	projects, err := r.getRiskenProjects(ctx)
+	if err != nil {
+		// The project of the API token (0) is set on flushing the spool
+		unknown := &riskenProjects{defaultID: r.opt.RiskenProjectID, routes: r.projectRoutes}
+		for _, result := range scanResults {
+			result.RiskenStatus = RISKEN_STATUS_FAILED
+			if uploader.spoolFinding(ctx, unknown.projectID(repository, result.File), result, err) {
+				result.RiskenStatus = RISKEN_STATUS_SPOOLED
+				r.summary.RiskenSpooled++
+				continue
+			}
+			r.summary.RiskenFailures++
 		}
-		putReq = req
-	case *gitleaks.GitleaksFinding:
-		req, err := gitleaks.GeneratePutFindingRequest(projectID, scanResult)
-		if err != nil {
-			return nil, err
+		if r.summary.RiskenFailures == 0 {
+			return nil
 		}
-		putReq = req
-	default:
-		return nil, fmt.Errorf("unknown scan result type: %T", scanResult)
+		return err
 	}
-	return putReq, nil
-}
-
-func (r *reviewService) getPutRecommendRequest(ctx context.Context, projectID uint32, findingID uint64, s *scanner.ScanResult) (*finding.PutRecommendRequest, error) {
-	var recReq *finding.PutRecommendRequest
-	switch scanResult := s.ScanResult.(type) {
-	case *codescan.SemgrepFinding:
-		recommendContent := codescan.GetSemgrepRecommend(
-			scanResult.Repository,
-			scanResult.Path,
-			scanResult.CheckID,
-			scanResult.Extra.Message,
-			scanResult.GitHubURL,
-			scanResult.Extra.Lines,
-		)
-		recReq = &finding.PutRecommendRequest{
-			ProjectId:      projectID,
-			FindingId:      findingID,
-			DataSource:     message.CodeScanDataSource,
-			Type:           codescan.GenerateDataSourceIDForSemgrep(scanResult),
-			Risk:           recommendContent.Risk,
-			Recommendation: recommendContent.Recommendation,
+	// The active findings by the project, to resolve the findings moved to the other project by the routes
+	active := map[uint32]*activeFindings{}
+	for _, projectID := range projects.repositoryProjects(repository) {
+		active[projectID] = newActiveFindings()
+	}
+	for _, result := range carried {
+		if a, ok := active[projects.projectID(repository, result.File)]; ok {
+			a.addFingerprint(result.Fingerprint)
 		}
-
-	case *gitleaks.GitleaksFinding:
-		recommendContent := gitleaks.GetRecommend(
-			scanResult.Result.RuleDescription,
-			scanResult.Result.Repo,
-			scanResult.Result.File,
-			*scanResult.Visibility,
-			scanResult.Result.URL,
-			scanResult.Result.Author,
-			scanResult.Result.Email,
-		)
-		recReq = &finding.PutRecommendRequest{
-			ProjectId:      projectID,
-			FindingId:      findingID,
-			DataSource:     message.GitleaksDataSource,
-			Type:           scanResult.Result.RuleDescription,
-			Risk:           recommendContent.Risk,
-			Recommendation: recommendContent.Recommendation,
+	}
+	projectIDs, groups := projects.group(repository, scanResults)
+	for _, projectID := range projectIDs {
+		results := groups[projectID]
+		findings := uploader.upload(ctx, projectID, results)
+		for i, f := range findings {
+			switch {
+			case f != nil:
+				active[projectID].add(f)
+				r.summary.RiskenUploaded++
+			case results[i].RiskenStatus == RISKEN_STATUS_SPOOLED:
+				r.summary.RiskenSpooled++
+			default:
+				r.summary.RiskenFailures++
+			}
 		}
+		if err := r.lookupRiskenTriage(ctx, projectID, repository, results, findings); err != nil {
+			r.logger.WarnContext(ctx, "Failed to look up triage status in RISKEN, comment on all findings", slog.String("err", err.Error()))
+		}
+	}
+	if r.summary.RiskenFailures > 0 {
+		// The failed findings would be resolved as they are not in the active findings
+		return fmt.Errorf("failed to put findings (skip resolving findings): failures=%d, findings=%d", r.summary.RiskenFailures, len(scanResults))
+	}
+	if r.summary.RiskenSpooled > 0 {
+		// Resolved on the next run after the spool is flushed
+		r.logger.InfoContext(ctx, "Skip resolving findings with spooled findings", slog.Int("spooled", r.summary.RiskenSpooled))
+		return nil
+	}
 
+	// フルスキャン、またはPRの再スキャンで検出されなくなったFindingを解決済みにする
+	var tags, keptTags []string
+	switch {
+	case target.Full:
+		if !target.isDefaultBranch() {
+			r.logger.InfoContext(ctx, "Skip resolving findings on non-default branch", slog.String("ref", target.Ref))
+			return nil
+		}
+		// The findings reported on the default branch, not the findings only on the PRs which still contain them
+		tags = []string{formatTag(TAG_HEAD_BRANCH, target.Repository.DefaultBranch)}
+	case target.ChangeRequest != nil:
+		if len(target.CarriedFiles) > 0 && r.opt.NoPRComment {
+			// The findings on the files not scanned are carried forward from the PR comments
+			r.logger.InfoContext(ctx, "Skip resolving findings on incremental review without PR comments")
+			return nil
+		}
+		// The findings reported on the PR
+		tags = []string{changeRequestTag(target.ChangeRequest)}
+		// The findings also reported on the default branch are resolved by the full scan after the merge,
+		// not to resolve the findings which remain on the default branch until then
+		keptTags = []string{formatTag(TAG_HEAD_BRANCH, target.Repository.DefaultBranch)}
 	default:
-		return nil, fmt.Errorf("unknown scan result type: %T", scanResult)
+		return nil
 	}
-	return recReq, nil
+	resolution := newFindingResolution(target)
+	for _, projectID := range projects.repositoryProjects(repository) {
+		resolved, err := r.resolveStaleFindings(ctx, projectID, repository, tags, keptTags, active[projectID], resolution)
+		r.summary.Resolved += resolved
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *reviewService) putFinding(ctx context.Context, projectID uint32, s *scanner.ScanResult) (*finding.PutFindingResponse, error) {
-	// Finding
-	putReq, err := r.getPutFindingRequest(ctx, projectID, s)
+func (r *reviewService) putFinding(ctx context.Context, projectID uint32, s *scanner.ScanResult, tags []string) (*finding.PutFindingResponse, error) {
+	sub, err := r.buildRiskenSubmission(projectID, s, tags)
 	if err != nil {
 		return nil, err
 	}
-	putResp, err := r.riskenClient.PutFinding(ctx, putReq)
+	return r.submitFinding(ctx, sub)
+}
+
+// riskenSubmission is the requests to put a finding to RISKEN, which is also the spooled entry.
+type riskenSubmission struct {
+	PutFinding   *finding.PutFindingRequest   `json:"put_finding"`
+	PutRecommend *finding.PutRecommendRequest `json:"put_recommend"` // the finding ID is set on the submission
+	Tags         []string                     `json:"tags,omitempty"`
+}
+
+// buildRiskenSubmission builds the requests with the score of the score policy.
+func (r *reviewService) buildRiskenSubmission(projectID uint32, s *scanner.ScanResult, tags []string) (*riskenSubmission, error) {
+	putReq, err := buildPutFindingRequest(projectID, s)
+	if err != nil {
+		return nil, &riskenRequestError{err: err}
+	}
+	if err := r.scorePolicy.apply(putReq, s); err != nil {
+		return nil, &riskenRequestError{err: err}
+	}
+	return &riskenSubmission{
+		PutFinding:   putReq,
+		PutRecommend: s.Finding.PutRecommendRequest(projectID, 0),
+		Tags:         tags,
+	}, nil
+}
+
+// submitFinding puts the finding, the recommendation and the tags.
+func (r *reviewService) submitFinding(ctx context.Context, sub *riskenSubmission) (*finding.PutFindingResponse, error) {
+	// Finding
+	projectID := sub.PutFinding.ProjectId
+	putResp, err := r.riskenClient.PutFinding(ctx, sub.PutFinding)
 	if err != nil {
 		return nil, err
 	}
 
 	// Recommendation
 	findingID := putResp.Finding.FindingId
-	recReq, err := r.getPutRecommendRequest(ctx, projectID, findingID, s)
+	sub.PutRecommend.FindingId = findingID
+	if _, err = r.riskenClient.PutRecommend(ctx, sub.PutRecommend); err != nil {
+		return nil, err
+	}
+
+	// Tags
+	for _, tag := range sub.Tags {
+		if _, err := r.riskenClient.TagFinding(ctx, &finding.TagFindingRequest{
+			ProjectId: projectID,
+			Tag:       &finding.FindingTagForUpsert{FindingId: findingID, ProjectId: projectID, Tag: tag},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to tag finding: tag=%s, err=%w", tag, err)
+		}
+	}
+	return putResp, nil
+}
+
+func buildPutFindingRequest(projectID uint32, s *scanner.ScanResult) (*finding.PutFindingRequest, error) {
+	if s.Finding == nil {
+		return nil, fmt.Errorf("no finding in the scan result: scan_id=%s", s.ScanID)
+	}
+	putReq, err := s.Finding.PutFindingRequest(projectID)
 	if err != nil {
 		return nil, err
 	}
-	if _, err = r.riskenClient.PutRecommend(ctx, recReq); err != nil {
+	if err := applyFingerprint(putReq, s.Fingerprint); err != nil {
 		return nil, err
 	}
-	return putResp, nil
+	if err := applyVerification(putReq, s); err != nil {
+		return nil, err
+	}
+	return putReq, nil
+}
+
+// applyFingerprint identifies the finding by the fingerprint, so that the line shifts do not create the new finding.
+func applyFingerprint(req *finding.PutFindingRequest, fingerprint string) error {
+	if fingerprint == "" {
+		return nil
+	}
+	req.Finding.DataSourceId = fingerprint
+	return setFindingData(req, "fingerprint", fingerprint)
+}
+
+// setFindingData sets the value to the finding data (JSON object).
+func setFindingData(req *finding.PutFindingRequest, key string, value any) error {
+	data := map[string]any{}
+	if err := json.Unmarshal([]byte(req.Finding.Data), &data); err != nil {
+		return fmt.Errorf("failed to unmarshal finding data: err=%w", err)
+	}
+	data[key] = value
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal finding data: err=%w", err)
+	}
+	req.Finding.Data = string(buf)
+	return nil
 }
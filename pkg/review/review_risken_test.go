@@ -3,6 +3,7 @@ package review
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/ca-risken/code/pkg/codescan"
@@ -74,6 +75,7 @@ func TestPutFinding(t *testing.T) {
 	type Args struct {
 		projectID  uint32
 		scanResult *scanner.ScanResult
+		tags       []string
 	}
 
 	type testCase struct {
@@ -97,7 +99,7 @@ func TestPutFinding(t *testing.T) {
 					ReviewComment: "reviewComment",
 					GitHubURL:     "githubURL",
 					RiskenURL:     "riskenURL",
-					ScanResult: &codescan.SemgrepFinding{
+					Finding: &scanner.SemgrepFinding{SemgrepFinding: &codescan.SemgrepFinding{
 						Repository:     "repository",
 						RepoVisibility: "public",
 						Path:           "path",
@@ -116,7 +118,7 @@ func TestPutFinding(t *testing.T) {
 							Message:  "message",
 							Lines:    "lines",
 						},
-					},
+					}},
 				},
 			},
 			setupMock: func(m *mocks.RiskenClient) {
@@ -137,11 +139,133 @@ func TestPutFinding(t *testing.T) {
 		},
 
 		{
-			name: "Error Unknown ScanResult Type",
+			name: "OK (Dependency)",
 			args: &Args{
 				projectID: 123,
 				scanResult: &scanner.ScanResult{
-					ScanResult: `{"test":true}`,
+					ScanID:      "CVE-2023-0001",
+					Fingerprint: "fingerprint",
+					File:        "go.mod",
+					Line:        4,
+					Finding: &scanner.DependencyFinding{
+						Repository:      "owner/repo",
+						Path:            "go.mod",
+						Line:            4,
+						Ecosystem:       "Go",
+						PackageName:     "example.com/vuln",
+						Version:         "v1.1.0",
+						VulnerabilityID: "CVE-2023-0001",
+						AdvisoryID:      "GO-2023-0001",
+						Level:           "HIGH",
+						FixedVersion:    "1.2.0",
+					},
+				},
+			},
+			setupMock: func(m *mocks.RiskenClient) {
+				m.On("PutFinding", ctx, mock.MatchedBy(func(req *finding.PutFindingRequest) bool {
+					return req.Finding.DataSource == "code:dependency" && req.Finding.OriginalScore == 0.5 &&
+						req.Finding.DataSourceId == "fingerprint" && strings.Contains(req.Finding.Data, `"fingerprint":"fingerprint"`)
+				})).
+					Return(&finding.PutFindingResponse{
+						Finding: &finding.Finding{FindingId: 1},
+					}, nil).Once()
+				m.On("PutRecommend", ctx, mock.Anything).
+					Return(&finding.PutRecommendResponse{
+						Recommend: &finding.Recommend{RecommendId: 1},
+					}, nil).
+					Once()
+			},
+			want: &finding.PutFindingResponse{
+				Finding: &finding.Finding{FindingId: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "OK (Tags)",
+			args: &Args{
+				projectID: 123,
+				scanResult: &scanner.ScanResult{
+					ScanID:      "CVE-2023-0001",
+					Fingerprint: "fingerprint",
+					File:        "go.mod",
+					Line:        4,
+					Finding: &scanner.DependencyFinding{
+						Repository:      "owner/repo",
+						Path:            "go.mod",
+						Line:            4,
+						Ecosystem:       "Go",
+						PackageName:     "example.com/vuln",
+						Version:         "v1.1.0",
+						VulnerabilityID: "CVE-2023-0001",
+						AdvisoryID:      "GO-2023-0001",
+						Level:           "HIGH",
+						FixedVersion:    "1.2.0",
+					},
+				},
+				tags: []string{"repository:owner/repo", "pr:1"},
+			},
+			setupMock: func(m *mocks.RiskenClient) {
+				m.On("PutFinding", ctx, mock.Anything).
+					Return(&finding.PutFindingResponse{
+						Finding: &finding.Finding{FindingId: 1},
+					}, nil).Once()
+				m.On("PutRecommend", ctx, mock.Anything).
+					Return(&finding.PutRecommendResponse{}, nil).Once()
+				for _, tag := range []string{"repository:owner/repo", "pr:1"} {
+					m.On("TagFinding", ctx, &finding.TagFindingRequest{
+						ProjectId: 123,
+						Tag:       &finding.FindingTagForUpsert{FindingId: 1, ProjectId: 123, Tag: tag},
+					}).Return(&finding.TagFindingResponse{}, nil).Once()
+				}
+			},
+			want: &finding.PutFindingResponse{
+				Finding: &finding.Finding{FindingId: 1},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Error TagFinding",
+			args: &Args{
+				projectID: 123,
+				scanResult: &scanner.ScanResult{
+					ScanID:      "CVE-2023-0001",
+					Fingerprint: "fingerprint",
+					File:        "go.mod",
+					Line:        4,
+					Finding: &scanner.DependencyFinding{
+						Repository:      "owner/repo",
+						Path:            "go.mod",
+						Line:            4,
+						Ecosystem:       "Go",
+						PackageName:     "example.com/vuln",
+						Version:         "v1.1.0",
+						VulnerabilityID: "CVE-2023-0001",
+						AdvisoryID:      "GO-2023-0001",
+						Level:           "HIGH",
+						FixedVersion:    "1.2.0",
+					},
+				},
+				tags: []string{"repository:owner/repo"},
+			},
+			setupMock: func(m *mocks.RiskenClient) {
+				m.On("PutFinding", ctx, mock.Anything).
+					Return(&finding.PutFindingResponse{
+						Finding: &finding.Finding{FindingId: 1},
+					}, nil).Once()
+				m.On("PutRecommend", ctx, mock.Anything).
+					Return(&finding.PutRecommendResponse{}, nil).Once()
+				m.On("TagFinding", ctx, mock.Anything).
+					Return(nil, errors.New("tag finding error")).Once()
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Error No Finding",
+			args: &Args{
+				projectID: 123,
+				scanResult: &scanner.ScanResult{
+					ScanID: "scanID",
 				},
 			},
 			setupMock: func(m *mocks.RiskenClient) {},
@@ -160,7 +284,7 @@ func TestPutFinding(t *testing.T) {
 					ReviewComment: "reviewComment",
 					GitHubURL:     "githubURL",
 					RiskenURL:     "riskenURL",
-					ScanResult: &codescan.SemgrepFinding{
+					Finding: &scanner.SemgrepFinding{SemgrepFinding: &codescan.SemgrepFinding{
 						Repository:     "repository",
 						RepoVisibility: "public",
 						Path:           "path",
@@ -179,7 +303,7 @@ func TestPutFinding(t *testing.T) {
 							Message:  "message",
 							Lines:    "lines",
 						},
-					},
+					}},
 				},
 			},
 			setupMock: func(m *mocks.RiskenClient) {
@@ -202,7 +326,7 @@ func TestPutFinding(t *testing.T) {
 					ReviewComment: "reviewComment",
 					GitHubURL:     "githubURL",
 					RiskenURL:     "riskenURL",
-					ScanResult: &codescan.SemgrepFinding{
+					Finding: &scanner.SemgrepFinding{SemgrepFinding: &codescan.SemgrepFinding{
 						Repository:     "repository",
 						RepoVisibility: "public",
 						Path:           "path",
@@ -221,7 +345,7 @@ func TestPutFinding(t *testing.T) {
 							Message:  "message",
 							Lines:    "lines",
 						},
-					},
+					}},
 				},
 			},
 			setupMock: func(m *mocks.RiskenClient) {
@@ -246,7 +370,7 @@ func TestPutFinding(t *testing.T) {
 			service := &reviewService{
 				riskenClient: mockRiskenClient,
 			}
-			gotResp, err := service.putFinding(ctx, tc.args.projectID, tc.args.scanResult)
+			gotResp, err := service.putFinding(ctx, tc.args.projectID, tc.args.scanResult, tc.args.tags)
 			if (err != nil) != tc.wantErr {
 				t.Errorf("putFinding() error = %v, wantErr %v", err, tc.wantErr)
 				return
@@ -0,0 +1,113 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/ca-risken/security-review/pkg/scm/bitbucket"
+	"github.com/ca-risken/security-review/pkg/scm/gitea"
+	"github.com/ca-risken/security-review/pkg/scm/gitlab"
+)
+
+// EVENT_MERGE_REQUEST is the pipeline source of the GitLab merge request pipelines.
+// ref: https://docs.gitlab.com/ee/ci/jobs/job_rules.html#ci_pipeline_source-predefined-variable
+const EVENT_MERGE_REQUEST = "merge_request_event"
+
+// changeRequestClient is the client of the SCM other than GitHub, to review the change request of the CI pipeline.
+type changeRequestClient interface {
+	scm.Provider
+	GetChangeRequest(ctx context.Context, number int) (*scm.ChangeRequest, error)
+}
+
+func newChangeRequestClient(opt *ReviewOption, logger *slog.Logger) (changeRequestClient, error) {
+	switch opt.SCM {
+	case scm.PROVIDER_GITLAB:
+		return newGitLabClient(opt, logger)
+	case scm.PROVIDER_GITEA:
+		return newGiteaClient(opt, logger)
+	case scm.PROVIDER_BITBUCKET:
+		return newBitbucketClient(opt, logger)
+	default:
+		return nil, fmt.Errorf("unknown scm: %s", opt.SCM)
+	}
+}
+
+func newGitLabClient(opt *ReviewOption, logger *slog.Logger) (*gitlab.Client, error) {
+	if opt.GitlabProjectID == "" {
+		return nil, fmt.Errorf("gitlab project ID is required")
+	}
+	// Same retry as GitHub: GitLab also returns 429 with Retry-After on the rate limit
+	httpClient, err := newBaseHTTPClient(opt.GitlabCABundle, logger)
+	if err != nil {
+		return nil, err
+	}
+	return gitlab.NewClient(&gitlab.Config{
+		APIURL:     opt.GitlabAPIURL,
+		Token:      opt.GitlabToken,
+		ProjectID:  opt.GitlabProjectID,
+		HTTPClient: httpClient,
+	}), nil
+}
+
+func newGiteaClient(opt *ReviewOption, logger *slog.Logger) (*gitea.Client, error) {
+	if opt.GiteaAPIURL == "" || opt.GiteaRepository == "" {
+		return nil, fmt.Errorf("gitea API URL and repository are required")
+	}
+	httpClient, err := newBaseHTTPClient(opt.GiteaCABundle, logger)
+	if err != nil {
+		return nil, err
+	}
+	return gitea.NewClient(&gitea.Config{
+		APIURL:     opt.GiteaAPIURL,
+		Token:      opt.GiteaToken,
+		Repository: opt.GiteaRepository,
+		HTTPClient: httpClient,
+	}), nil
+}
+
+func newBitbucketClient(opt *ReviewOption, logger *slog.Logger) (*bitbucket.Client, error) {
+	if opt.BitbucketAPIURL == "" || opt.BitbucketProjectKey == "" || opt.BitbucketRepository == "" {
+		return nil, fmt.Errorf("bitbucket API URL, project key and repository are required")
+	}
+	httpClient, err := newBaseHTTPClient(opt.BitbucketCABundle, logger)
+	if err != nil {
+		return nil, err
+	}
+	return bitbucket.NewClient(&bitbucket.Config{
+		APIURL:         opt.BitbucketAPIURL,
+		Token:          opt.BitbucketToken,
+		ProjectKey:     opt.BitbucketProjectKey,
+		RepositorySlug: opt.BitbucketRepository,
+		HTTPClient:     httpClient,
+	}), nil
+}
+
+// getChangeRequestTarget resolves the change request of the CI pipeline. Only the pipelines for the change requests are reviewed.
+func (r *reviewService) getChangeRequestTarget(ctx context.Context) (*reviewTarget, error) {
+	event, number := EVENT_PULL_REQUEST, 0
+	switch r.opt.SCM {
+	case scm.PROVIDER_GITLAB:
+		event, number = EVENT_MERGE_REQUEST, r.opt.GitlabMergeRequestIID
+	case scm.PROVIDER_GITEA:
+		number = r.opt.GiteaPullRequest
+	case scm.PROVIDER_BITBUCKET:
+		number = r.opt.BitbucketPullRequest
+	}
+	if number == 0 {
+		r.logger.WarnContext(ctx, "Skip review for the pipeline not for the pull request (merge request)", slog.String("scm", r.opt.SCM))
+		return nil, nil
+	}
+	cr, err := r.scmClient.GetChangeRequest(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return &reviewTarget{
+		Event:         event,
+		Repository:    cr.Repository,
+		HeadSHA:       cr.HeadSHA,
+		BaseSHA:       cr.BaseSHA,
+		ChangeRequest: cr,
+	}, nil
+}
@@ -0,0 +1,108 @@
+package review
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetChangeRequestTarget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fproject":
+			_, _ = w.Write([]byte(`{"id":10,"name":"project","path_with_namespace":"group/project","web_url":"https://gitlab.example.com/group/project","default_branch":"main","visibility":"private"}`))
+		case "/api/v4/projects/group%2Fproject/merge_requests/1":
+			_, _ = w.Write([]byte(`{"iid":1,"web_url":"https://gitlab.example.com/group/project/-/merge_requests/1","sha":"head","diff_refs":{"base_sha":"base","head_sha":"head","start_sha":"start"}}`))
+		case "/api/v1/repos/owner/repo":
+			_, _ = w.Write([]byte(`{"id":20,"name":"repo","full_name":"owner/repo","html_url":"https://gitea.example.com/owner/repo","default_branch":"main"}`))
+		case "/api/v1/repos/owner/repo/pulls/1":
+			_, _ = w.Write([]byte(`{"number":1,"html_url":"https://gitea.example.com/owner/repo/pulls/1","head":{"sha":"head"},"base":{"sha":"base"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	gitlabRepo := &scm.Repository{
+		Provider: scm.PROVIDER_GITLAB, ID: 10, Name: "project", FullName: "group/project",
+		URL: "https://gitlab.example.com/group/project", DefaultBranch: "main", Visibility: "private", Private: true,
+	}
+	giteaRepo := &scm.Repository{
+		Provider: scm.PROVIDER_GITEA, ID: 20, Name: "repo", FullName: "owner/repo",
+		URL: "https://gitea.example.com/owner/repo", DefaultBranch: "main", Visibility: "public",
+	}
+	gitlabOpt := func(iid int) *ReviewOption {
+		return &ReviewOption{
+			SCM:                   scm.PROVIDER_GITLAB,
+			GitlabAPIURL:          server.URL + "/api/v4",
+			GitlabProjectID:       "group/project",
+			GitlabMergeRequestIID: iid,
+		}
+	}
+	testCases := []struct {
+		name    string
+		opt     *ReviewOption
+		want    *reviewTarget
+		wantErr bool
+	}{
+		{
+			name: "GitLab merge request pipeline",
+			opt:  gitlabOpt(1),
+			want: &reviewTarget{
+				Event: EVENT_MERGE_REQUEST, Repository: gitlabRepo, HeadSHA: "head", BaseSHA: "base",
+				ChangeRequest: &scm.ChangeRequest{
+					Number: 1, URL: "https://gitlab.example.com/group/project/-/merge_requests/1",
+					HeadSHA: "head", BaseSHA: "base", StartSHA: "start", Repository: gitlabRepo,
+				},
+			},
+		},
+		{
+			name: "Gitea pull request",
+			opt: &ReviewOption{
+				SCM:              scm.PROVIDER_GITEA,
+				GiteaAPIURL:      server.URL + "/api/v1",
+				GiteaRepository:  "owner/repo",
+				GiteaPullRequest: 1,
+			},
+			want: &reviewTarget{
+				Event: EVENT_PULL_REQUEST, Repository: giteaRepo, HeadSHA: "head", BaseSHA: "base",
+				ChangeRequest: &scm.ChangeRequest{
+					Number: 1, URL: "https://gitea.example.com/owner/repo/pulls/1",
+					HeadSHA: "head", BaseSHA: "base", Repository: giteaRepo,
+				},
+			},
+		},
+		{
+			name: "Not merge request pipeline",
+			opt:  gitlabOpt(0),
+		},
+		{
+			name:    "Not found",
+			opt:     gitlabOpt(2),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+			client, err := newChangeRequestClient(tc.opt, logger)
+			if err != nil {
+				t.Fatalf("newChangeRequestClient() error = %v", err)
+			}
+			r := &reviewService{opt: tc.opt, scmClient: client, scm: client, logger: logger}
+			got, err := r.getChangeRequestTarget(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("getChangeRequestTarget() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("getChangeRequestTarget() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
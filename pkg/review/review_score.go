@@ -0,0 +1,130 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/verify"
+	"gopkg.in/yaml.v3"
+)
+
+// scorePolicy overrides the RISKEN score (OriginalScore) of the findings by the rules, the first matched rule wins.
+// The policy is applied after the score of the secret verification, so the rules can change it by `verification`.
+type scorePolicy struct {
+	Rules []*scoreRule `yaml:"rules"`
+}
+
+// scoreRule matches the finding by all the conditions set. A rule without the conditions matches all the findings.
+type scoreRule struct {
+	Name         string   `yaml:"name"`         // shown in the finding data, the index of the rule if empty
+	Scanner      string   `yaml:"scanner"`      // e.g. semgrep, gitleaks, dependency, workflow, dockerfile, iac or entropy
+	Rule         string   `yaml:"rule"`         // glob pattern of the rule ID (the scan ID), e.g. terraform.aws.*
+	CWE          string   `yaml:"cwe"`          // e.g. CWE-89
+	Path         string   `yaml:"path"`         // file pattern in the .riskenignore syntax, e.g. test/ or *_test.go
+	Verification string   `yaml:"verification"` // live, invalid or unknown
+	Score        *float32 `yaml:"score"`        // 0.0 - 1.0
+
+	path *ignoreRule
+}
+
+// appliedScore is set to the finding data of the finding scored by the policy.
+type appliedScore struct {
+	Rule          string  `json:"rule"`
+	OriginalScore float32 `json:"original_score"` // the score before the policy
+}
+
+// loadScorePolicy returns nil if the file is not set.
+func loadScorePolicy(file string) (*scorePolicy, error) {
+	if file == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read score policy: file=%s, err=%w", file, err)
+	}
+	return parseScorePolicy(content)
+}
+
+func parseScorePolicy(content []byte) (*scorePolicy, error) {
+	var policy scorePolicy
+	if err := yaml.Unmarshal(content, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse score policy: %w", err)
+	}
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rules[%d]", i)
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid score policy: rule=%s, err=%w", rule.Name, err)
+		}
+		if rule.Path != "" {
+			rules := parseIgnoreRules(rule.Path)
+			if len(rules) != 1 || rules[0].negate {
+				return nil, fmt.Errorf("invalid score policy: rule=%s, err=invalid path pattern: %s", rule.Name, rule.Path)
+			}
+			rule.path = rules[0]
+		}
+	}
+	return &policy, nil
+}
+
+func (r *scoreRule) validate() error {
+	if r.Score == nil {
+		return errors.New("score is required")
+	}
+	if *r.Score < 0 || *r.Score > 1 {
+		return fmt.Errorf("score must be between 0 and 1: score=%v", *r.Score)
+	}
+	if _, err := path.Match(r.Rule, ""); err != nil {
+		return fmt.Errorf("invalid rule pattern: %s", r.Rule)
+	}
+	switch verify.Status(r.Verification) {
+	case "", verify.STATUS_LIVE, verify.STATUS_INVALID, verify.STATUS_UNKNOWN:
+	default:
+		return fmt.Errorf("unknown verification status: %s", r.Verification)
+	}
+	return nil
+}
+
+func (r *scoreRule) match(s *scanner.ScanResult) bool {
+	if r.Scanner != "" && r.Scanner != s.Finding.Scanner() {
+		return false
+	}
+	if r.Rule != "" {
+		if ok, _ := path.Match(r.Rule, s.ScanID); !ok {
+			return false
+		}
+	}
+	if r.CWE != "" && !slices.ContainsFunc(s.Finding.CWE(), func(cwe string) bool { return strings.EqualFold(cwe, r.CWE) }) {
+		return false
+	}
+	if r.path != nil && !r.path.match(s.File) {
+		return false
+	}
+	if r.Verification != "" && (s.Verification == nil || string(s.Verification.Status) != r.Verification) {
+		return false
+	}
+	return true
+}
+
+// apply sets the score of the first matched rule to the request. Nothing is changed if no rule matches (or no policy).
+func (p *scorePolicy) apply(req *finding.PutFindingRequest, s *scanner.ScanResult) error {
+	if p == nil {
+		return nil
+	}
+	for _, rule := range p.Rules {
+		if !rule.match(s) {
+			continue
+		}
+		original := req.Finding.OriginalScore
+		req.Finding.OriginalScore = *rule.Score
+		return setFindingData(req, "score_policy", &appliedScore{Rule: rule.Name, OriginalScore: original})
+	}
+	return nil
+}
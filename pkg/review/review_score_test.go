@@ -0,0 +1,150 @@
+package review
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ca-risken/code/pkg/codescan"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/verify"
+)
+
+const testScorePolicy = `
+rules:
+  - name: live-secret
+    verification: live
+    score: 1.0
+  - name: test-secret
+    scanner: entropy
+    path: test/
+    score: 0.1
+  - name: sqli
+    cwe: CWE-89
+    score: 0.9
+  - name: prd-iac
+    scanner: iac
+    rule: TF_AWS_*
+    path: /envs/prd/
+    score: 0.8
+  - scanner: iac
+    score: 0.4
+`
+
+func TestScorePolicy(t *testing.T) {
+	policy, err := parseScorePolicy([]byte(testScorePolicy))
+	if err != nil {
+		t.Fatalf("parseScorePolicy() error = %v", err)
+	}
+	entropy := func(file string, status verify.Status) *scanner.ScanResult {
+		result := &scanner.ScanResult{ScanID: "HIGH_ENTROPY_STRING", File: file, Finding: &scanner.RuleFinding{
+			ScannerName: scanner.RULE_SCANNER_ENTROPY, RuleID: "HIGH_ENTROPY_STRING", Level: scanner.SEVERITY_HIGH, Repository: "owner/repo", Path: file,
+		}}
+		if status != "" {
+			result.Verification = &verify.Result{Verifier: "github", Status: status}
+		}
+		return result
+	}
+	iac := func(ruleID, file string) *scanner.ScanResult {
+		return &scanner.ScanResult{ScanID: ruleID, File: file, Finding: &scanner.RuleFinding{
+			ScannerName: scanner.RULE_SCANNER_IAC, RuleID: ruleID, Level: scanner.SEVERITY_MEDIUM, Repository: "owner/repo", Path: file,
+		}}
+	}
+	semgrep := &scanner.ScanResult{ScanID: "go.lang.security.audit.sqli", File: "main.go", Finding: &scanner.SemgrepFinding{SemgrepFinding: &codescan.SemgrepFinding{
+		Repository: "owner/repo", Path: "main.go", CheckID: "go.lang.security.audit.sqli",
+		Start: &codescan.SemgrepLine{Line: 1}, End: &codescan.SemgrepLine{Line: 1},
+		Extra: &codescan.SemgrepExtra{Severity: "WARNING", Metadata: map[string]any{"cwe": []string{"CWE-89: SQL Injection"}}},
+	}}}
+
+	testCases := []struct {
+		name     string
+		result   *scanner.ScanResult
+		want     float32
+		wantRule string // empty if no rule matched
+	}{
+		{name: "Live secret in test directory (the first rule wins)", result: entropy("test/fixture.go", verify.STATUS_LIVE), want: 1.0, wantRule: "live-secret"},
+		{name: "Secret in test directory", result: entropy("test/fixture.go", ""), want: 0.1, wantRule: "test-secret"},
+		{name: "Invalid secret in test directory (policy overrides verification score)", result: entropy("test/fixture.go", verify.STATUS_INVALID), want: 0.1, wantRule: "test-secret"},
+		{name: "Invalid secret not matched keeps verification score", result: entropy("main.go", verify.STATUS_INVALID), want: INVALID_SECRET_SCORE},
+		{name: "CWE", result: semgrep, want: 0.9, wantRule: "sqli"},
+		{name: "Production IaC (specific rule before the scanner rule)", result: iac("TF_AWS_S3_PUBLIC", "envs/prd/main.tf"), want: 0.8, wantRule: "prd-iac"},
+		{name: "Other IaC rule", result: iac("TF_GCP_PUBLIC", "envs/prd/main.tf"), want: 0.4, wantRule: "rules[4]"},
+		{name: "IaC outside production", result: iac("TF_AWS_S3_PUBLIC", "modules/envs/prd/main.tf"), want: 0.4, wantRule: "rules[4]"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			base, err := (&reviewService{}).buildRiskenSubmission(1, tc.result, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sub, err := (&reviewService{scorePolicy: policy}).buildRiskenSubmission(1, tc.result, nil)
+			if err != nil {
+				t.Fatalf("buildRiskenSubmission() error = %v", err)
+			}
+			want := tc.want
+			if tc.wantRule == "" {
+				want = base.PutFinding.Finding.OriginalScore
+			}
+			if got := sub.PutFinding.Finding.OriginalScore; got != want {
+				t.Errorf("OriginalScore = %v, want %v", got, want)
+			}
+			var data struct {
+				ScorePolicy *appliedScore `json:"score_policy"`
+			}
+			if err := json.Unmarshal([]byte(sub.PutFinding.Finding.Data), &data); err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tc.wantRule == "" && data.ScorePolicy != nil:
+				t.Errorf("score_policy = %+v, want nil", data.ScorePolicy)
+			case tc.wantRule != "" && (data.ScorePolicy == nil || data.ScorePolicy.Rule != tc.wantRule || data.ScorePolicy.OriginalScore != base.PutFinding.Finding.OriginalScore):
+				t.Errorf("score_policy = %+v, want rule %s and original score %v", data.ScorePolicy, tc.wantRule, base.PutFinding.Finding.OriginalScore)
+			}
+		})
+	}
+}
+
+func TestParseScorePolicy(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "OK", content: testScorePolicy},
+		{name: "Empty", content: ""},
+		{name: "No score", content: "rules:\n  - scanner: iac\n", wantErr: true},
+		{name: "Score out of range", content: "rules:\n  - scanner: iac\n    score: 2\n", wantErr: true},
+		{name: "Unknown verification", content: "rules:\n  - verification: valid\n    score: 1\n", wantErr: true},
+		{name: "Invalid rule pattern", content: "rules:\n  - rule: '[abc'\n    score: 1\n", wantErr: true},
+		{name: "Negated path", content: "rules:\n  - path: '!test/'\n    score: 1\n", wantErr: true},
+		{name: "Invalid YAML", content: "rules: {", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseScorePolicy([]byte(tc.content)); (err != nil) != tc.wantErr {
+				t.Errorf("parseScorePolicy() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadScorePolicy(t *testing.T) {
+	if policy, err := loadScorePolicy(""); policy != nil || err != nil {
+		t.Errorf("loadScorePolicy(\"\") = %v, %v, want nil", policy, err)
+	}
+	file := filepath.Join(t.TempDir(), "score.yaml")
+	if err := os.WriteFile(file, []byte(testScorePolicy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := loadScorePolicy(file)
+	if err != nil {
+		t.Fatalf("loadScorePolicy() error = %v", err)
+	}
+	if len(policy.Rules) != 5 {
+		t.Errorf("rules = %d, want 5", len(policy.Rules))
+	}
+	if _, err := loadScorePolicy(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("loadScorePolicy() of the missing file error = nil, want error")
+	}
+}
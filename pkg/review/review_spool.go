@@ -0,0 +1,184 @@
+package review
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ca-risken/core/proto/finding"
+)
+
+const SPOOL_FILE_EXT = ".json"
+
+// riskenSpool is the directory of the findings failed to be sent to RISKEN, replayed by the risken flush command.
+// A file is written per finding named by the data source ID, so the spooled finding is overwritten by the later run (not duplicated).
+type riskenSpool struct {
+	dir string
+}
+
+// newRiskenSpool returns nil if the directory is empty (spool disabled).
+func newRiskenSpool(dir string) *riskenSpool {
+	if dir == "" {
+		return nil
+	}
+	return &riskenSpool{dir: dir}
+}
+
+func (s *riskenSpool) path(dataSource, dataSourceID string) string {
+	sum := sha256.Sum256([]byte(dataSource + "\x00" + dataSourceID))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+SPOOL_FILE_EXT)
+}
+
+func (s *riskenSpool) write(sub *riskenSubmission) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create spool directory: dir=%s, err=%w", s.dir, err)
+	}
+	content, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal spool entry: %w", err)
+	}
+	// Write to the temporary file and rename, not to leave the partial file on the failure
+	path := s.path(sub.PutFinding.Finding.DataSource, sub.PutFinding.Finding.DataSourceId)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return fmt.Errorf("failed to write spool entry: path=%s, err=%w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename spool entry: path=%s, err=%w", path, err)
+	}
+	return nil
+}
+
+func (s *riskenSpool) remove(req *finding.PutFindingRequest) error {
+	if err := os.Remove(s.path(req.Finding.DataSource, req.Finding.DataSourceId)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove spool entry: %w", err)
+	}
+	return nil
+}
+
+// list returns the paths of the spooled findings. No findings if the directory does not exist.
+func (s *riskenSpool) list() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: dir=%s, err=%w", s.dir, err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), SPOOL_FILE_EXT) {
+			paths = append(paths, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (s *riskenSpool) read(path string) (*riskenSubmission, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool entry: path=%s, err=%w", path, err)
+	}
+	var sub riskenSubmission
+	if err := json.Unmarshal(content, &sub); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal spool entry: path=%s, err=%w", path, err)
+	}
+	if sub.PutFinding == nil || sub.PutFinding.Finding == nil || sub.PutRecommend == nil {
+		return nil, fmt.Errorf("invalid spool entry: path=%s", path)
+	}
+	return &sub, nil
+}
+
+// setProjectID sets the default project, which is unknown (0) if the signin failed on spooling.
+func (sub *riskenSubmission) setProjectID(projectID uint32) {
+	sub.PutFinding.ProjectId = projectID
+	sub.PutFinding.Finding.ProjectId = projectID
+	sub.PutRecommend.ProjectId = projectID
+}
+
+// FlushRiskenSpool sends the findings spooled by the review to RISKEN, and removes them from the spool.
+// The findings are sent to the projects selected on spooling, or the default project if unknown.
+// The findings are put by the data source IDs (upsert), so the replay is idempotent.
+// On the dry run, the requests are recorded instead of sent, and the spool is kept.
+func FlushRiskenSpool(ctx context.Context, opt *ReviewOption, logger *slog.Logger) error {
+	if opt.RiskenSpoolDir == "" {
+		return errors.New("spool directory is not set")
+	}
+	if opt.RiskenApiEndpoint == "" || opt.RiskenApiToken == "" {
+		return errors.New("RISKEN API endpoint and token are not set")
+	}
+	r := &reviewService{
+		opt:          opt,
+		riskenClient: NewRiskenClient(opt.RiskenApiToken, opt.RiskenApiEndpoint),
+		spool:        newRiskenSpool(opt.RiskenSpoolDir),
+		logger:       logger,
+	}
+	if opt.DryRun {
+		r.dryRun = &dryRunRecorder{}
+		r.riskenClient = newDryRunRiskenClient(r.riskenClient, r.dryRun)
+	}
+	err := r.flushSpool(ctx)
+	if r.dryRun != nil {
+		logger.InfoContext(ctx, "Dry run", slog.Int("requests", len(r.dryRun.Requests)), slog.String("output", opt.DryRunOutput))
+		if writeErr := r.dryRun.write(opt.DryRunOutput); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
+	}
+	return err
+}
+
+func (r *reviewService) flushSpool(ctx context.Context) error {
+	paths, err := r.spool.list()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		r.logger.InfoContext(ctx, "No spooled findings", slog.String("dir", r.spool.dir))
+		return nil
+	}
+	projects, err := r.getRiskenProjects(ctx)
+	if err != nil {
+		return err
+	}
+	u := newRiskenUploader(r, nil)
+	var flushed, failures int
+	for _, path := range paths {
+		sub, err := r.spool.read(path)
+		if err != nil {
+			r.logger.WarnContext(ctx, "Failed to read spooled finding", slog.String("err", err.Error()))
+			failures++
+			continue
+		}
+		if sub.PutFinding.ProjectId == 0 {
+			sub.setProjectID(projects.defaultID)
+		}
+		if err := u.retry(ctx, func() error {
+			_, err := r.submitFinding(ctx, sub)
+			return err
+		}); err != nil {
+			r.logger.WarnContext(ctx, "Failed to flush spooled finding", slog.String("path", path), slog.String("err", err.Error()))
+			failures++
+			continue
+		}
+		if r.dryRun == nil { // kept to be flushed actually
+			if err := os.Remove(path); err != nil {
+				r.logger.WarnContext(ctx, "Failed to remove flushed finding", slog.String("path", path), slog.String("err", err.Error()))
+			}
+		}
+		flushed++
+	}
+	r.logger.InfoContext(ctx, "Flushed spooled findings", slog.Int("flushed", flushed), slog.Int("failures", failures))
+	if failures > 0 {
+		return fmt.Errorf("failed to flush spooled findings: failures=%d, findings=%d", failures, len(paths))
+	}
+	return nil
+}
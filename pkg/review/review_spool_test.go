@@ -0,0 +1,230 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/go-risken"
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/mock"
+)
+
+func spoolTestResult(fingerprint string) *scanner.ScanResult {
+	return &scanner.ScanResult{ScanID: "rule", Fingerprint: fingerprint, Finding: &scanner.RuleFinding{RuleID: "rule", Repository: "owner/repo"}}
+}
+
+func spoolTestSubmission(t *testing.T, fingerprint string) *riskenSubmission {
+	t.Helper()
+	sub, err := (&reviewService{}).buildRiskenSubmission(0, spoolTestResult(fingerprint), []string{"repository:owner/repo"})
+	if err != nil {
+		t.Fatalf("buildRiskenSubmission() error = %v", err)
+	}
+	return sub
+}
+
+func TestRiskenSpool(t *testing.T) {
+	if s := newRiskenSpool(""); s != nil {
+		t.Errorf("newRiskenSpool(\"\") = %v, want nil", s)
+	}
+	s := newRiskenSpool(filepath.Join(t.TempDir(), "spool"))
+	if paths, err := s.list(); err != nil || len(paths) != 0 {
+		t.Fatalf("list() on the missing directory = %v, %v", paths, err)
+	}
+	sub1, sub2 := spoolTestSubmission(t, "fp1"), spoolTestSubmission(t, "fp2")
+	for _, sub := range []*riskenSubmission{sub1, sub2, sub1} { // overwritten, not duplicated
+		if err := s.write(sub); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+	}
+	paths, err := s.list()
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("list() = %v, %v, want 2 entries", paths, err)
+	}
+	got, err := s.read(s.path(sub1.PutFinding.Finding.DataSource, "fp1"))
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if got.PutFinding.Finding.DataSourceId != "fp1" || got.PutRecommend == nil {
+		t.Errorf("read() = %+v", got)
+	}
+	if diff := cmp.Diff(sub1.Tags, got.Tags); diff != "" {
+		t.Errorf("Tags mismatch (-want +got):\n%s", diff)
+	}
+
+	for range 2 { // not-exist is ignored
+		if err := s.remove(sub1.PutFinding); err != nil {
+			t.Fatalf("remove() error = %v", err)
+		}
+	}
+	if paths, _ := s.list(); len(paths) != 1 {
+		t.Errorf("list() after remove = %v, want 1 entry", paths)
+	}
+	broken := filepath.Join(s.dir, "broken"+SPOOL_FILE_EXT)
+	if err := os.WriteFile(broken, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.read(broken); err == nil {
+		t.Errorf("read() of the invalid entry error = nil, want error")
+	}
+}
+
+func TestRiskenUploaderSpool(t *testing.T) {
+	ctx := context.Background()
+	dataSourceID := func(id string) any {
+		return mock.MatchedBy(func(req *finding.PutFindingRequest) bool { return req.Finding.DataSourceId == id })
+	}
+	m := mocks.NewRiskenClient(t)
+	// fp1: spooled on the server error
+	m.On("PutFinding", ctx, dataSourceID("fp1")).Return(nil, risken.APIError{Status: http.StatusServiceUnavailable})
+	// fp2: not spooled on the client error
+	m.On("PutFinding", ctx, dataSourceID("fp2")).Return(nil, risken.APIError{Status: http.StatusBadRequest}).Once()
+	// fp3: uploaded, and removed from the spool of the previous run
+	m.On("PutFinding", ctx, dataSourceID("fp3")).Return(&finding.PutFindingResponse{Finding: &finding.Finding{FindingId: 3, ProjectId: 1}}, nil).Once()
+	m.On("PutRecommend", ctx, mock.Anything).Return(&finding.PutRecommendResponse{}, nil).Once()
+
+	r := &reviewService{
+		opt:          &ReviewOption{},
+		riskenClient: m,
+		spool:        newRiskenSpool(t.TempDir()),
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := r.spool.write(spoolTestSubmission(t, "fp3")); err != nil {
+		t.Fatal(err)
+	}
+	u := newRiskenUploader(r, nil)
+	u.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	results := []*scanner.ScanResult{spoolTestResult("fp1"), spoolTestResult("fp2"), spoolTestResult("fp3")}
+	u.upload(ctx, 1, results)
+
+	var statuses []string
+	for _, result := range results {
+		statuses = append(statuses, result.RiskenStatus)
+	}
+	if diff := cmp.Diff([]string{RISKEN_STATUS_SPOOLED, RISKEN_STATUS_FAILED, RISKEN_STATUS_UPLOADED}, statuses); diff != "" {
+		t.Errorf("RiskenStatus mismatch (-want +got):\n%s", diff)
+	}
+	paths, err := r.spool.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 {
+		t.Fatalf("spooled = %v, want 1 entry", paths)
+	}
+	sub, err := r.spool.read(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.PutFinding.Finding.DataSourceId != "fp1" || sub.PutFinding.ProjectId != 1 {
+		t.Errorf("spooled finding = %+v", sub.PutFinding)
+	}
+}
+
+func TestRiskenUploaderSpoolDryRun(t *testing.T) {
+	ctx := context.Background()
+	r := &reviewService{
+		opt:    &ReviewOption{},
+		spool:  newRiskenSpool(t.TempDir()),
+		dryRun: &dryRunRecorder{},
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	if err := r.spool.write(spoolTestSubmission(t, "fp1")); err != nil {
+		t.Fatal(err)
+	}
+	u := newRiskenUploader(r, nil)
+	if u.spoolFinding(ctx, 1, spoolTestResult("fp2"), risken.APIError{Status: http.StatusServiceUnavailable}) {
+		t.Errorf("spoolFinding() on the dry run = true, want false")
+	}
+	u.unspoolFinding(ctx, 1, spoolTestResult("fp1"))
+	paths, err := r.spool.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 {
+		t.Errorf("spooled = %v, want the entry of the previous run only", paths)
+	}
+}
+
+func TestFlushSpool(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name      string
+		signinErr error
+		fp2Err    error
+		dryRun    bool
+		wantErr   bool
+		wantLeft  int
+	}{
+		{name: "OK"},
+		{name: "Dry run keeps spool", dryRun: true, wantLeft: 2},
+		{name: "Failure is kept", fp2Err: risken.APIError{Status: http.StatusBadRequest}, wantErr: true, wantLeft: 1},
+		{name: "Signin error", signinErr: errors.New("error calling the API endpoint"), wantErr: true, wantLeft: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mocks.NewRiskenClient(t)
+			m.On("Signin", ctx).Return(&risken.SigninResponse{ProjectID: 9}, tc.signinErr).Once()
+			if tc.signinErr == nil && !tc.dryRun {
+				projectID := func(id string) any {
+					return mock.MatchedBy(func(req *finding.PutFindingRequest) bool {
+						return req.Finding.DataSourceId == id && req.ProjectId == 9 && req.Finding.ProjectId == 9
+					})
+				}
+				m.On("PutFinding", ctx, projectID("fp1")).Return(&finding.PutFindingResponse{Finding: &finding.Finding{FindingId: 1, ProjectId: 9}}, nil).Once()
+				if tc.fp2Err != nil {
+					m.On("PutFinding", ctx, projectID("fp2")).Return(nil, tc.fp2Err).Once()
+				} else {
+					m.On("PutFinding", ctx, projectID("fp2")).Return(&finding.PutFindingResponse{Finding: &finding.Finding{FindingId: 2, ProjectId: 9}}, nil).Once()
+				}
+				m.On("PutRecommend", ctx, mock.MatchedBy(func(req *finding.PutRecommendRequest) bool { return req.ProjectId == 9 && req.FindingId != 0 })).
+					Return(&finding.PutRecommendResponse{}, nil)
+				m.On("TagFinding", ctx, mock.MatchedBy(func(req *finding.TagFindingRequest) bool { return req.ProjectId == 9 })).
+					Return(&finding.TagFindingResponse{}, nil)
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{},
+				riskenClient: m,
+				spool:        newRiskenSpool(t.TempDir()),
+				logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			if tc.dryRun {
+				r.dryRun = &dryRunRecorder{}
+				r.riskenClient = newDryRunRiskenClient(m, r.dryRun)
+			}
+			for _, fp := range []string{"fp1", "fp2"} {
+				if err := r.spool.write(spoolTestSubmission(t, fp)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := r.flushSpool(ctx)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("flushSpool() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if paths, _ := r.spool.list(); len(paths) != tc.wantLeft {
+				t.Errorf("spooled = %v, want %d entries", paths, tc.wantLeft)
+			}
+			if tc.dryRun && len(r.dryRun.Requests) == 0 {
+				t.Errorf("dry run requests are not recorded")
+			}
+		})
+	}
+}
+
+func TestFlushRiskenSpoolValidation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := FlushRiskenSpool(context.Background(), &ReviewOption{RiskenApiEndpoint: "https://api.example.com", RiskenApiToken: "token"}, logger); err == nil {
+		t.Errorf("FlushRiskenSpool() without the spool directory error = nil, want error")
+	}
+	if err := FlushRiskenSpool(context.Background(), &ReviewOption{RiskenSpoolDir: t.TempDir()}, logger); err == nil {
+		t.Errorf("FlushRiskenSpool() without the RISKEN API error = nil, want error")
+	}
+}
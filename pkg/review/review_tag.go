@@ -0,0 +1,84 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+// RISKEN_TAG_MAX_LENGTH is the max length (in characters) of the RISKEN finding tag.
+const RISKEN_TAG_MAX_LENGTH = 64
+
+// The keys of the tags attached to the findings, in the form of key:value.
+const (
+	TAG_REPOSITORY  = "repository"
+	TAG_PR          = "pr" // PR number, or MR IID on GitLab
+	TAG_AUTHOR      = "author"
+	TAG_BASE_BRANCH = "base_branch"
+	TAG_HEAD_BRANCH = "head_branch"
+	TAG_HEAD_SHA    = "head_sha"
+)
+
+// findingTags returns the tags of the review target and the user-defined tags, without the duplicates.
+// The long values (e.g. the branch names) are truncated to the max length of the tag.
+func findingTags(target *reviewTarget, userTags []string) []string {
+	var tags []string
+	add := func(key, value string) {
+		if value != "" {
+			tags = append(tags, formatTag(key, value))
+		}
+	}
+	if target.Repository != nil {
+		add(TAG_REPOSITORY, target.Repository.FullName)
+	}
+	if cr := target.ChangeRequest; cr != nil {
+		tags = append(tags, changeRequestTag(cr))
+		add(TAG_AUTHOR, cr.Author)
+		add(TAG_BASE_BRANCH, cr.BaseBranch)
+		add(TAG_HEAD_BRANCH, cr.HeadBranch)
+	} else if branch, ok := strings.CutPrefix(target.Ref, "refs/heads/"); ok {
+		add(TAG_HEAD_BRANCH, branch)
+	}
+	add(TAG_HEAD_SHA, target.HeadSHA)
+
+	seen := map[string]bool{}
+	var uniq []string
+	for _, tag := range append(tags, userTags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			uniq = append(uniq, tag)
+		}
+	}
+	return uniq
+}
+
+// changeRequestTag returns the tag of the findings reported on the PR.
+func changeRequestTag(cr *scm.ChangeRequest) string {
+	return formatTag(TAG_PR, strconv.Itoa(cr.Number))
+}
+
+// formatTag returns the tag of key:value, truncated to the max length.
+func formatTag(key, value string) string {
+	tag := key + ":" + value
+	if utf8.RuneCountInString(tag) <= RISKEN_TAG_MAX_LENGTH {
+		return tag
+	}
+	return string([]rune(tag)[:RISKEN_TAG_MAX_LENGTH])
+}
+
+// validateRiskenTags validates the user-defined tags, which are rejected by RISKEN if empty or too long.
+func validateRiskenTags(tags []string) error {
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("empty RISKEN tag")
+		}
+		if n := utf8.RuneCountInString(tag); n > RISKEN_TAG_MAX_LENGTH {
+			return fmt.Errorf("too long RISKEN tag (max %d characters): tag=%s, length=%d", RISKEN_TAG_MAX_LENGTH, tag, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package review
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindingTags(t *testing.T) {
+	repo := &scm.Repository{FullName: "owner/repo", DefaultBranch: "main"}
+	testCases := []struct {
+		name     string
+		target   *reviewTarget
+		userTags []string
+		want     []string
+	}{
+		{
+			name: "Pull request",
+			target: &reviewTarget{
+				Repository: repo,
+				HeadSHA:    "abc123",
+				ChangeRequest: &scm.ChangeRequest{
+					Number: 12, Author: "alice", BaseBranch: "main", HeadBranch: "feature", Repository: repo,
+				},
+			},
+			userTags: []string{"team:security", "repository:owner/repo"},
+			want: []string{
+				"repository:owner/repo", "pr:12", "author:alice", "base_branch:main", "head_branch:feature", "head_sha:abc123",
+				"team:security",
+			},
+		},
+		{
+			name:   "Push",
+			target: &reviewTarget{Repository: repo, Ref: "refs/heads/main", HeadSHA: "abc123"},
+			want:   []string{"repository:owner/repo", "head_branch:main", "head_sha:abc123"},
+		},
+		{
+			name:   "Tag push and long value",
+			target: &reviewTarget{Repository: &scm.Repository{FullName: strings.Repeat("a", 70)}, Ref: "refs/tags/v1.0.0"},
+			want:   []string{"repository:" + strings.Repeat("a", RISKEN_TAG_MAX_LENGTH-len("repository:"))},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := findingTags(tc.target, tc.userTags)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("findingTags() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateRiskenTags(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tags    []string
+		wantErr bool
+	}{
+		{name: "OK", tags: []string{"team:security", "env:prd", strings.Repeat("あ", RISKEN_TAG_MAX_LENGTH)}},
+		{name: "No tags", tags: nil},
+		{name: "Empty", tags: []string{"team:security", " "}, wantErr: true},
+		{name: "Too long", tags: []string{strings.Repeat("a", RISKEN_TAG_MAX_LENGTH+1)}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateRiskenTags(tc.tags); (err != nil) != tc.wantErr {
+				t.Errorf("validateRiskenTags() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/go-risken"
+	"github.com/ca-risken/security-review/pkg/scanner"
+)
+
+// The handling of the findings triaged (pended) in RISKEN.
+const (
+	TRIAGE_SKIP     = "skip"     // do not comment on the triaged findings
+	TRIAGE_ANNOTATE = "annotate" // comment with the triage status
+	TRIAGE_OFF      = "off"      // do not look up the triage status
+)
+
+// The triage status of the finding in RISKEN.
+const (
+	RISKEN_TRIAGE_PENDING        = "pending"
+	RISKEN_TRIAGE_FALSE_POSITIVE = "false_positive"
+)
+
+func validateTriageMode(mode string) error {
+	switch mode {
+	case "", TRIAGE_SKIP, TRIAGE_ANNOTATE, TRIAGE_OFF:
+		return nil
+	default:
+		return fmt.Errorf("unknown RISKEN triage mode: %s", mode)
+	}
+}
+
+func (r *reviewService) triageMode() string {
+	if r.opt.RiskenTriage == "" {
+		return TRIAGE_SKIP
+	}
+	return r.opt.RiskenTriage
+}
+
+// lookupRiskenTriage sets the triage status of the scan results pended in RISKEN (archived or marked as false positive in the console).
+// Only the findings put in the current run are looked up: the pending findings of the repository are narrowed down
+// by the finding IDs returned from the upload (in the order of the scan results, nil if failed), and their pend status
+// is fetched with the bounded concurrency.
+func (r *reviewService) lookupRiskenTriage(ctx context.Context, projectID uint32, repository string, scanResults []*scanner.ScanResult, findings []*finding.Finding) error {
+	if r.triageMode() == TRIAGE_OFF || len(scanResults) == 0 || r.dryRun != nil { // the dry run returns the dummy finding IDs
+		return nil
+	}
+	put := map[uint64]*scanner.ScanResult{}
+	for i, f := range findings {
+		if f != nil {
+			put[f.FindingId] = scanResults[i]
+		}
+	}
+	if len(put) == 0 {
+		return nil
+	}
+	var pendingIDs []uint64
+	var offset int32
+	for {
+		resp, err := r.riskenClient.ListFinding(ctx, &finding.ListFindingRequest{
+			ProjectId:    projectID,
+			ResourceName: []string{repository},
+			ToScore:      1.0,
+			Status:       finding.FindingStatus_FINDING_PENDING,
+			Offset:       offset,
+			Limit:        listFindingLimit,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list pending findings: repository=%s, err=%w", repository, err)
+		}
+		for _, id := range resp.FindingId {
+			if _, ok := put[id]; ok {
+				pendingIDs = append(pendingIDs, id)
+			}
+		}
+		offset += int32(len(resp.FindingId))
+		if len(resp.FindingId) == 0 || uint32(offset) >= resp.Total {
+			break
+		}
+	}
+
+	sem := make(chan struct{}, riskenUploadConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for _, id := range pendingIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pend, err := r.getPendFinding(ctx, projectID, id)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			if pend == nil || (pend.ExpiredAt != 0 && pend.ExpiredAt < time.Now().Unix()) {
+				return
+			}
+			result := put[id]
+			result.RiskenTriage = RISKEN_TRIAGE_PENDING
+			if pend.Reason == finding.PendReason_PEND_REASON_FALSE_POSITIVE {
+				result.RiskenTriage = RISKEN_TRIAGE_FALSE_POSITIVE
+			}
+			result.RiskenTriageNote = pend.Note
+			r.logger.InfoContext(ctx, "Triaged finding in RISKEN", slog.String("file", result.File), slog.Int("line", result.Line),
+				slog.String("scan_id", result.ScanID), slog.String("triage", result.RiskenTriage))
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// getPendFinding returns nil if the finding is not pended.
+func (r *reviewService) getPendFinding(ctx context.Context, projectID uint32, findingID uint64) (*finding.PendFinding, error) {
+	resp, err := r.riskenClient.GetPendFinding(ctx, &finding.GetPendFindingRequest{ProjectId: projectID, FindingId: findingID})
+	if err != nil {
+		var apiErr risken.APIError
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get pend finding: finding_id=%d, err=%w", findingID, err)
+	}
+	return resp.PendFinding, nil
+}
+
+// filterTriagedResults removes the findings triaged in RISKEN on the skip mode.
+func (r *reviewService) filterTriagedResults(ctx context.Context, scanResults []*scanner.ScanResult) []*scanner.ScanResult {
+	if r.triageMode() != TRIAGE_SKIP {
+		return scanResults
+	}
+	var filtered []*scanner.ScanResult
+	for _, result := range scanResults {
+		if result.RiskenTriage != "" {
+			r.logger.InfoContext(ctx, "Skip finding triaged in RISKEN", slog.String("file", result.File), slog.Int("line", result.Line), slog.String("scan_id", result.ScanID))
+			r.summary.Triaged++
+			continue
+		}
+		filtered = append(filtered, result)
+	}
+	return filtered
+}
+
+// untriagedResults returns the findings not triaged in RISKEN, which fail the review with --error.
+func untriagedResults(scanResults []*scanner.ScanResult) []*scanner.ScanResult {
+	var untriaged []*scanner.ScanResult
+	for _, result := range scanResults {
+		if result.RiskenTriage == "" {
+			untriaged = append(untriaged, result)
+		}
+	}
+	return untriaged
+}
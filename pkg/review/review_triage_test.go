@@ -0,0 +1,158 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/go-risken"
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLookupRiskenTriage(t *testing.T) {
+	ctx := context.Background()
+	pends := map[uint64]*finding.PendFinding{
+		1: {FindingId: 1, Note: "accepted risk"},
+		2: {FindingId: 2, Reason: finding.PendReason_PEND_REASON_FALSE_POSITIVE},
+		3: {FindingId: 3, ExpiredAt: time.Now().Add(-time.Hour).Unix()}, // expired
+	}
+	testCases := []struct {
+		name      string
+		mode      string
+		listErr   error
+		pendErr   error
+		wantErr   bool
+		want      []string
+		wantNotes []string
+	}{
+		{
+			name:      "OK",
+			want:      []string{RISKEN_TRIAGE_PENDING, RISKEN_TRIAGE_FALSE_POSITIVE, "", "", ""},
+			wantNotes: []string{"accepted risk", "", "", "", ""},
+		},
+		{
+			name:      "Off",
+			mode:      TRIAGE_OFF,
+			want:      []string{"", "", "", "", ""},
+			wantNotes: []string{"", "", "", "", ""},
+		},
+		{
+			name:      "List error",
+			listErr:   risken.APIError{Status: http.StatusInternalServerError},
+			wantErr:   true,
+			want:      []string{"", "", "", "", ""},
+			wantNotes: []string{"", "", "", "", ""},
+		},
+		{
+			name:      "Pend error",
+			pendErr:   risken.APIError{Status: http.StatusInternalServerError},
+			wantErr:   true,
+			want:      []string{RISKEN_TRIAGE_PENDING, "", "", "", ""},
+			wantNotes: []string{"accepted risk", "", "", "", ""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// No GetFinding calls, and no GetPendFinding calls for the findings not put in the run (5: other finding, 9: upload failed)
+			m := mocks.NewRiskenClient(t)
+			if tc.mode != TRIAGE_OFF {
+				m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool {
+					return req.Status == finding.FindingStatus_FINDING_PENDING && req.ResourceName[0] == "owner/repo"
+				})).Return(&finding.ListFindingResponse{FindingId: []uint64{1, 2, 3, 5, 9}, Total: 5}, tc.listErr).Once()
+			}
+			if tc.listErr == nil && tc.mode != TRIAGE_OFF {
+				m.On("GetPendFinding", ctx, mock.MatchedBy(func(req *finding.GetPendFindingRequest) bool { return req.FindingId <= 3 })).
+					Return(func(ctx context.Context, req *finding.GetPendFindingRequest) (*finding.GetPendFindingResponse, error) {
+						if req.FindingId == 2 && tc.pendErr != nil {
+							return nil, tc.pendErr
+						}
+						return &finding.GetPendFindingResponse{PendFinding: pends[req.FindingId]}, nil
+					}).Times(3)
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{RiskenTriage: tc.mode},
+				riskenClient: m,
+				logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			results := []*scanner.ScanResult{
+				{ScanID: "rule1", Fingerprint: "fp1"},
+				{ScanID: "rule2", Fingerprint: "fp2"},
+				{ScanID: "rule3", Fingerprint: "fp3"}, // expired
+				{ScanID: "rule4", Fingerprint: "fp4"}, // not pended
+				{ScanID: "rule5", Fingerprint: "fp5"}, // upload failed
+			}
+			findings := []*finding.Finding{{FindingId: 1}, {FindingId: 2}, {FindingId: 3}, {FindingId: 4}, nil}
+			err := r.lookupRiskenTriage(ctx, 1, "owner/repo", results, findings)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("lookupRiskenTriage() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			var got, gotNotes []string
+			for _, result := range results {
+				got = append(got, result.RiskenTriage)
+				gotNotes = append(gotNotes, result.RiskenTriageNote)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("RiskenTriage mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantNotes, gotNotes); diff != "" {
+				t.Errorf("RiskenTriageNote mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetPendFinding(t *testing.T) {
+	ctx := context.Background()
+	m := mocks.NewRiskenClient(t)
+	m.On("GetPendFinding", ctx, mock.Anything).Return(nil, errors.New("error calling the API endpoint")).Once()
+	r := &reviewService{riskenClient: m}
+	if _, err := r.getPendFinding(ctx, 1, 1); err == nil {
+		t.Errorf("getPendFinding() error = nil, want error")
+	}
+}
+
+func TestFilterTriagedResults(t *testing.T) {
+	results := []*scanner.ScanResult{
+		{ScanID: "rule1", RiskenTriage: RISKEN_TRIAGE_PENDING},
+		{ScanID: "rule2"},
+		{ScanID: "rule3", RiskenTriage: RISKEN_TRIAGE_FALSE_POSITIVE},
+	}
+	testCases := []struct {
+		name        string
+		mode        string
+		want        []string
+		wantTriaged int
+	}{
+		{name: "Default (skip)", mode: "", want: []string{"rule2"}, wantTriaged: 2},
+		{name: "Annotate", mode: TRIAGE_ANNOTATE, want: []string{"rule1", "rule2", "rule3"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &reviewService{
+				opt:    &ReviewOption{RiskenTriage: tc.mode},
+				logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			var got []string
+			for _, result := range r.filterTriagedResults(context.Background(), results) {
+				got = append(got, result.ScanID)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("filterTriagedResults() mismatch (-want +got):\n%s", diff)
+			}
+			if r.summary.Triaged != tc.wantTriaged {
+				t.Errorf("Triaged = %d, want %d", r.summary.Triaged, tc.wantTriaged)
+			}
+			if n := len(untriagedResults(results)); n != 1 {
+				t.Errorf("untriagedResults() = %d, want 1", n)
+			}
+		})
+	}
+}
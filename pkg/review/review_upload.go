@@ -0,0 +1,168 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/go-risken"
+	"github.com/ca-risken/security-review/pkg/scanner"
+)
+
+const (
+	riskenUploadConcurrency = 4
+	riskenMaxRetries        = 3
+	riskenMinBackoff        = 1 * time.Second
+	riskenMaxBackoff        = 10 * time.Second
+)
+
+const (
+	RISKEN_STATUS_UPLOADED = "uploaded"
+	RISKEN_STATUS_FAILED   = "failed"
+	RISKEN_STATUS_SPOOLED  = "spooled" // failed and written to the spool directory
+)
+
+// riskenUploader puts the findings to RISKEN with the bounded concurrency, retrying the transient errors.
+type riskenUploader struct {
+	review      *reviewService
+	tags        []string // attached to all the findings
+	concurrency int
+	maxRetries  int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+func newRiskenUploader(r *reviewService, tags []string) *riskenUploader {
+	return &riskenUploader{
+		review:      r,
+		tags:        tags,
+		concurrency: riskenUploadConcurrency,
+		maxRetries:  riskenMaxRetries,
+		minBackoff:  riskenMinBackoff,
+		maxBackoff:  riskenMaxBackoff,
+		sleep:       sleepContext,
+	}
+}
+
+// upload puts the findings and continues on the failures of the individual findings.
+// The RISKEN status (and URL) is set on each scan result, and the put findings are returned in the order of the scan results (nil if failed).
+// The findings failed by the transient errors are spooled if the spool is enabled (and not dry run).
+func (u *riskenUploader) upload(ctx context.Context, projectID uint32, scanResults []*scanner.ScanResult) []*finding.Finding {
+	findings := make([]*finding.Finding, len(scanResults))
+	sem := make(chan struct{}, u.concurrency)
+	var wg sync.WaitGroup
+	for i, result := range scanResults {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			f, err := u.putFinding(ctx, projectID, result)
+			if err != nil {
+				u.review.logger.WarnContext(ctx, "Failed to put finding to RISKEN",
+					slog.String("file", result.File), slog.Int("line", result.Line), slog.String("scan_id", result.ScanID), slog.String("err", err.Error()))
+				result.RiskenStatus = RISKEN_STATUS_FAILED
+				if u.spoolFinding(ctx, projectID, result, err) {
+					result.RiskenStatus = RISKEN_STATUS_SPOOLED
+				}
+				return
+			}
+			u.unspoolFinding(ctx, projectID, result)
+			findings[i] = f
+			result.RiskenStatus = RISKEN_STATUS_UPLOADED
+			result.RiskenURL = scanner.GenerateRiskenURL(u.review.opt.RiskenConsoleURL, f.ProjectId, f.FindingId)
+		}()
+	}
+	wg.Wait()
+	return findings
+}
+
+func (u *riskenUploader) putFinding(ctx context.Context, projectID uint32, s *scanner.ScanResult) (*finding.Finding, error) {
+	var resp *finding.PutFindingResponse
+	err := u.retry(ctx, func() error {
+		var err error
+		resp, err = u.review.putFinding(ctx, projectID, s, u.tags)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Finding, nil
+}
+
+// spoolFinding writes the finding failed by the transient error to the spool, and returns true if spooled.
+// The spool is not written on the dry run, which has no side effects.
+func (u *riskenUploader) spoolFinding(ctx context.Context, projectID uint32, s *scanner.ScanResult, err error) bool {
+	if u.review.spool == nil || u.review.dryRun != nil || !isRetryableRiskenError(err) {
+		return false
+	}
+	sub, err := u.review.buildRiskenSubmission(projectID, s, u.tags)
+	if err == nil {
+		err = u.review.spool.write(sub)
+	}
+	if err != nil {
+		u.review.logger.WarnContext(ctx, "Failed to spool finding", slog.String("file", s.File), slog.Int("line", s.Line), slog.String("err", err.Error()))
+		return false
+	}
+	u.review.logger.InfoContext(ctx, "Spooled finding", slog.String("file", s.File), slog.Int("line", s.Line), slog.String("scan_id", s.ScanID))
+	return true
+}
+
+// unspoolFinding removes the finding spooled by the previous run, which is outdated by the put finding.
+// The finding is not put on the dry run, so the spool is kept.
+func (u *riskenUploader) unspoolFinding(ctx context.Context, projectID uint32, s *scanner.ScanResult) {
+	if u.review.spool == nil || u.review.dryRun != nil {
+		return
+	}
+	sub, err := u.review.buildRiskenSubmission(projectID, s, u.tags)
+	if err == nil {
+		err = u.review.spool.remove(sub.PutFinding)
+	}
+	if err != nil {
+		u.review.logger.WarnContext(ctx, "Failed to remove spooled finding", slog.String("file", s.File), slog.Int("line", s.Line), slog.String("err", err.Error()))
+	}
+}
+
+// retry calls the function again on the transient errors. PutFinding, PutRecommend and TagFinding are idempotent (upsert by the data source ID and the tag).
+func (u *riskenUploader) retry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryableRiskenError(err) || attempt >= u.maxRetries || ctx.Err() != nil {
+			return err
+		}
+		wait := jitteredBackoff(attempt, u.minBackoff, u.maxBackoff)
+		u.review.logger.WarnContext(ctx, "Retry RISKEN API request", slog.String("err", err.Error()), slog.Int("attempt", attempt+1), slog.Duration("wait", wait))
+		if err := u.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// isRetryableRiskenError returns true for the network errors, the rate limit and the server errors.
+func isRetryableRiskenError(err error) bool {
+	var apiErr risken.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status == http.StatusTooManyRequests || apiErr.Status >= http.StatusInternalServerError
+	}
+	var localErr *riskenRequestError
+	return !errors.As(err, &localErr)
+}
+
+// riskenRequestError is the error on building the request, which is not resolved by retrying.
+type riskenRequestError struct {
+	err error
+}
+
+func (e *riskenRequestError) Error() string {
+	return fmt.Sprintf("invalid RISKEN request: %v", e.err)
+}
+
+func (e *riskenRequestError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,246 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/go-risken"
+	"github.com/ca-risken/security-review/pkg/mocks"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRiskenUploader(t *testing.T) {
+	ctx := context.Background()
+	result := func(fingerprint string) *scanner.ScanResult {
+		return &scanner.ScanResult{ScanID: "rule", Fingerprint: fingerprint, Finding: &scanner.RuleFinding{RuleID: "rule", Repository: "owner/repo"}}
+	}
+	dataSourceID := func(id string) any {
+		return mock.MatchedBy(func(req *finding.PutFindingRequest) bool { return req.Finding.DataSourceId == id })
+	}
+	putResp := func(findingID uint64) *finding.PutFindingResponse {
+		return &finding.PutFindingResponse{Finding: &finding.Finding{FindingId: findingID, ProjectId: 1}}
+	}
+
+	m := mocks.NewRiskenClient(t)
+	// fp1: uploaded after retrying the server error
+	m.On("PutFinding", ctx, dataSourceID("fp1")).Return(nil, risken.APIError{Status: http.StatusServiceUnavailable}).Once()
+	m.On("PutFinding", ctx, dataSourceID("fp1")).Return(putResp(1), nil).Once()
+	// fp2: not retried on the client error
+	m.On("PutFinding", ctx, dataSourceID("fp2")).Return(nil, risken.APIError{Status: http.StatusBadRequest}).Once()
+	// fp3: retried until the max retries
+	m.On("PutFinding", ctx, dataSourceID("fp3")).Return(nil, errors.New("error calling the API endpoint: connection refused")).Times(riskenMaxRetries + 1)
+	// fp4: uploaded after retrying the recommendation
+	m.On("PutFinding", ctx, dataSourceID("fp4")).Return(putResp(4), nil).Twice()
+	m.On("PutRecommend", ctx, mock.MatchedBy(func(req *finding.PutRecommendRequest) bool { return req.FindingId == 4 })).
+		Return(nil, risken.APIError{Status: http.StatusTooManyRequests}).Once()
+	m.On("PutRecommend", ctx, mock.Anything).Return(&finding.PutRecommendResponse{}, nil).Twice()
+
+	results := []*scanner.ScanResult{result("fp1"), result("fp2"), result("fp3"), result("fp4"), {ScanID: "no finding"}}
+	r := &reviewService{
+		opt:          &ReviewOption{RiskenConsoleURL: "https://console.example.com"},
+		riskenClient: m,
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	u := newRiskenUploader(r, nil)
+	u.concurrency = 1 // to count the waits
+	var waits int
+	u.sleep = func(ctx context.Context, d time.Duration) error {
+		waits++
+		return nil
+	}
+	got := u.upload(ctx, 1, results)
+
+	if diff := cmp.Diff([]*finding.Finding{{FindingId: 1, ProjectId: 1}, nil, nil, {FindingId: 4, ProjectId: 1}, nil}, got, cmp.Comparer(func(a, b *finding.Finding) bool {
+		return a == nil && b == nil || a != nil && b != nil && a.FindingId == b.FindingId
+	})); diff != "" {
+		t.Errorf("upload() mismatch (-want +got):\n%s", diff)
+	}
+	var statuses, urls []string
+	for _, r := range results {
+		statuses = append(statuses, r.RiskenStatus)
+		urls = append(urls, r.RiskenURL)
+	}
+	wantStatuses := []string{RISKEN_STATUS_UPLOADED, RISKEN_STATUS_FAILED, RISKEN_STATUS_FAILED, RISKEN_STATUS_UPLOADED, RISKEN_STATUS_FAILED}
+	if diff := cmp.Diff(wantStatuses, statuses); diff != "" {
+		t.Errorf("RiskenStatus mismatch (-want +got):\n%s", diff)
+	}
+	wantURLs := []string{
+		scanner.GenerateRiskenURL("https://console.example.com", 1, 1), "", "",
+		scanner.GenerateRiskenURL("https://console.example.com", 1, 4), "",
+	}
+	if diff := cmp.Diff(wantURLs, urls); diff != "" {
+		t.Errorf("RiskenURL mismatch (-want +got):\n%s", diff)
+	}
+	if want := 1 + riskenMaxRetries + 1; waits != want {
+		t.Errorf("waits = %d, want %d", waits, want)
+	}
+}
+
+func TestIntegrateRisken(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name       string
+		signinErr  error
+		putErr     error
+		spool      bool
+		wantErr    bool
+		wantStatus string
+		want       reviewSummary
+	}{
+		{
+			name:       "OK",
+			wantStatus: RISKEN_STATUS_UPLOADED,
+			want:       reviewSummary{RiskenUploaded: 1},
+		},
+		{
+			name:       "Signin error",
+			signinErr:  risken.APIError{Status: http.StatusUnauthorized},
+			wantErr:    true,
+			wantStatus: RISKEN_STATUS_FAILED,
+			want:       reviewSummary{RiskenFailures: 1},
+		},
+		{
+			name:       "Signin error spooled",
+			signinErr:  risken.APIError{Status: http.StatusServiceUnavailable},
+			spool:      true,
+			wantStatus: RISKEN_STATUS_SPOOLED,
+			want:       reviewSummary{RiskenSpooled: 1},
+		},
+		{
+			name:       "Put error skips resolving",
+			putErr:     risken.APIError{Status: http.StatusBadRequest},
+			wantErr:    true,
+			wantStatus: RISKEN_STATUS_FAILED,
+			want:       reviewSummary{RiskenFailures: 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mocks.NewRiskenClient(t)
+			if tc.signinErr != nil {
+				m.On("Signin", ctx).Return(nil, tc.signinErr).Once()
+			} else {
+				m.On("Signin", ctx).Return(&risken.SigninResponse{ProjectID: 1}, nil).Once()
+				if tc.putErr == nil { // no triage lookup without the put findings
+					m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool { return req.Status == finding.FindingStatus_FINDING_PENDING })).
+						Return(&finding.ListFindingResponse{}, nil).Once()
+				}
+				if tc.putErr != nil {
+					m.On("PutFinding", ctx, mock.Anything).Return(nil, tc.putErr).Once()
+				} else {
+					m.On("PutFinding", ctx, mock.Anything).Return(&finding.PutFindingResponse{Finding: &finding.Finding{FindingId: 1, ProjectId: 1}}, nil).Once()
+					m.On("PutRecommend", ctx, mock.Anything).Return(&finding.PutRecommendResponse{}, nil).Once()
+					for _, tag := range []string{"repository:owner/repo", "head_branch:main", "team:security"} {
+						m.On("TagFinding", ctx, mock.MatchedBy(func(req *finding.TagFindingRequest) bool { return req.Tag.Tag == tag })).
+							Return(&finding.TagFindingResponse{}, nil).Once()
+					}
+					m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool { return cmp.Equal(req.Tag, []string{"head_branch:main"}) })).Return(&finding.ListFindingResponse{FindingId: []uint64{1}, Total: 1}, nil).Once()
+				}
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{RiskenTags: []string{"team:security"}},
+				riskenClient: m,
+				logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			if tc.spool {
+				r.spool = newRiskenSpool(t.TempDir())
+			}
+			result := &scanner.ScanResult{ScanID: "rule", Fingerprint: "fp", Finding: &scanner.RuleFinding{RuleID: "rule", Repository: "owner/repo"}}
+			target := &reviewTarget{Full: true, Ref: "refs/heads/main", Repository: &scm.Repository{FullName: "owner/repo", DefaultBranch: "main"}}
+			err := r.integrateRisken(ctx, target, []*scanner.ScanResult{result}, nil)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("integrateRisken() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if result.RiskenStatus != tc.wantStatus {
+				t.Errorf("RiskenStatus = %s, want %s", result.RiskenStatus, tc.wantStatus)
+			}
+			if diff := cmp.Diff(tc.want, r.summary); diff != "" {
+				t.Errorf("summary mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIntegrateRiskenFullScanKeepsPRFindings(t *testing.T) {
+	ctx := context.Background()
+	m := mocks.NewRiskenClient(t)
+	m.On("Signin", ctx).Return(&risken.SigninResponse{ProjectID: 1}, nil).Once()
+	// The finding only on the open PR (tagged pr:1, not head_branch:main) is not listed, so not resolved
+	m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool { return cmp.Equal(req.Tag, []string{"head_branch:main"}) })).
+		Return(&finding.ListFindingResponse{}, nil).Once()
+	r := &reviewService{
+		opt:          &ReviewOption{},
+		riskenClient: m,
+		logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	target := &reviewTarget{Full: true, Ref: "refs/heads/main", HeadSHA: "head", Repository: &scm.Repository{FullName: "owner/repo", DefaultBranch: "main"}}
+	if err := r.integrateRisken(ctx, target, nil, nil); err != nil {
+		t.Fatalf("integrateRisken() error = %v", err)
+	}
+	if r.summary.Resolved != 0 {
+		t.Errorf("Resolved = %d, want 0", r.summary.Resolved)
+	}
+}
+
+func TestIntegrateRiskenChangeRequest(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name         string
+		noPRComment  bool
+		carriedFiles []string
+		wantResolved int
+	}{
+		{name: "Resolve findings of the PR", wantResolved: 1},
+		{name: "Incremental review", carriedFiles: []string{"main.go"}, wantResolved: 1},
+		{name: "Incremental review without PR comments", noPRComment: true, carriedFiles: []string{"main.go"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := mocks.NewRiskenClient(t)
+			m.On("Signin", ctx).Return(&risken.SigninResponse{ProjectID: 1}, nil).Once()
+			if tc.wantResolved > 0 {
+				m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool { return cmp.Equal(req.Tag, []string{"pr:1"}) })).
+					Return(&finding.ListFindingResponse{FindingId: []uint64{1, 2, 3}, Total: 3}, nil).Once()
+				// 3: also reported on the default branch, resolved by the full scan after the merge
+				m.On("ListFinding", ctx, mock.MatchedBy(func(req *finding.ListFindingRequest) bool { return cmp.Equal(req.Tag, []string{"head_branch:main"}) })).
+					Return(&finding.ListFindingResponse{FindingId: []uint64{3}, Total: 1}, nil).Once()
+				m.On("GetFinding", ctx, &finding.GetFindingRequest{ProjectId: 1, FindingId: 1}).
+					Return(&finding.GetFindingResponse{Finding: &finding.Finding{FindingId: 1, DataSource: "code:codescan", DataSourceId: "fixed", Data: `{"fingerprint":"fixed"}`, OriginalScore: 0.6}}, nil).Once()
+				m.On("GetFinding", ctx, &finding.GetFindingRequest{ProjectId: 1, FindingId: 2}).
+					Return(&finding.GetFindingResponse{Finding: &finding.Finding{FindingId: 2, DataSource: "code:codescan", DataSourceId: "carried", Data: `{"fingerprint":"carried"}`, OriginalScore: 0.6}}, nil).Once()
+				m.On("PutFinding", ctx, mock.MatchedBy(func(req *finding.PutFindingRequest) bool {
+					return req.Finding.DataSourceId == "fixed" && req.Finding.OriginalScore == 0 && strings.Contains(req.Finding.Data, `"commit":"head"`)
+				})).Return(&finding.PutFindingResponse{}, nil).Once()
+			}
+			r := &reviewService{
+				opt:          &ReviewOption{NoPRComment: tc.noPRComment},
+				riskenClient: m,
+				logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			repo := &scm.Repository{FullName: "owner/repo", DefaultBranch: "main"}
+			target := &reviewTarget{
+				Repository:    repo,
+				HeadSHA:       "head",
+				ChangeRequest: &scm.ChangeRequest{Number: 1, Repository: repo},
+				CarriedFiles:  tc.carriedFiles,
+			}
+			carried := []*scanner.ScanResult{{File: "main.go", Fingerprint: "carried"}}
+			if err := r.integrateRisken(ctx, target, nil, carried); err != nil {
+				t.Fatalf("integrateRisken() error = %v", err)
+			}
+			if r.summary.Resolved != tc.wantResolved {
+				t.Errorf("Resolved = %d, want %d", r.summary.Resolved, tc.wantResolved)
+			}
+		})
+	}
+}
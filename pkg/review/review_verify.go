@@ -0,0 +1,78 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/security-review/pkg/scanner"
+	"github.com/ca-risken/security-review/pkg/verify"
+)
+
+const (
+	verifyContextLines = 5 // lines around the secret to find the paired credential (e.g. AWS secret access key)
+
+	LIVE_SECRET_SCORE    float32 = 1.0
+	INVALID_SECRET_SCORE float32 = 0.1
+)
+
+// verifySecrets verifies the liveness of the detected secrets and stores the results on the scan results.
+func (r *reviewService) verifySecrets(ctx context.Context, scanResults []*scanner.ScanResult) error {
+	verifiers, err := verify.NewVerifiers(r.opt.VerifyEndpoints, nil)
+	if err != nil {
+		return fmt.Errorf("failed to initialize verifiers: %w", err)
+	}
+	for _, s := range scanResults {
+		secret := s.Secret()
+		if secret == "" {
+			continue
+		}
+		c := &verify.Credential{
+			Secret:  secret,
+			Context: r.readContextLines(ctx, s.File, s.Line),
+		}
+		result := verify.Verify(ctx, verifiers, c)
+		if result == nil {
+			continue
+		}
+		r.logger.InfoContext(ctx, "Verified secret",
+			slog.String("file", s.File), slog.Int("line", s.Line),
+			slog.String("verifier", result.Verifier), slog.String("status", string(result.Status)))
+		s.ApplyVerification(result)
+	}
+	return nil
+}
+
+func (r *reviewService) readContextLines(ctx context.Context, file string, line int) []string {
+	buf, err := os.ReadFile(fmt.Sprintf("%s/%s", r.opt.GithubWorkspace, file))
+	if err != nil {
+		r.logger.WarnContext(ctx, "Failed to read file for verification", slog.String("file", file), slog.String("err", err.Error()))
+		return nil
+	}
+	lines := strings.Split(string(buf), "\n")
+	start := max(line-1-verifyContextLines, 0)
+	end := min(line+verifyContextLines, len(lines))
+	if start >= end {
+		return nil
+	}
+	return lines[start:end]
+}
+
+// applyVerification raises the score of the live secret (the critical severity) and downgrades the invalid one, and attaches the result to the finding data.
+func applyVerification(req *finding.PutFindingRequest, s *scanner.ScanResult) error {
+	v := s.Verification
+	if v == nil {
+		return nil
+	}
+	switch {
+	case v.Status == verify.STATUS_INVALID:
+		req.Finding.OriginalScore = INVALID_SECRET_SCORE
+	case s.Severity() == scanner.SEVERITY_CRITICAL:
+		req.Finding.OriginalScore = LIVE_SECRET_SCORE
+	}
+	req.Finding.Description = fmt.Sprintf("%s (verified: %s by %s)", req.Finding.Description, v.Status, v.Verifier)
+	return setFindingData(req, "verification", v)
+}
@@ -11,6 +11,10 @@ type RiskenClient interface {
 	Signin(ctx context.Context) (*risken.SigninResponse, error)
 	PutFinding(ctx context.Context, req *finding.PutFindingRequest) (*finding.PutFindingResponse, error)
 	PutRecommend(ctx context.Context, req *finding.PutRecommendRequest) (*finding.PutRecommendResponse, error)
+	ListFinding(ctx context.Context, req *finding.ListFindingRequest) (*finding.ListFindingResponse, error)
+	GetFinding(ctx context.Context, req *finding.GetFindingRequest) (*finding.GetFindingResponse, error)
+	GetPendFinding(ctx context.Context, req *finding.GetPendFindingRequest) (*finding.GetPendFindingResponse, error)
+	TagFinding(ctx context.Context, req *finding.TagFindingRequest) (*finding.TagFindingResponse, error)
 }
 
 type riskenClient struct {
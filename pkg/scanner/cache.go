@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// DEFAULT_CACHE_MAX_AGE expires the cached results to pick up the rule updates of the remote ruleset (e.g. Semgrep registry).
+const DEFAULT_CACHE_MAX_AGE = 7 * 24 * time.Hour
+
+// ResultCache is the content-addressed cache of the per-file scanner results.
+// The nil cache is valid and always misses.
+type ResultCache struct {
+	dir    string
+	maxAge time.Duration
+	logger *slog.Logger
+	now    func() time.Time
+	hits   int
+	misses int
+}
+
+func NewResultCache(dir string, logger *slog.Logger) *ResultCache {
+	return &ResultCache{
+		dir:    dir,
+		maxAge: DEFAULT_CACHE_MAX_AGE,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// cacheKey returns the key of the file content scanned by the scanner version and the ruleset.
+// The path is included because the rules may depend on the file name (language detection, path filters).
+func cacheKey(scanner, version, ruleset, path string, content []byte) string {
+	h := sha256.New()
+	for _, part := range []string{scanner, version, ruleset, path, blobSHA(content)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// blobSHA returns the git blob SHA of the content.
+func blobSHA(content []byte) string {
+	h := sha1.New()
+	h.Write([]byte(fmt.Sprintf("blob %d\x00", len(content))))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// moduleVersion returns the version of the dependency module, or "unknown" if the build info is not available.
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == path {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+func (c *ResultCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+// Get decodes the cached result into v, and returns true on hit.
+func (c *ResultCache) Get(ctx context.Context, key string, v any) bool {
+	if c == nil {
+		return false
+	}
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil || c.now().Sub(info.ModTime()) > c.maxAge {
+		c.misses++
+		return false
+	}
+	content, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(content, v)
+	}
+	if err != nil {
+		c.logger.WarnContext(ctx, "Failed to read the cache", slog.String("path", path), slog.String("err", err.Error()))
+		c.misses++
+		return false
+	}
+	c.hits++
+	return true
+}
+
+// Put stores the result. The failure only disables the cache for the result, not the scan.
+func (c *ResultCache) Put(ctx context.Context, key string, v any) {
+	if c == nil {
+		return
+	}
+	path := c.path(key)
+	if err := writeFileAtomic(path, v); err != nil {
+		c.logger.WarnContext(ctx, "Failed to write the cache", slog.String("path", path), slog.String("err", err.Error()))
+	}
+}
+
+func writeFileAtomic(path string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // no-op after the rename
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LogStats logs the hit/miss stats.
+func (c *ResultCache) LogStats(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	c.logger.InfoContext(ctx, "Scan cache stats", slog.String("dir", c.dir), slog.Int("hits", c.hits), slog.Int("misses", c.misses))
+}
+
+// relativizePaths replaces the absolute paths of the workspace to share the cached results across the workspaces.
+// Nothing is replaced for the current directory, of which the prefix (./) also matches the parent paths (../).
+func relativizePaths(sourceCodePath, s string) string {
+	dir := filepath.Clean(sourceCodePath)
+	if dir == "." {
+		return s
+	}
+	return strings.ReplaceAll(s, dir+"/", "")
+}
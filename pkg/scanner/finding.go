@@ -0,0 +1,181 @@
+package scanner
+
+import (
+	"strings"
+
+	"github.com/ca-risken/code/pkg/codescan"
+	"github.com/ca-risken/code/pkg/gitleaks"
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/datasource-api/pkg/message"
+)
+
+// Finding is a finding detected by a scanner.
+// The review (PR comments, RISKEN findings and the output) only depends on this interface,
+// so a new scanner is integrated by returning its findings as ScanResult.Finding.
+type Finding interface {
+	// Fingerprint is the ID of the finding given by the scanner, which may depend on the line number.
+	// The review identifies the findings by ScanResult.Fingerprint instead.
+	Fingerprint() string
+	// Scanner is the name of the scanner which detected the finding, one of SCANNER_* or RULE_SCANNER_*.
+	Scanner() string
+	// Severity is one of SEVERITY_*.
+	Severity() string
+	// CWE is the CWE IDs (e.g. CWE-89) of the finding, or empty if unknown.
+	CWE() []string
+	// DataSource is the RISKEN data source.
+	DataSource() string
+	PutFindingRequest(projectID uint32) (*finding.PutFindingRequest, error)
+	PutRecommendRequest(projectID uint32, findingID uint64) *finding.PutRecommendRequest
+	// ReviewComment is the body of the PR review comment.
+	ReviewComment() string
+}
+
+// SecretFinding is a finding of the detected secret, which is verified by the secret verifiers.
+type SecretFinding interface {
+	Finding
+	// Secret is the raw secret, never sent outside except to the verifiers.
+	Secret() string
+}
+
+// SemgrepFinding is a finding detected by SemgrepScanner.
+type SemgrepFinding struct {
+	*codescan.SemgrepFinding
+}
+
+func (f *SemgrepFinding) Fingerprint() string {
+	return codescan.GenerateDataSourceIDForSemgrep(f.SemgrepFinding)
+}
+
+func (f *SemgrepFinding) Scanner() string {
+	return SCANNER_SEMGREP
+}
+
+func (f *SemgrepFinding) Severity() string {
+	switch f.Extra.Severity {
+	case "ERROR":
+		return SEVERITY_HIGH
+	case "WARNING":
+		return SEVERITY_MEDIUM
+	case "INFO":
+		return SEVERITY_LOW
+	default:
+		return SEVERITY_UNKNOWN
+	}
+}
+
+// CWE returns the CWE IDs in the Semgrep rule metadata.
+func (f *SemgrepFinding) CWE() []string {
+	if f.Extra == nil {
+		return nil
+	}
+	meta, err := parseSemgrepMetadata(f.Extra.Metadata)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, cwe := range meta.CWE {
+		// e.g. "CWE-89: Improper Neutralization of Special Elements used in an SQL Command ('SQL Injection')"
+		id, _, _ := strings.Cut(cwe, ":")
+		ids = append(ids, strings.TrimSpace(id))
+	}
+	return ids
+}
+
+func (f *SemgrepFinding) DataSource() string {
+	return message.CodeScanDataSource
+}
+
+func (f *SemgrepFinding) PutFindingRequest(projectID uint32) (*finding.PutFindingRequest, error) {
+	return codescan.GeneratePutFindingRequest(projectID, f.SemgrepFinding)
+}
+
+func (f *SemgrepFinding) PutRecommendRequest(projectID uint32, findingID uint64) *finding.PutRecommendRequest {
+	recommendContent := codescan.GetSemgrepRecommend(
+		f.Repository,
+		f.Path,
+		f.CheckID,
+		f.Extra.Message,
+		f.GitHubURL,
+		f.Extra.Lines,
+	)
+	return &finding.PutRecommendRequest{
+		ProjectId:      projectID,
+		FindingId:      findingID,
+		DataSource:     f.DataSource(),
+		Type:           f.Fingerprint(),
+		Risk:           recommendContent.Risk,
+		Recommendation: recommendContent.Recommendation,
+	}
+}
+
+func (f *SemgrepFinding) ReviewComment() string {
+	return generateSemgrepReviewComment(f.SemgrepFinding)
+}
+
+// GitleaksFinding is a finding detected by GitleaksScanner.
+type GitleaksFinding struct {
+	*gitleaks.GitleaksFinding
+}
+
+func (f *GitleaksFinding) Fingerprint() string {
+	return f.Result.DataSourceID
+}
+
+func (f *GitleaksFinding) Scanner() string {
+	return SCANNER_GITLEAKS
+}
+
+func (f *GitleaksFinding) Severity() string {
+	return SEVERITY_HIGH
+}
+
+func (f *GitleaksFinding) CWE() []string {
+	return nil
+}
+
+func (f *GitleaksFinding) DataSource() string {
+	return message.GitleaksDataSource
+}
+
+func (f *GitleaksFinding) PutFindingRequest(projectID uint32) (*finding.PutFindingRequest, error) {
+	return gitleaks.GeneratePutFindingRequest(projectID, f.GitleaksFinding)
+}
+
+func (f *GitleaksFinding) PutRecommendRequest(projectID uint32, findingID uint64) *finding.PutRecommendRequest {
+	visibility := ""
+	if f.Visibility != nil {
+		visibility = *f.Visibility
+	}
+	recommendContent := gitleaks.GetRecommend(
+		f.Result.RuleDescription,
+		f.Result.Repo,
+		f.Result.File,
+		visibility,
+		f.Result.URL,
+		f.Result.Author,
+		f.Result.Email,
+	)
+	return &finding.PutRecommendRequest{
+		ProjectId:      projectID,
+		FindingId:      findingID,
+		DataSource:     f.DataSource(),
+		Type:           f.Result.RuleDescription,
+		Risk:           recommendContent.Risk,
+		Recommendation: recommendContent.Recommendation,
+	}
+}
+
+func (f *GitleaksFinding) ReviewComment() string {
+	return generateGitleaksReviewComment(f.Result)
+}
+
+func (f *GitleaksFinding) Secret() string {
+	return f.Result.Secret
+}
+
+// The names of the scanners other than the rule based scanners (RULE_SCANNER_*).
+const (
+	SCANNER_SEMGREP    = "semgrep"
+	SCANNER_GITLEAKS   = "gitleaks"
+	SCANNER_DEPENDENCY = "dependency"
+)
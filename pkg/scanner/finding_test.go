@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/ca-risken/code/pkg/codescan"
+	"github.com/ca-risken/code/pkg/gitleaks"
+	"github.com/ca-risken/datasource-api/pkg/message"
+	"github.com/ca-risken/security-review/pkg/verify"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-github/v44/github"
+)
+
+func TestFinding(t *testing.T) {
+	semgrep := func(severity string) *SemgrepFinding {
+		return &SemgrepFinding{SemgrepFinding: &codescan.SemgrepFinding{
+			Repository: "owner/repo",
+			Path:       "main.go",
+			CheckID:    "go.lang.security.audit.sqli",
+			Start:      &codescan.SemgrepLine{Line: 1},
+			End:        &codescan.SemgrepLine{Line: 1},
+			Extra: &codescan.SemgrepExtra{Severity: severity, Message: "message", Lines: "lines", Metadata: map[string]any{
+				"cwe": []string{"CWE-89: Improper Neutralization of Special Elements used in an SQL Command ('SQL Injection')"},
+			}},
+		}}
+	}
+	testCases := []struct {
+		name           string
+		finding        Finding
+		wantSeverity   string
+		wantDataSource string
+		wantSecret     string
+		wantScanner    string
+		wantCWE        []string
+	}{
+		{name: "Semgrep ERROR", finding: semgrep("ERROR"), wantSeverity: SEVERITY_HIGH, wantDataSource: message.CodeScanDataSource, wantScanner: SCANNER_SEMGREP, wantCWE: []string{"CWE-89"}},
+		{name: "Semgrep WARNING", finding: semgrep("WARNING"), wantSeverity: SEVERITY_MEDIUM, wantDataSource: message.CodeScanDataSource, wantScanner: SCANNER_SEMGREP, wantCWE: []string{"CWE-89"}},
+		{name: "Semgrep INFO", finding: semgrep("INFO"), wantSeverity: SEVERITY_LOW, wantDataSource: message.CodeScanDataSource, wantScanner: SCANNER_SEMGREP, wantCWE: []string{"CWE-89"}},
+		{name: "Semgrep unknown", finding: semgrep(""), wantSeverity: SEVERITY_UNKNOWN, wantDataSource: message.CodeScanDataSource, wantScanner: SCANNER_SEMGREP, wantCWE: []string{"CWE-89"}},
+		{
+			name: "Gitleaks",
+			finding: &GitleaksFinding{GitleaksFinding: &gitleaks.GitleaksFinding{
+				RepositoryMetadata: &gitleaks.RepositoryMetadata{FullName: github.String("owner/repo"), Visibility: github.String("private"), Language: github.String("Go")},
+				Result:             &gitleaks.LeakFinding{DataSourceID: "id", RuleDescription: "GitHub Personal Access Token", Secret: "ghp_xxx"},
+			}},
+			wantSeverity:   SEVERITY_HIGH,
+			wantDataSource: message.GitleaksDataSource,
+			wantSecret:     "ghp_xxx",
+			wantScanner:    SCANNER_GITLEAKS,
+		},
+		{
+			name:           "Rule (entropy)",
+			finding:        &RuleFinding{ScannerName: RULE_SCANNER_ENTROPY, RuleID: "HIGH_ENTROPY_STRING", Level: SEVERITY_HIGH, RawSecret: "secret"},
+			wantSeverity:   SEVERITY_HIGH,
+			wantDataSource: ENTROPY_DATA_SOURCE,
+			wantSecret:     "secret",
+			wantScanner:    RULE_SCANNER_ENTROPY,
+		},
+		{
+			name:           "Dependency",
+			finding:        &DependencyFinding{PackageName: "example.com/vuln", Level: SEVERITY_CRITICAL},
+			wantSeverity:   SEVERITY_CRITICAL,
+			wantDataSource: message.DependencyDataSource,
+			wantScanner:    SCANNER_DEPENDENCY,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.finding.Severity(); got != tc.wantSeverity {
+				t.Errorf("Severity() = %s, want %s", got, tc.wantSeverity)
+			}
+			if got := tc.finding.DataSource(); got != tc.wantDataSource {
+				t.Errorf("DataSource() = %s, want %s", got, tc.wantDataSource)
+			}
+			req, err := tc.finding.PutFindingRequest(1)
+			if err != nil {
+				t.Fatalf("PutFindingRequest() error = %v", err)
+			}
+			if req.Finding.DataSource != tc.wantDataSource || req.Finding.DataSourceId != tc.finding.Fingerprint() {
+				t.Errorf("PutFindingRequest() data_source = %s, data_source_id = %s, want %s, %s",
+					req.Finding.DataSource, req.Finding.DataSourceId, tc.wantDataSource, tc.finding.Fingerprint())
+			}
+			if rec := tc.finding.PutRecommendRequest(1, 2); rec.FindingId != 2 || rec.DataSource != tc.wantDataSource {
+				t.Errorf("PutRecommendRequest() finding_id = %d, data_source = %s, want 2, %s", rec.FindingId, rec.DataSource, tc.wantDataSource)
+			}
+			if tc.finding.ReviewComment() == "" {
+				t.Errorf("ReviewComment() is empty")
+			}
+			result := &ScanResult{Finding: tc.finding}
+			if secret := result.Secret(); secret != tc.wantSecret {
+				t.Errorf("Secret() = %s, want %s", secret, tc.wantSecret)
+			}
+			if name := tc.finding.Scanner(); name != tc.wantScanner {
+				t.Errorf("Scanner() = %s, want %s", name, tc.wantScanner)
+			}
+			if diff := cmp.Diff(tc.wantCWE, tc.finding.CWE()); diff != "" {
+				t.Errorf("CWE() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestScanResultSeverity(t *testing.T) {
+	testCases := []struct {
+		name   string
+		result *ScanResult
+		want   string
+	}{
+		{name: "Severity of the finding", result: &ScanResult{Finding: &GitleaksFinding{}}, want: SEVERITY_HIGH},
+		{name: "Live secret", result: &ScanResult{Finding: &GitleaksFinding{}, Verification: &verify.Result{Status: verify.STATUS_LIVE}}, want: SEVERITY_CRITICAL},
+		{name: "Invalid secret", result: &ScanResult{Finding: &GitleaksFinding{}, Verification: &verify.Result{Status: verify.STATUS_INVALID}}, want: SEVERITY_HIGH},
+		{name: "No finding", result: &ScanResult{}, want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.result.Severity(); got != tc.want {
+				t.Errorf("Severity() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
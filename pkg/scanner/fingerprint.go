@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// AssignFingerprints sets the fingerprints of the scan results.
+// The fingerprint consists of the rule ID, the file path, the code without the whitespaces and the occurrence index of the same code in the file,
+// so it does not change when the lines are inserted or removed above the finding, or the indentation changes.
+// The occurrence index is counted over the whole file content in the source code path, not over the results,
+// because the diff scan reports only the added lines (the same code added below the existing one must not get the index 0).
+// It falls back to the index among the results if the code is not found in the file (e.g. the masked secret).
+func AssignFingerprints(sourceCodePath string, results []*ScanResult) {
+	sorted := slices.Clone(results)
+	slices.SortStableFunc(sorted, func(a, b *ScanResult) int {
+		return cmp.Compare(a.Line, b.Line)
+	})
+	files := map[string]*normalizedFile{}
+	occurrences := map[string]int{}
+	sameLine := map[string]int{} // the same code on the same line
+	for _, r := range sorted {
+		code := normalizeCode(r.DiffHunk)
+		key := strings.Join([]string{r.ScanID, r.File, code}, "\x00")
+		f, ok := files[r.File]
+		if !ok {
+			f = loadNormalizedFile(filepath.Join(sourceCodePath, r.File))
+			files[r.File] = f
+		}
+		index, found := f.occurrencesBefore(code, r.Line)
+		if found {
+			lineKey := fmt.Sprintf("%s\x00%d", key, r.Line)
+			index += sameLine[lineKey]
+			sameLine[lineKey]++
+		} else {
+			index = occurrences[key]
+			occurrences[key]++
+		}
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, index)))
+		r.Fingerprint = hex.EncodeToString(hash[:])
+	}
+}
+
+// normalizeCode removes all whitespaces (indentation, line breaks) of the code.
+func normalizeCode(code string) string {
+	return strings.Join(strings.Fields(code), "")
+}
+
+// normalizedFile is the file content without the whitespaces, to find the code across the lines.
+type normalizedFile struct {
+	content string
+	lines   []int // line number of each byte of the content
+}
+
+// loadNormalizedFile returns nil if the file is not readable (e.g. deleted).
+func loadNormalizedFile(path string) *normalizedFile {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	f := &normalizedFile{}
+	var b strings.Builder
+	for i, line := range strings.Split(string(content), "\n") {
+		code := normalizeCode(line)
+		b.WriteString(code)
+		for range len(code) {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	f.content = b.String()
+	return f
+}
+
+// occurrencesBefore returns the number of the occurrences of the code ending before the line, and false if the code is not in the file.
+func (f *normalizedFile) occurrencesBefore(code string, line int) (int, bool) {
+	if f == nil || code == "" {
+		return 0, false
+	}
+	count, found := 0, false
+	for offset := 0; offset < len(f.content); {
+		i := strings.Index(f.content[offset:], code)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		found = true
+		if f.lines[start+len(code)-1] < line {
+			count++
+		}
+		offset = start + 1
+	}
+	return count, found
+}
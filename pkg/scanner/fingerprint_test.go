@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssignFingerprints(t *testing.T) {
+	fingerprints := func(results ...*ScanResult) []string {
+		AssignFingerprints(t.TempDir(), results) // the files are not found, the index is counted over the results
+		var got []string
+		for _, r := range results {
+			got = append(got, r.Fingerprint)
+		}
+		return got
+	}
+	base := fingerprints(
+		&ScanResult{ScanID: "rule1", File: "main.go", Line: 10, DiffHunk: "exec(cmd)"},
+		&ScanResult{ScanID: "rule1", File: "main.go", Line: 20, DiffHunk: "exec(cmd)"},
+		&ScanResult{ScanID: "rule2", File: "main.go", Line: 10, DiffHunk: "exec(cmd)"},
+	)
+
+	testCases := []struct {
+		name    string
+		results []*ScanResult
+		same    []bool // whether the fingerprint is the same as the base
+	}{
+		{
+			name: "Lines shifted and indentation changed",
+			results: []*ScanResult{
+				{ScanID: "rule1", File: "main.go", Line: 15, DiffHunk: "\t\texec(cmd)"},
+				{ScanID: "rule1", File: "main.go", Line: 25, DiffHunk: "exec( cmd )\n"},
+				{ScanID: "rule2", File: "main.go", Line: 15, DiffHunk: "  exec(cmd)"},
+			},
+			same: []bool{true, true, true},
+		},
+		{
+			name: "Occurrence index by line order",
+			results: []*ScanResult{
+				{ScanID: "rule1", File: "main.go", Line: 30, DiffHunk: "exec(cmd)"}, // second occurrence
+				{ScanID: "rule1", File: "main.go", Line: 5, DiffHunk: "exec(cmd)"},  // first occurrence
+				{ScanID: "rule2", File: "main.go", Line: 1, DiffHunk: "exec(cmd)"},
+			},
+			same: []bool{false, false, true},
+		},
+		{
+			name: "Different code and file",
+			results: []*ScanResult{
+				{ScanID: "rule1", File: "main.go", Line: 10, DiffHunk: "exec(userInput)"},
+				{ScanID: "rule1", File: "sub/main.go", Line: 20, DiffHunk: "exec(cmd)"},
+				{ScanID: "rule2", File: "main.go", Line: 10, DiffHunk: "exec(cmd)"},
+			},
+			same: []bool{false, false, true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fingerprints(tc.results...)
+			for i := range got {
+				if (got[i] == base[i]) != tc.same[i] {
+					t.Errorf("fingerprint[%d] = %s, base = %s, want same = %v", i, got[i], base[i], tc.same[i])
+				}
+			}
+		})
+	}
+	if base[0] == base[1] {
+		t.Errorf("fingerprints of the same code in the file must differ by the occurrence index: %s", base[0])
+	}
+}
+
+func TestAssignFingerprintsByFileContent(t *testing.T) {
+	dir := t.TempDir()
+	content := "package main\n\nfunc a() {\n\texec(cmd)\n}\n\nfunc b() {\n\texec(\n\t\tcmd)\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Full scan reports both occurrences
+	full := []*ScanResult{
+		{ScanID: "rule1", File: "main.go", Line: 4, DiffHunk: "exec(cmd)"},
+		{ScanID: "rule1", File: "main.go", Line: 9, DiffHunk: "exec(\n\t\tcmd)"},
+	}
+	AssignFingerprints(dir, full)
+	if full[0].Fingerprint == full[1].Fingerprint {
+		t.Fatalf("fingerprints of the same code in the file must differ: %s", full[0].Fingerprint)
+	}
+	// Diff scan reports only the second one added in the PR
+	diff := []*ScanResult{{ScanID: "rule1", File: "main.go", Line: 9, DiffHunk: "exec(cmd)"}}
+	AssignFingerprints(dir, diff)
+	if diff[0].Fingerprint != full[1].Fingerprint {
+		t.Errorf("fingerprint of the diff scan = %s, want %s (the second occurrence in the file)", diff[0].Fingerprint, full[1].Fingerprint)
+	}
+	// The same code twice on the same line
+	sameLine := []*ScanResult{
+		{ScanID: "rule1", File: "main.go", Line: 4, DiffHunk: "exec(cmd)"},
+		{ScanID: "rule1", File: "main.go", Line: 4, DiffHunk: "exec(cmd)"},
+	}
+	AssignFingerprints(dir, sameLine)
+	if sameLine[0].Fingerprint != full[0].Fingerprint || sameLine[1].Fingerprint == full[0].Fingerprint {
+		t.Errorf("fingerprints on the same line = %s, %s", sameLine[0].Fingerprint, sameLine[1].Fingerprint)
+	}
+}
@@ -0,0 +1,329 @@
+package scanner
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// osvAdvisory is a subset of the OSV schema.
+// ref: https://ossf.github.io/osv-schema/
+type osvAdvisory struct {
+	ID               string             `json:"id"`
+	Aliases          []string           `json:"aliases,omitempty"`
+	Summary          string             `json:"summary,omitempty"`
+	Details          string             `json:"details,omitempty"`
+	Affected         []*osvAffected     `json:"affected,omitempty"`
+	DatabaseSpecific *osvDatabaseDetail `json:"database_specific,omitempty"`
+	References       []*osvReference    `json:"references,omitempty"`
+}
+
+type osvAffected struct {
+	Package  *osvPackage `json:"package,omitempty"`
+	Ranges   []*osvRange `json:"ranges,omitempty"`
+	Versions []string    `json:"versions,omitempty"`
+}
+
+type osvPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+type osvRange struct {
+	Type   string      `json:"type"`
+	Events []*osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+type osvDatabaseDetail struct {
+	Severity string `json:"severity,omitempty"`
+}
+
+type osvReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// vulnerabilityID returns the CVE alias if exists, otherwise the OSV ID.
+func (a *osvAdvisory) vulnerabilityID() string {
+	for _, alias := range a.Aliases {
+		if strings.HasPrefix(alias, "CVE-") {
+			return alias
+		}
+	}
+	return a.ID
+}
+
+func (a *osvAdvisory) severity() string {
+	if a.DatabaseSpecific == nil || a.DatabaseSpecific.Severity == "" {
+		return SEVERITY_UNKNOWN
+	}
+	severity := strings.ToUpper(a.DatabaseSpecific.Severity)
+	if severity == "MODERATE" {
+		return SEVERITY_MEDIUM
+	}
+	return severity
+}
+
+// advisoryDB is an in-memory index of OSV advisories keyed by ecosystem and package name.
+type advisoryDB struct {
+	advisories map[string][]*osvAdvisory
+}
+
+// loadAdvisoryDB loads OSV advisories from a directory or a zip archive (e.g. https://osv-vulnerabilities.storage.googleapis.com/Go/all.zip).
+// A directory may also contain zip archives.
+func loadAdvisoryDB(path string) (*advisoryDB, error) {
+	db := &advisoryDB{advisories: map[string][]*osvAdvisory{}}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat advisory db: path=%s, err=%w", path, err)
+	}
+	if !info.IsDir() {
+		if err := db.loadZip(path); err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(p) {
+		case ".json":
+			buf, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return db.load(p, buf)
+		case ".zip":
+			return db.loadZip(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load advisory db: path=%s, err=%w", path, err)
+	}
+	return db, nil
+}
+
+func (db *advisoryDB) loadZip(path string) (err error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open advisory archive: path=%s, err=%w", path, err)
+	}
+	defer func() {
+		if closeErr := r.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close advisory archive: path=%s, err=%w", path, closeErr)
+		}
+	}()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || filepath.Ext(f.Name) != ".json" {
+			continue
+		}
+		buf, err := readZipFile(f)
+		if err != nil {
+			return fmt.Errorf("failed to read %s in %s: %w", f.Name, path, err)
+		}
+		if err := db.load(f.Name, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := io.ReadAll(rc)
+	if closeErr := rc.Close(); err == nil {
+		err = closeErr
+	}
+	return buf, err
+}
+
+func (db *advisoryDB) load(name string, buf []byte) error {
+	var advisory osvAdvisory
+	if err := json.Unmarshal(buf, &advisory); err != nil {
+		return fmt.Errorf("failed to decode advisory: file=%s, err=%w", name, err)
+	}
+	for _, affected := range advisory.Affected {
+		if affected.Package == nil {
+			continue
+		}
+		key := advisoryKey(affected.Package.Ecosystem, affected.Package.Name)
+		db.advisories[key] = append(db.advisories[key], &advisory)
+	}
+	return nil
+}
+
+// advisoryMatch is an advisory that affects a specific dependency version.
+type advisoryMatch struct {
+	advisory     *osvAdvisory
+	fixedVersion string
+}
+
+// lookup returns the advisories affecting the version of the package.
+func (db *advisoryDB) lookup(ecosystem, name, version string) []*advisoryMatch {
+	key := advisoryKey(ecosystem, name)
+	var matches []*advisoryMatch
+	for _, advisory := range db.advisories[key] {
+		for _, affected := range advisory.Affected {
+			if affected.Package == nil || advisoryKey(affected.Package.Ecosystem, affected.Package.Name) != key {
+				continue
+			}
+			if hit, fixed := isAffectedVersion(affected, version); hit {
+				matches = append(matches, &advisoryMatch{advisory: advisory, fixedVersion: fixed})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+var pypiNameRegexp = regexp.MustCompile(`[-_.]+`)
+
+func advisoryKey(ecosystem, name string) string {
+	if ecosystem == ECOSYSTEM_PYPI {
+		// https://peps.python.org/pep-0503/#normalized-names
+		name = pypiNameRegexp.ReplaceAllString(strings.ToLower(name), "-")
+	}
+	return ecosystem + "/" + name
+}
+
+// isAffectedVersion evaluates the affected versions and ranges, and returns the first fixed version above the version.
+func isAffectedVersion(affected *osvAffected, version string) (bool, string) {
+	for _, v := range affected.Versions {
+		if compareVersion(v, version) == 0 {
+			return true, fixedVersion(affected, version)
+		}
+	}
+	for _, r := range affected.Ranges {
+		if r.Type != "SEMVER" && r.Type != "ECOSYSTEM" {
+			continue // GIT ranges can not be evaluated without the repository
+		}
+		affectedInRange := false
+		for _, e := range r.Events {
+			switch {
+			case e.Introduced != "":
+				if e.Introduced == "0" || compareVersion(version, e.Introduced) >= 0 {
+					affectedInRange = true
+				}
+			case e.Fixed != "":
+				if compareVersion(version, e.Fixed) >= 0 {
+					affectedInRange = false
+				}
+			case e.LastAffected != "":
+				if compareVersion(version, e.LastAffected) > 0 {
+					affectedInRange = false
+				}
+			}
+		}
+		if affectedInRange {
+			return true, fixedVersion(affected, version)
+		}
+	}
+	return false, ""
+}
+
+func fixedVersion(affected *osvAffected, version string) string {
+	fixed := ""
+	for _, r := range affected.Ranges {
+		for _, e := range r.Events {
+			if e.Fixed == "" || compareVersion(e.Fixed, version) <= 0 {
+				continue
+			}
+			if fixed == "" || compareVersion(e.Fixed, fixed) < 0 {
+				fixed = e.Fixed
+			}
+		}
+	}
+	return fixed
+}
+
+// compareVersion compares the versions loosely based on semantic versioning.
+// It returns -1 if a < b, 0 if a == b, 1 if a > b.
+func compareVersion(a, b string) int {
+	a, aPre := splitVersion(a)
+	b, bPre := splitVersion(b)
+	if c := compareSegments(strings.Split(a, "."), strings.Split(b, ".")); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1 // release > pre-release
+	case bPre == "":
+		return -1
+	}
+	return compareSegments(strings.Split(aPre, "."), strings.Split(bPre, "."))
+}
+
+func splitVersion(v string) (string, string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i] // build metadata
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+func compareSegments(a, b []string) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var as, bs string
+		if i < len(a) {
+			as = a[i]
+		}
+		if i < len(b) {
+			bs = b[i]
+		}
+		if c := compareSegment(as, bs); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+var segmentRegexp = regexp.MustCompile(`^(\d*)(.*)$`)
+
+// compareSegment compares the numeric prefix, then the suffix (e.g. "0rc1" < "0").
+func compareSegment(a, b string) int {
+	am := segmentRegexp.FindStringSubmatch(a)
+	bm := segmentRegexp.FindStringSubmatch(b)
+	an, _ := strconv.Atoi(am[1])
+	bn, _ := strconv.Atoi(bm[1])
+	switch {
+	case an < bn:
+		return -1
+	case an > bn:
+		return 1
+	case am[2] == bm[2]:
+		return 0
+	case am[2] == "":
+		return 1
+	case bm[2] == "":
+		return -1
+	case am[2] < bm[2]:
+		return -1
+	}
+	return 1
+}
@@ -3,29 +3,45 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
-	"github.com/google/go-github/v44/github"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/ca-risken/security-review/pkg/verify"
+)
+
+const (
+	SEVERITY_CRITICAL = "CRITICAL"
+	SEVERITY_HIGH     = "HIGH"
+	SEVERITY_MEDIUM   = "MEDIUM"
+	SEVERITY_LOW      = "LOW"
+	SEVERITY_UNKNOWN  = "UNKNOWN"
 )
 
 type ScanResult struct {
-	ScanID        string
-	File          string
-	Line          int
-	DiffHunk      string
-	ReviewComment string
-	GitHubURL     string
-	ScanResult    any
-	RiskenURL     string
+	ScanID           string
+	Fingerprint      string // stable ID across the line shifts, set by AssignFingerprints
+	File             string
+	Line             int
+	DiffHunk         string
+	ReviewComment    string
+	GitHubURL        string
+	Finding          Finding
+	RiskenURL        string
+	RiskenStatus     string // uploaded or failed, empty if not integrated with RISKEN
+	RiskenTriage     string // pending or false_positive if pended in RISKEN
+	RiskenTriageNote string
+	Verification     *verify.Result
 }
 
 type Scanner interface {
-	Scan(ctx context.Context, repo *github.Repository, pr *github.PullRequest, sourceCodePath string, changeFiles []*github.CommitFile) ([]*ScanResult, error)
+	Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error)
 }
 
-func isChangeLine(files []*github.CommitFile, fileName, line string) bool {
+func isChangeLine(files []*scm.ChangeFile, fileName, line string) bool {
 	for _, f := range files {
-		if *f.Filename != fileName {
+		if f.Filename != fileName {
 			continue
 		}
 		if isLineInDiff(f, line) {
@@ -35,8 +51,8 @@ func isChangeLine(files []*github.CommitFile, fileName, line string) bool {
 	return false
 }
 
-func isLineInDiff(file *github.CommitFile, line string) bool {
-	patchLines := strings.Split(file.GetPatch(), "\n")
+func isLineInDiff(file *scm.ChangeFile, line string) bool {
+	patchLines := strings.Split(file.Patch, "\n")
 	for _, patchLine := range patchLines {
 		// "+" で始まる行は追加された行を示します。
 		if strings.HasPrefix(patchLine, "+") {
@@ -48,6 +64,37 @@ func isLineInDiff(file *github.CommitFile, line string) bool {
 	return false
 }
 
+var hunkHeaderRegexp = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// addedLineNumbers returns the line numbers (new file side) of the lines added in the patch.
+func addedLineNumbers(file *scm.ChangeFile) map[int]bool {
+	added := map[int]bool{}
+	lineNumber := 0
+	for _, patchLine := range strings.Split(file.Patch, "\n") {
+		if m := hunkHeaderRegexp.FindStringSubmatch(patchLine); m != nil {
+			start, err := strconv.Atoi(m[1])
+			if err != nil {
+				continue
+			}
+			lineNumber = start
+			continue
+		}
+		if lineNumber == 0 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(patchLine, "+"):
+			added[lineNumber] = true
+			lineNumber++
+		case strings.HasPrefix(patchLine, "-"), strings.HasPrefix(patchLine, "\\"):
+			// removed line or "\ No newline at end of file"
+		default:
+			lineNumber++
+		}
+	}
+	return added
+}
+
 func removeDirPrefix(dir, path string) string {
 	if strings.HasPrefix(path, dir+"/") {
 		return strings.TrimPrefix(path, dir+"/")
@@ -58,3 +105,17 @@ func removeDirPrefix(dir, path string) string {
 func GenerateRiskenURL(riskenConsoleURL string, projectID uint32, findingID uint64) string {
 	return fmt.Sprintf("%s/finding/finding/?from_score=0&status=0&project_id=%d&finding_id=%d", riskenConsoleURL, projectID, findingID)
 }
+
+func getSeverityScore(severity string) float32 {
+	// Same as the RISKEN dependency scan (ca-risken/code)
+	switch severity {
+	case SEVERITY_CRITICAL:
+		return 0.6
+	case SEVERITY_HIGH:
+		return 0.5
+	case SEVERITY_MEDIUM:
+		return 0.3
+	default:
+		return 0.1
+	}
+}
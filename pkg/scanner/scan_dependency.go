@@ -0,0 +1,330 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/datasource-api/pkg/message"
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+const (
+	ECOSYSTEM_GO   = "Go"
+	ECOSYSTEM_NPM  = "npm"
+	ECOSYSTEM_PYPI = "PyPI"
+)
+
+// DependencyScanner scans the dependencies added or upgraded in the changed manifests with the offline OSV advisory database.
+type DependencyScanner struct {
+	logger         *slog.Logger
+	advisoryDBPath string
+}
+
+func NewDependencyScanner(logger *slog.Logger, advisoryDBPath string) Scanner {
+	return &DependencyScanner{
+		logger:         logger,
+		advisoryDBPath: advisoryDBPath,
+	}
+}
+
+// DependencyFinding is a vulnerable dependency detected by DependencyScanner.
+type DependencyFinding struct {
+	Repository      string   `json:"repository"`
+	Path            string   `json:"path"`
+	Line            int      `json:"line"`
+	Ecosystem       string   `json:"ecosystem"`
+	PackageName     string   `json:"package_name"`
+	Version         string   `json:"version"`
+	VulnerabilityID string   `json:"vulnerability_id"`
+	AdvisoryID      string   `json:"advisory_id"`
+	Aliases         []string `json:"aliases,omitempty"`
+	Summary         string   `json:"summary,omitempty"`
+	Level           string   `json:"severity"`
+	FixedVersion    string   `json:"fixed_version,omitempty"`
+	References      []string `json:"references,omitempty"`
+	GitHubURL       string   `json:"github_url"`
+}
+
+type dependency struct {
+	ecosystem string
+	name      string
+	version   string
+	line      int
+	code      string
+}
+
+func (s *DependencyScanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error) {
+	manifests := []*scm.ChangeFile{}
+	for _, file := range changeFiles {
+		if manifestEcosystem(file.Filename) != "" {
+			manifests = append(manifests, file)
+		}
+	}
+	if len(manifests) == 0 {
+		return nil, nil
+	}
+
+	db, err := loadAdvisoryDB(s.advisoryDBPath)
+	if err != nil {
+		return nil, err
+	}
+	var findings []*DependencyFinding
+	for _, file := range manifests {
+		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, file.Filename)
+		s.logger.InfoContext(ctx, "Start dependency scan", slog.String("file", targetPath))
+		deps, err := parseManifest(targetPath, manifestEcosystem(file.Filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: targetPath=%s, err=%w", targetPath, err)
+		}
+		findings = append(findings, matchDependencies(repo, headSHA, file, deps, db)...)
+	}
+	return generateScanResultFromDependencyFindings(findings), nil
+}
+
+func manifestEcosystem(fileName string) string {
+	base := path.Base(fileName)
+	switch {
+	case base == "go.mod":
+		return ECOSYSTEM_GO
+	case base == "package-lock.json":
+		return ECOSYSTEM_NPM
+	case strings.HasPrefix(base, "requirements") && strings.HasSuffix(base, ".txt"):
+		return ECOSYSTEM_PYPI
+	}
+	return ""
+}
+
+// matchDependencies returns the findings of the dependencies on the added lines only (= added or upgraded from the base).
+func matchDependencies(repo *scm.Repository, commit string, file *scm.ChangeFile, deps []*dependency, db *advisoryDB) []*DependencyFinding {
+	added := addedLineNumbers(file)
+	reported := map[string]bool{}
+	var findings []*DependencyFinding
+	for _, d := range deps {
+		if !added[d.line] {
+			continue
+		}
+		for _, m := range db.lookup(d.ecosystem, d.name, d.version) {
+			key := fmt.Sprintf("%s@%s/%s", d.name, d.version, m.advisory.ID)
+			if reported[key] {
+				continue // package-lock.json may contain the same package in multiple sections
+			}
+			reported[key] = true
+			var refs []string
+			for _, r := range m.advisory.References {
+				refs = append(refs, r.URL)
+			}
+			findings = append(findings, &DependencyFinding{
+				Repository:      repo.FullName,
+				Path:            file.Filename,
+				Line:            d.line,
+				Ecosystem:       d.ecosystem,
+				PackageName:     d.name,
+				Version:         d.version,
+				VulnerabilityID: m.advisory.vulnerabilityID(),
+				AdvisoryID:      m.advisory.ID,
+				Aliases:         m.advisory.Aliases,
+				Summary:         m.advisory.Summary,
+				Level:           m.advisory.severity(),
+				FixedVersion:    m.fixedVersion,
+				References:      refs,
+				GitHubURL:       repo.FileURL(commit, file.Filename, d.line),
+			})
+		}
+	}
+	return findings
+}
+
+func parseManifest(targetPath, ecosystem string) ([]*dependency, error) {
+	buf, err := os.ReadFile(targetPath)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(buf), "\r\n", "\n"), "\n")
+	switch ecosystem {
+	case ECOSYSTEM_GO:
+		return parseGoMod(lines), nil
+	case ECOSYSTEM_NPM:
+		return parsePackageLock(lines), nil
+	case ECOSYSTEM_PYPI:
+		return parseRequirements(lines), nil
+	}
+	return nil, fmt.Errorf("unsupported ecosystem: %s", ecosystem)
+}
+
+var goModRequireRegexp = regexp.MustCompile(`^(?:require\s+)?([^\s()]+)\s+(v[^\s]+)`)
+
+func parseGoMod(lines []string) []*dependency {
+	var deps []*dependency
+	inRequire := false
+	for i, l := range lines {
+		line := strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(line, "require ("):
+			inRequire = true
+			continue
+		case inRequire && line == ")":
+			inRequire = false
+			continue
+		case !inRequire && !strings.HasPrefix(line, "require "):
+			continue
+		}
+		m := goModRequireRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		deps = append(deps, &dependency{ecosystem: ECOSYSTEM_GO, name: m[1], version: m[2], line: i + 1, code: line})
+	}
+	return deps
+}
+
+var requirementRegexp = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(?:\[[^\]]*\])?\s*==\s*([^\s;#,]+)`)
+
+func parseRequirements(lines []string) []*dependency {
+	var deps []*dependency
+	for i, l := range lines {
+		line := strings.TrimSpace(l)
+		m := requirementRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue // only pinned versions can be matched
+		}
+		deps = append(deps, &dependency{ecosystem: ECOSYSTEM_PYPI, name: m[1], version: m[2], line: i + 1, code: line})
+	}
+	return deps
+}
+
+var (
+	packageLockKeyRegexp     = regexp.MustCompile(`^\s*"([^"]*)":\s*\{`)
+	packageLockVersionRegexp = regexp.MustCompile(`^\s*"version":\s*"([^"]+)"`)
+	packageLockSectionKeys   = map[string]bool{
+		"packages": true, "dependencies": true, "devDependencies": true, "optionalDependencies": true,
+		"peerDependencies": true, "peerDependenciesMeta": true, "requires": true, "engines": true, "bin": true, "funding": true,
+	}
+)
+
+// parsePackageLock parses package-lock.json line by line to keep the line numbers (lockfileVersion 1, 2 and 3).
+func parsePackageLock(lines []string) []*dependency {
+	var deps []*dependency
+	current := ""
+	for i, line := range lines {
+		if m := packageLockKeyRegexp.FindStringSubmatch(line); m != nil {
+			current = ""
+			if !packageLockSectionKeys[m[1]] {
+				current = m[1]
+				if idx := strings.LastIndex(current, "node_modules/"); idx >= 0 {
+					current = current[idx+len("node_modules/"):]
+				}
+			}
+			continue
+		}
+		m := packageLockVersionRegexp.FindStringSubmatch(line)
+		if m == nil || current == "" {
+			continue
+		}
+		deps = append(deps, &dependency{ecosystem: ECOSYSTEM_NPM, name: current, version: m[1], line: i + 1, code: strings.TrimSpace(line)})
+		current = ""
+	}
+	return deps
+}
+
+func generateScanResultFromDependencyFindings(findings []*DependencyFinding) []*ScanResult {
+	var scanResults []*ScanResult
+	for _, f := range findings {
+		scanResults = append(scanResults, &ScanResult{
+			ScanID:        f.VulnerabilityID,
+			File:          f.Path,
+			Line:          f.Line,
+			DiffHunk:      fmt.Sprintf("%s %s", f.PackageName, f.Version),
+			ReviewComment: f.ReviewComment(),
+			GitHubURL:     f.GitHubURL,
+			Finding:       f,
+		})
+	}
+	return scanResults
+}
+
+const (
+	DEPENDENCY_REVIEW_COMMENT_TEMPLATE = `
+脆弱性のある依存パッケージが追加されています⚠️
+
+#### 依存パッケージスキャン結果
+
+- パッケージ: %s@%s (%s)
+- 脆弱性: %s
+- 重要度: %s
+- 修正バージョン: %s
+- 説明:
+	%s
+`
+)
+
+func (f *DependencyFinding) ReviewComment() string {
+	fixedVersion := f.FixedVersion
+	if fixedVersion == "" {
+		fixedVersion = "なし（修正版は未リリースです）"
+	}
+	return fmt.Sprintf(DEPENDENCY_REVIEW_COMMENT_TEMPLATE, f.PackageName, f.Version, f.Ecosystem, f.VulnerabilityID, f.Level, fixedVersion, f.Summary)
+}
+
+func (f *DependencyFinding) Scanner() string {
+	return SCANNER_DEPENDENCY
+}
+
+func (f *DependencyFinding) Severity() string {
+	return f.Level
+}
+
+func (f *DependencyFinding) CWE() []string {
+	return nil
+}
+
+func (f *DependencyFinding) DataSource() string {
+	return message.DependencyDataSource
+}
+
+func (f *DependencyFinding) Fingerprint() string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%s", f.Repository, f.Path, f.PackageName, f.AdvisoryID)))
+	return hex.EncodeToString(hash[:])
+}
+
+func (f *DependencyFinding) PutFindingRequest(projectID uint32) (*finding.PutFindingRequest, error) {
+	buf, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: project_id=%d, repository=%s, err=%w", projectID, f.Repository, err)
+	}
+	return &finding.PutFindingRequest{
+		ProjectId: projectID,
+		Finding: &finding.FindingForUpsert{
+			Description:      fmt.Sprintf("Vulnerability %s found in %s@%s. Repository: %s", f.VulnerabilityID, f.PackageName, f.Version, f.Repository),
+			DataSource:       f.DataSource(),
+			DataSourceId:     f.Fingerprint(),
+			ResourceName:     f.Repository,
+			ProjectId:        projectID,
+			OriginalScore:    getSeverityScore(f.Level),
+			OriginalMaxScore: 1.0,
+			Data:             string(buf),
+		},
+	}, nil
+}
+
+func (f *DependencyFinding) PutRecommendRequest(projectID uint32, findingID uint64) *finding.PutRecommendRequest {
+	return &finding.PutRecommendRequest{
+		ProjectId:  projectID,
+		FindingId:  findingID,
+		DataSource: f.DataSource(),
+		Type:       f.PackageName,
+		Risk: fmt.Sprintf(`One or more vulnerabilities are discovered in %s.
+		- Check Finding for more information on the impact of the vulnerability and other details.`, f.PackageName),
+		Recommendation: `Take the following actions for vulnerable package.
+		- Check Finding and update the package to the FixedVersion.
+		- If the vulnerability has not been fixed, please check References and take interim action.`,
+	}
+}
@@ -0,0 +1,342 @@
+package scanner
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testAdvisoryGo = `{
+  "id": "GO-2023-0001",
+  "aliases": ["CVE-2023-0001", "GHSA-xxxx-xxxx-xxxx"],
+  "summary": "Denial of service in example.com/vuln",
+  "affected": [{
+    "package": {"ecosystem": "Go", "name": "example.com/vuln"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}]
+  }],
+  "database_specific": {"severity": "HIGH"},
+  "references": [{"type": "ADVISORY", "url": "https://example.com/advisory"}]
+}`
+	testAdvisoryNpm = `{
+  "id": "GHSA-npm-0001",
+  "summary": "Prototype pollution in lodash",
+  "affected": [{
+    "package": {"ecosystem": "npm", "name": "lodash"},
+    "ranges": [{"type": "SEMVER", "events": [{"introduced": "4.0.0"}, {"fixed": "4.17.21"}]}]
+  }],
+  "database_specific": {"severity": "MODERATE"}
+}`
+	testAdvisoryPyPI = `{
+  "id": "PYSEC-2023-0001",
+  "aliases": ["CVE-2023-0002"],
+  "summary": "Unsafe deserialization",
+  "affected": [{
+    "package": {"ecosystem": "PyPI", "name": "pyyaml"},
+    "versions": ["5.3"]
+  }]
+}`
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func writeTestAdvisoryZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create zip: %v", err)
+	}
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %v", err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write zip entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close zip writer: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("failed to close zip: %v", err)
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "v1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.2.3", b: "1.10.0", want: -1},
+		{a: "2.0.0", b: "1.99.99", want: 1},
+		{a: "1.0.0-rc.1", b: "1.0.0", want: -1},
+		{a: "1.0.0-rc.2", b: "1.0.0-rc.1", want: 1},
+		{a: "1.0rc1", b: "1.0", want: -1},
+		{a: "1.0.0+build", b: "1.0.0", want: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.a+"_"+tc.b, func(t *testing.T) {
+			if got := compareVersion(tc.a, tc.b); got != tc.want {
+				t.Errorf("compareVersion(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsAffectedVersion(t *testing.T) {
+	affected := &osvAffected{
+		Ranges: []*osvRange{
+			{Type: "SEMVER", Events: []*osvEvent{{Introduced: "1.0.0"}, {Fixed: "1.2.0"}, {Introduced: "2.0.0"}, {LastAffected: "2.1.0"}}},
+			{Type: "GIT", Events: []*osvEvent{{Introduced: "0"}}},
+		},
+		Versions: []string{"0.9.0"},
+	}
+	testCases := []struct {
+		version   string
+		wantHit   bool
+		wantFixed string
+	}{
+		{version: "0.8.0", wantHit: false, wantFixed: ""},
+		{version: "0.9.0", wantHit: true, wantFixed: "1.2.0"},
+		{version: "1.1.9", wantHit: true, wantFixed: "1.2.0"},
+		{version: "1.2.0", wantHit: false, wantFixed: ""},
+		{version: "2.1.0", wantHit: true, wantFixed: ""},
+		{version: "2.1.1", wantHit: false, wantFixed: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			gotHit, gotFixed := isAffectedVersion(affected, tc.version)
+			if gotHit != tc.wantHit || gotFixed != tc.wantFixed {
+				t.Errorf("isAffectedVersion(%q) = (%t, %q), want (%t, %q)", tc.version, gotHit, gotFixed, tc.wantHit, tc.wantFixed)
+			}
+		})
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	testCases := []struct {
+		name      string
+		ecosystem string
+		content   string
+		want      []*dependency
+	}{
+		{
+			name:      "go.mod",
+			ecosystem: ECOSYSTEM_GO,
+			content: `module example.com/app
+
+go 1.21
+
+require example.com/single v1.0.0
+
+require (
+	example.com/vuln v1.1.0
+	example.com/indirect v0.1.0 // indirect
+)
+
+replace example.com/single => ../single
+`,
+			want: []*dependency{
+				{ecosystem: ECOSYSTEM_GO, name: "example.com/single", version: "v1.0.0", line: 5, code: "require example.com/single v1.0.0"},
+				{ecosystem: ECOSYSTEM_GO, name: "example.com/vuln", version: "v1.1.0", line: 8, code: "example.com/vuln v1.1.0"},
+				{ecosystem: ECOSYSTEM_GO, name: "example.com/indirect", version: "v0.1.0", line: 9, code: "example.com/indirect v0.1.0 // indirect"},
+			},
+		},
+		{
+			name:      "requirements.txt",
+			ecosystem: ECOSYSTEM_PYPI,
+			content: `# comment
+requests==2.31.0
+PyYAML[extra] == 5.3 ; python_version > "3.6"
+flask>=2.0
+-r other.txt
+`,
+			want: []*dependency{
+				{ecosystem: ECOSYSTEM_PYPI, name: "requests", version: "2.31.0", line: 2, code: "requests==2.31.0"},
+				{ecosystem: ECOSYSTEM_PYPI, name: "PyYAML", version: "5.3", line: 3, code: `PyYAML[extra] == 5.3 ; python_version > "3.6"`},
+			},
+		},
+		{
+			name:      "package-lock.json",
+			ecosystem: ECOSYSTEM_NPM,
+			content: `{
+  "name": "app",
+  "version": "1.0.0",
+  "lockfileVersion": 3,
+  "packages": {
+    "": {
+      "name": "app",
+      "version": "1.0.0",
+      "dependencies": {
+        "lodash": "^4.17.0"
+      }
+    },
+    "node_modules/lodash": {
+      "version": "4.17.20",
+      "resolved": "https://registry.npmjs.org/lodash/-/lodash-4.17.20.tgz"
+    },
+    "node_modules/@scope/pkg": {
+      "version": "1.0.0",
+      "dependencies": {
+        "lodash": "^4.0.0"
+      }
+    }
+  }
+}
+`,
+			want: []*dependency{
+				{ecosystem: ECOSYSTEM_NPM, name: "lodash", version: "4.17.20", line: 14, code: `"version": "4.17.20",`},
+				{ecosystem: ECOSYSTEM_NPM, name: "@scope/pkg", version: "1.0.0", line: 18, code: `"version": "1.0.0",`},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.name)
+			writeTestFile(t, path, tc.content)
+			got, err := parseManifest(path, tc.ecosystem)
+			if err != nil {
+				t.Fatalf("parseManifest() error = %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(dependency{})); diff != "" {
+				t.Errorf("parseManifest() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDependencyScan(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	headSHA := "headsha"
+
+	sourceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(sourceDir, "go.mod"), `module example.com/app
+
+require (
+	example.com/vuln v1.1.0
+	example.com/old v0.1.0
+)
+`)
+	writeTestFile(t, filepath.Join(sourceDir, "py/requirements.txt"), "PyYAML==5.3\n")
+	writeTestFile(t, filepath.Join(sourceDir, "main.go"), "package main\n")
+
+	dbDir := t.TempDir()
+	writeTestFile(t, filepath.Join(dbDir, "go/GO-2023-0001.json"), testAdvisoryGo)
+	writeTestAdvisoryZip(t, filepath.Join(dbDir, "all.zip"), map[string]string{
+		"GHSA-npm-0001.json":   testAdvisoryNpm,
+		"PYSEC-2023-0001.json": testAdvisoryPyPI,
+	})
+
+	changeFiles := []*scm.ChangeFile{
+		{
+			Filename: "go.mod",
+			Patch: `@@ -1,5 +1,5 @@
+ module example.com/app
+
+ require (
+-	example.com/vuln v1.0.0
++	example.com/vuln v1.1.0
+ 	example.com/old v0.1.0`,
+		},
+		{
+			Filename: "py/requirements.txt",
+			Patch:    "@@ -0,0 +1 @@\n+PyYAML==5.3",
+		},
+		{
+			Filename: "main.go",
+			Patch:    "@@ -0,0 +1 @@\n+package main",
+		},
+	}
+
+	s := NewDependencyScanner(slog.New(slog.NewTextHandler(io.Discard, nil)), dbDir)
+	got, err := s.Scan(context.Background(), repo, headSHA, sourceDir, changeFiles)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	want := []*ScanResult{
+		{
+			ScanID:        "CVE-2023-0001",
+			File:          "go.mod",
+			Line:          4,
+			DiffHunk:      "example.com/vuln v1.1.0",
+			ReviewComment: "\n脆弱性のある依存パッケージが追加されています⚠️\n\n#### 依存パッケージスキャン結果\n\n- パッケージ: example.com/vuln@v1.1.0 (Go)\n- 脆弱性: CVE-2023-0001\n- 重要度: HIGH\n- 修正バージョン: 1.2.0\n- 説明:\n\tDenial of service in example.com/vuln\n",
+			GitHubURL:     "https://github.com/owner/repo/blob/headsha/go.mod#L4",
+			Finding: &DependencyFinding{
+				Repository:      "owner/repo",
+				Path:            "go.mod",
+				Line:            4,
+				Ecosystem:       ECOSYSTEM_GO,
+				PackageName:     "example.com/vuln",
+				Version:         "v1.1.0",
+				VulnerabilityID: "CVE-2023-0001",
+				AdvisoryID:      "GO-2023-0001",
+				Aliases:         []string{"CVE-2023-0001", "GHSA-xxxx-xxxx-xxxx"},
+				Summary:         "Denial of service in example.com/vuln",
+				Level:           SEVERITY_HIGH,
+				FixedVersion:    "1.2.0",
+				References:      []string{"https://example.com/advisory"},
+				GitHubURL:       "https://github.com/owner/repo/blob/headsha/go.mod#L4",
+			},
+		},
+		{
+			ScanID:        "CVE-2023-0002",
+			File:          "py/requirements.txt",
+			Line:          1,
+			DiffHunk:      "PyYAML 5.3",
+			ReviewComment: "\n脆弱性のある依存パッケージが追加されています⚠️\n\n#### 依存パッケージスキャン結果\n\n- パッケージ: PyYAML@5.3 (PyPI)\n- 脆弱性: CVE-2023-0002\n- 重要度: UNKNOWN\n- 修正バージョン: なし（修正版は未リリースです）\n- 説明:\n\tUnsafe deserialization\n",
+			GitHubURL:     "https://github.com/owner/repo/blob/headsha/py/requirements.txt#L1",
+			Finding: &DependencyFinding{
+				Repository:      "owner/repo",
+				Path:            "py/requirements.txt",
+				Line:            1,
+				Ecosystem:       ECOSYSTEM_PYPI,
+				PackageName:     "PyYAML",
+				Version:         "5.3",
+				VulnerabilityID: "CVE-2023-0002",
+				AdvisoryID:      "PYSEC-2023-0001",
+				Aliases:         []string{"CVE-2023-0002"},
+				Summary:         "Unsafe deserialization",
+				Level:           SEVERITY_UNKNOWN,
+				GitHubURL:       "https://github.com/owner/repo/blob/headsha/py/requirements.txt#L1",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Scan() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDependencyScanNoManifest(t *testing.T) {
+	s := NewDependencyScanner(slog.New(slog.NewTextHandler(io.Discard, nil)), "/not/exists")
+	changeFiles := []*scm.ChangeFile{{Filename: "main.go"}}
+	got, err := s.Scan(context.Background(), &scm.Repository{}, "", t.TempDir(), changeFiles)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Scan() results = %d, want 0", len(got))
+	}
+}
@@ -0,0 +1,297 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+// DockerfileScanner checks the Dockerfiles for insecure container build practices.
+type DockerfileScanner struct {
+	logger *slog.Logger
+}
+
+func NewDockerfileScanner(logger *slog.Logger) Scanner {
+	return &DockerfileScanner{
+		logger: logger,
+	}
+}
+
+var (
+	dockerfileRuleUnpinnedImage = &rule{
+		ID:             "DOCKERFILE_UNPINNED_IMAGE",
+		Severity:       SEVERITY_LOW,
+		Description:    "ベースイメージがダイジェストで固定されていません。タグは書き換えられる可能性があり、ビルドの再現性やサプライチェーンの安全性が損なわれます。",
+		Recommendation: "FROM image:1.2.3@sha256:<digest> のようにダイジェストで固定してください（docker buildx imagetools inspect image:1.2.3 で確認できます）。",
+	}
+	dockerfileRuleLatestTag = &rule{
+		ID:             "DOCKERFILE_LATEST_TAG",
+		Severity:       SEVERITY_MEDIUM,
+		Description:    "ベースイメージに latest タグ（またはタグの省略）が使われています。ビルドの度に異なるイメージが使われる可能性があります。",
+		Recommendation: "FROM image:1.2.3@sha256:<digest> のように明示的なバージョンとダイジェストを指定してください。",
+	}
+	dockerfileRuleRootUser = &rule{
+		ID:             "DOCKERFILE_ROOT_USER",
+		Severity:       SEVERITY_MEDIUM,
+		Description:    "コンテナが root ユーザーで実行されます。コンテナ内の脆弱性が悪用された場合に被害が拡大する恐れがあります。",
+		Recommendation: "最終ステージで USER nonroot（または USER 65532:65532 など）を指定し、非特権ユーザーで実行してください。",
+	}
+	dockerfileRuleAddURL = &rule{
+		ID:             "DOCKERFILE_ADD_URL",
+		Severity:       SEVERITY_MEDIUM,
+		Description:    "ADD でリモートURLからファイルを取得しています。取得したファイルの完全性が検証されません。",
+		Recommendation: "RUN curl -fsSLO <url> && echo \"<sha256>  <file>\" | sha256sum -c - のようにチェックサムを検証するか、ADD --checksum=sha256:<digest> を指定してください。",
+	}
+	dockerfileRuleCurlPipeShell = &rule{
+		ID:             "DOCKERFILE_CURL_PIPE_SHELL",
+		Severity:       SEVERITY_HIGH,
+		Description:    "リモートから取得したスクリプトを検証せずにシェルで実行しています (curl | sh)。配布元が改ざんされた場合に任意のコードが実行されます。",
+		Recommendation: "スクリプトをファイルに保存し、チェックサムや署名を検証してから実行してください。可能であればパッケージマネージャーを利用してください。",
+	}
+	dockerfileRuleSecretInEnv = &rule{
+		ID:             "DOCKERFILE_SECRET_IN_ENV",
+		Severity:       SEVERITY_HIGH,
+		Description:    "ENV または ARG でシークレットと思われる値を扱っています。ENV はイメージに、ARG はイメージの履歴 (docker history) に残ります。",
+		Recommendation: "RUN --mount=type=secret,id=<id> (BuildKit) でシークレットを渡し、実行時の値は環境変数やシークレットストアから注入してください。",
+	}
+	dockerfileRulePackageCache = &rule{
+		ID:             "DOCKERFILE_PACKAGE_CACHE",
+		Severity:       SEVERITY_LOW,
+		Description:    "パッケージマネージャーのキャッシュがイメージに残ります。イメージサイズの増加や、古いパッケージ情報が残る原因になります。",
+		Recommendation: "apk add --no-cache、pip install --no-cache-dir を指定するか、apt-get install と同じ RUN で rm -rf /var/lib/apt/lists/* を実行してください。",
+	}
+)
+
+var (
+	dockerfileCurlPipeShellRegexp = regexp.MustCompile(`\b(?:curl|wget)\b[^|;&]*\|\s*(?:sudo\s+(?:-\S+\s+)*)?(?:/usr)?(?:/bin/)?(?:ba|da|z)?sh\b`)
+	dockerfilePipInstallRegexp    = regexp.MustCompile(`\bpip3?\s+install\b`)
+	dockerfileSecretKeyRegexp     = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|access_?key|private_?key|credential)`)
+	dockerfileRootUsers           = map[string]bool{"root": true, "0": true}
+)
+
+// dockerInstruction is an instruction of the Dockerfile joined with the continuation lines.
+type dockerInstruction struct {
+	command   string
+	args      string
+	startLine int
+	endLine   int
+}
+
+func (s *DockerfileScanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error) {
+	var findings []*RuleFinding
+	for _, file := range changeFiles {
+		if !isDockerfile(file.Filename) {
+			continue
+		}
+		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, file.Filename)
+		s.logger.InfoContext(ctx, "Start dockerfile scan", slog.String("file", targetPath))
+		content, err := os.ReadFile(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", targetPath, err)
+		}
+		lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+		findings = append(findings, filterRuleFindings(file, lines, checkDockerfile(repo, headSHA, file.Filename, lines))...)
+	}
+	return generateScanResultFromRuleFindings(findings), nil
+}
+
+var dockerfileDocumentExts = map[string]bool{".md": true, ".txt": true, ".rst": true}
+
+// isDockerfile returns true for Dockerfile, Dockerfile.* and *.dockerfile (except the documents like Dockerfile.md).
+func isDockerfile(fileName string) bool {
+	base := strings.ToLower(path.Base(fileName))
+	if dockerfileDocumentExts[path.Ext(base)] {
+		return false
+	}
+	return base == "dockerfile" || strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile")
+}
+
+// parseDockerfile splits the Dockerfile into the instructions. Comments and empty lines are skipped.
+func parseDockerfile(lines []string) []*dockerInstruction {
+	var instructions []*dockerInstruction
+	var current *dockerInstruction
+	for i, l := range lines {
+		line := strings.TrimSpace(l)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue // comment lines are also allowed in the middle of continuation
+		}
+		continued := strings.HasSuffix(line, "\\")
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\\"))
+		if current == nil {
+			fields := strings.SplitN(line, " ", 2)
+			current = &dockerInstruction{command: strings.ToUpper(fields[0]), startLine: i + 1}
+			if len(fields) > 1 {
+				current.args = strings.TrimSpace(fields[1])
+			}
+		} else {
+			current.args = strings.TrimSpace(current.args + " " + line)
+		}
+		current.endLine = i + 1
+		if !continued {
+			instructions = append(instructions, current)
+			current = nil
+		}
+	}
+	if current != nil {
+		instructions = append(instructions, current)
+	}
+	return instructions
+}
+
+type dockerfileChecker struct {
+	repo     *scm.Repository
+	commit   string
+	path     string
+	lines    []string
+	findings []*RuleFinding
+}
+
+func (c *dockerfileChecker) report(r *rule, line int) {
+	c.findings = append(c.findings, newRuleFinding(RULE_SCANNER_DOCKERFILE, r, c.repo, c.commit, c.path, line, strings.TrimSpace(c.lines[line-1])))
+}
+
+// lineOf returns the first line of the instruction containing the keyword, or the start line.
+func (c *dockerfileChecker) lineOf(inst *dockerInstruction, keyword string) int {
+	for l := inst.startLine; l <= inst.endLine; l++ {
+		if strings.Contains(c.lines[l-1], keyword) {
+			return l
+		}
+	}
+	return inst.startLine
+}
+
+// lineOfCurlPipeShell returns the line of "curl | sh", or the line of the pipe if it is split by the continuation.
+func (c *dockerfileChecker) lineOfCurlPipeShell(inst *dockerInstruction) int {
+	for l := inst.startLine; l <= inst.endLine; l++ {
+		if dockerfileCurlPipeShellRegexp.MatchString(c.lines[l-1]) {
+			return l
+		}
+	}
+	return c.lineOf(inst, "|")
+}
+
+func checkDockerfile(repo *scm.Repository, commit, filePath string, lines []string) []*RuleFinding {
+	c := &dockerfileChecker{
+		repo:   repo,
+		commit: commit,
+		path:   filePath,
+		lines:  lines,
+	}
+	stages := map[string]bool{}
+	var lastFrom, lastUser *dockerInstruction
+	for _, inst := range parseDockerfile(lines) {
+		switch inst.command {
+		case "FROM":
+			c.checkFrom(inst, stages)
+			lastFrom, lastUser = inst, nil
+		case "USER":
+			lastUser = inst
+		case "ADD":
+			if strings.Contains(inst.args, "--checksum=") {
+				break
+			}
+			for _, arg := range dockerfileArgs(inst.args) {
+				if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+					c.report(dockerfileRuleAddURL, c.lineOf(inst, arg))
+					break
+				}
+			}
+		case "RUN":
+			c.checkRun(inst)
+		case "ENV", "ARG":
+			for _, key := range dockerfileVariableKeys(inst) {
+				if dockerfileSecretKeyRegexp.MatchString(key) {
+					c.report(dockerfileRuleSecretInEnv, c.lineOf(inst, key))
+				}
+			}
+		}
+	}
+
+	// Only the final stage is relevant to the runtime user.
+	switch {
+	case lastFrom == nil:
+	case lastUser == nil:
+		c.report(dockerfileRuleRootUser, lastFrom.startLine)
+	case dockerfileRootUsers[strings.SplitN(lastUser.args, ":", 2)[0]]:
+		c.report(dockerfileRuleRootUser, lastUser.startLine)
+	}
+	return c.findings
+}
+
+func (c *dockerfileChecker) checkFrom(inst *dockerInstruction, stages map[string]bool) {
+	args := dockerfileArgs(inst.args)
+	if len(args) == 0 {
+		return
+	}
+	image := args[0]
+	if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+		stages[strings.ToLower(args[2])] = true
+	}
+	if image == "scratch" || stages[strings.ToLower(image)] || strings.Contains(image, "$") {
+		return // no registry image, or resolved by the build args
+	}
+	if strings.Contains(image, "@sha256:") {
+		return
+	}
+	tag := ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+	if tag == "" || tag == "latest" {
+		c.report(dockerfileRuleLatestTag, inst.startLine)
+		return
+	}
+	c.report(dockerfileRuleUnpinnedImage, inst.startLine)
+}
+
+func (c *dockerfileChecker) checkRun(inst *dockerInstruction) {
+	if dockerfileCurlPipeShellRegexp.MatchString(inst.args) {
+		c.report(dockerfileRuleCurlPipeShell, c.lineOfCurlPipeShell(inst))
+	}
+	if strings.Contains(inst.args, "--mount=type=cache") {
+		return // the cache is not stored in the image layer
+	}
+	switch {
+	case strings.Contains(inst.args, "apk add") && !strings.Contains(inst.args, "--no-cache"):
+		c.report(dockerfileRulePackageCache, c.lineOf(inst, "apk add"))
+	case strings.Contains(inst.args, "apt-get install") && !strings.Contains(inst.args, "/var/lib/apt/lists"):
+		c.report(dockerfileRulePackageCache, c.lineOf(inst, "apt-get install"))
+	case dockerfilePipInstallRegexp.MatchString(inst.args) && !strings.Contains(inst.args, "--no-cache-dir"):
+		c.report(dockerfileRulePackageCache, c.lineOf(inst, "pip"))
+	}
+}
+
+// dockerfileArgs returns the arguments without the flags (e.g. --platform=linux/amd64).
+func dockerfileArgs(args string) []string {
+	var fields []string
+	for _, f := range strings.Fields(args) {
+		if !strings.HasPrefix(f, "--") {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// dockerfileVariableKeys returns the variable names of ENV (KEY=VALUE ... or KEY VALUE) and ARG (NAME[=default]).
+func dockerfileVariableKeys(inst *dockerInstruction) []string {
+	fields := strings.Fields(inst.args)
+	if len(fields) == 0 {
+		return nil
+	}
+	if inst.command == "ENV" && !strings.Contains(fields[0], "=") {
+		return fields[:1] // legacy form: ENV KEY VALUE
+	}
+	var keys []string
+	for _, f := range fields {
+		if key, _, found := strings.Cut(f, "="); found || inst.command == "ARG" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
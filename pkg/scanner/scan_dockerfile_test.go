@@ -0,0 +1,167 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDockerfile(t *testing.T) {
+	content := `# syntax=docker/dockerfile:1
+FROM golang:1.22 AS builder
+
+RUN apt-get update && \
+    # comment in continuation
+    apt-get install -y git
+from alpine:3.19`
+	want := []*dockerInstruction{
+		{command: "FROM", args: "golang:1.22 AS builder", startLine: 2, endLine: 2},
+		{command: "RUN", args: "apt-get update && apt-get install -y git", startLine: 4, endLine: 6},
+		{command: "FROM", args: "alpine:3.19", startLine: 7, endLine: 7},
+	}
+	got := parseDockerfile(strings.Split(content, "\n"))
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(dockerInstruction{})); diff != "" {
+		t.Errorf("parseDockerfile() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckDockerfile(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	type want struct {
+		ruleID string
+		line   int
+	}
+	testCases := []struct {
+		name    string
+		content string
+		want    []want
+	}{
+		{
+			name: "Base images",
+			content: `ARG BASE=alpine
+FROM --platform=linux/amd64 golang:1.22 AS builder
+FROM builder AS test
+FROM ${BASE}
+FROM ubuntu
+FROM node:latest
+FROM gcr.io/distroless/static:nonroot@sha256:0123456789abcdef
+USER nonroot`,
+			want: []want{
+				{ruleID: "DOCKERFILE_UNPINNED_IMAGE", line: 2},
+				{ruleID: "DOCKERFILE_LATEST_TAG", line: 5},
+				{ruleID: "DOCKERFILE_LATEST_TAG", line: 6},
+			},
+		},
+		{
+			name: "Root user",
+			content: `FROM alpine@sha256:0123 AS builder
+USER app
+FROM alpine@sha256:0123`,
+			want: []want{{ruleID: "DOCKERFILE_ROOT_USER", line: 3}},
+		},
+		{
+			name: "Explicit root user",
+			content: `FROM alpine@sha256:0123
+USER 0:0`,
+			want: []want{{ruleID: "DOCKERFILE_ROOT_USER", line: 2}},
+		},
+		{
+			name: "ADD and RUN",
+			content: `FROM alpine@sha256:0123
+ADD https://example.com/app.tar.gz /app/
+ADD --checksum=sha256:0123 https://example.com/app.tar.gz /app/
+ADD ./local /app/
+RUN apk add curl && \
+    curl -fsSL https://example.com/install.sh | sudo -E bash -
+RUN apk add --no-cache git
+RUN curl -fsSLO https://example.com/install.sh && sh install.sh
+RUN apt-get update && apt-get install -y git
+RUN apt-get update && apt-get install -y git && rm -rf /var/lib/apt/lists/*
+RUN pip install -r requirements.txt
+RUN --mount=type=cache,target=/root/.cache pip install -r requirements.txt
+USER app`,
+			want: []want{
+				{ruleID: "DOCKERFILE_ADD_URL", line: 2},
+				{ruleID: "DOCKERFILE_CURL_PIPE_SHELL", line: 6},
+				{ruleID: "DOCKERFILE_PACKAGE_CACHE", line: 5},
+				{ruleID: "DOCKERFILE_PACKAGE_CACHE", line: 9},
+				{ruleID: "DOCKERFILE_PACKAGE_CACHE", line: 11},
+			},
+		},
+		{
+			name: "Secrets in ENV and ARG",
+			content: `FROM alpine@sha256:0123
+ARG GITHUB_TOKEN
+ARG VERSION=1.0
+ENV DB_PASSWORD secret
+ENV APP_ENV=prod \
+    API_KEY=xxx
+USER app`,
+			want: []want{
+				{ruleID: "DOCKERFILE_SECRET_IN_ENV", line: 2},
+				{ruleID: "DOCKERFILE_SECRET_IN_ENV", line: 4},
+				{ruleID: "DOCKERFILE_SECRET_IN_ENV", line: 6},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := checkDockerfile(repo, "headsha", "Dockerfile", strings.Split(tc.content, "\n"))
+			var got []want
+			for _, f := range findings {
+				got = append(got, want{ruleID: f.RuleID, line: f.Line})
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("checkDockerfile() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDockerfileScan(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	headSHA := "headsha"
+	sourceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(sourceDir, "build/Dockerfile.app"), `FROM alpine:3.19
+# risken-ignore: DOCKERFILE_ROOT_USER
+FROM node:latest
+RUN curl -sSL https://example.com/install.sh | sh
+`)
+	writeTestFile(t, filepath.Join(sourceDir, "docs/Dockerfile.md"), "FROM node:latest\n")
+	changeFiles := []*scm.ChangeFile{
+		{
+			Filename: "build/Dockerfile.app",
+			Patch:    "@@ -1,0 +1,4 @@\n+FROM alpine:3.19\n+# risken-ignore: DOCKERFILE_ROOT_USER\n+FROM node:latest\n+RUN curl -sSL https://example.com/install.sh | sh",
+		},
+		{
+			Filename: "docs/Dockerfile.md",
+			Patch:    "@@ -0,0 +1 @@\n+FROM node:latest",
+		},
+	}
+
+	s := NewDockerfileScanner(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	got, err := s.Scan(context.Background(), repo, headSHA, sourceDir, changeFiles)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	var gotRules []string
+	for _, r := range got {
+		gotRules = append(gotRules, r.ScanID)
+	}
+	wantRules := []string{"DOCKERFILE_UNPINNED_IMAGE", "DOCKERFILE_LATEST_TAG", "DOCKERFILE_CURL_PIPE_SHELL"}
+	if diff := cmp.Diff(wantRules, gotRules); diff != "" {
+		t.Errorf("Scan() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,180 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+const DEFAULT_ENTROPY_THRESHOLD = 3.5
+
+// EntropyScanner detects the high-entropy strings assigned to the suspicious identifiers (e.g. password, token),
+// to complement the gitleaks rules which only know the well-known credential formats.
+type EntropyScanner struct {
+	logger    *slog.Logger
+	threshold float64
+}
+
+func NewEntropyScanner(logger *slog.Logger, threshold float64) Scanner {
+	if threshold <= 0 {
+		threshold = DEFAULT_ENTROPY_THRESHOLD
+	}
+	return &EntropyScanner{
+		logger:    logger,
+		threshold: threshold,
+	}
+}
+
+var entropyRuleGenericSecret = &rule{
+	ID:             "ENTROPY_GENERIC_SECRET",
+	Severity:       SEVERITY_HIGH,
+	Description:    "%s にランダムな文字列 (エントロピー: %.2f) が設定されています。ハードコードされたシークレット情報の可能性があります。",
+	Recommendation: "対象データがテスト用のダミーデータであるか確認してください。有効なシークレット情報の場合はキーのローテーションを行い、環境変数やシークレットストアから読み込むように変更してください。",
+}
+
+// entropyLanguage is the syntax used to tokenize the assignments.
+type entropyLanguage int
+
+const (
+	entropyLanguageCode entropyLanguage = iota // key = "value", key: "value", key := "value", key => "value"
+	entropyLanguageYAML                        // key: value
+	entropyLanguageEnv                         // KEY=value (dotenv, shell, properties, ini, toml)
+)
+
+var (
+	entropyAssignmentRegexps = map[entropyLanguage]*regexp.Regexp{
+		entropyLanguageCode: regexp.MustCompile("([A-Za-z_$][\\w.$-]*)[\"']?\\s*(?::=|=>|=|:)\\s*(?:[\\w.]+\\()?[\"'`]([^\"'`\\s]+)[\"'`]"),
+		entropyLanguageYAML: regexp.MustCompile(`^\s*(?:-\s+)?["']?([\w.-]+)["']?\s*:\s*["']?([^\s"'#]+)`),
+		entropyLanguageEnv:  regexp.MustCompile(`^\s*(?:export\s+)?([\w.-]+)\s*[=:]\s*["']?([^\s"'#;]+)`),
+	}
+	entropySecretIdentifierRegexp = regexp.MustCompile(`(?i)(passw(or)?d|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credential|auth[_-]?key)`)
+	entropyNonSecretSuffixRegexp  = regexp.MustCompile(`(?i)(url|uri|endpoint|path|file|dir|name|type|length|size|id|header|field|env|prefix|format|pattern|regexp?|count|ttl|timeout|expir\w*)$`)
+	entropyPlaceholderRegexp      = regexp.MustCompile(`(?i)(example|sample|dummy|placeholder|changeme|change_me|your[_-]|xxxx|\*\*\*|<[^>]*>|\$\{|\{\{|%\(|^\$)`)
+	entropyUUIDRegexp             = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	entropyTestPathRegexp         = regexp.MustCompile(`(^|/)(testdata|fixtures?|__fixtures__|__mocks__|__snapshots__)/|_test\.go$|\.(test|spec)\.[jt]sx?$|(^|/)test_[^/]*\.py$`)
+	entropyLockFiles              = map[string]bool{
+		"package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "go.sum": true, "Pipfile.lock": true,
+		"poetry.lock": true, "Cargo.lock": true, "composer.lock": true, "Gemfile.lock": true, "packages.lock.json": true,
+	}
+	entropyEnvExts = map[string]bool{".env": true, ".sh": true, ".bash": true, ".zsh": true, ".properties": true, ".ini": true, ".cfg": true, ".conf": true, ".toml": true}
+)
+
+const (
+	// entropyMinSecretLength keeps the threshold reachable: the entropy of n characters is at most log2(n) bits per character,
+	// e.g. 3 bits for 8 characters, which never reaches the default threshold.
+	entropyMinSecretLength = 16
+	entropyMaxLineLength   = 500 // minified files
+)
+
+func (s *EntropyScanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error) {
+	var findings []*RuleFinding
+	for _, file := range changeFiles {
+		if isEntropyIgnoredFile(file.Filename) {
+			continue
+		}
+		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, file.Filename)
+		content, err := os.ReadFile(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", targetPath, err)
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue // binary
+		}
+		s.logger.InfoContext(ctx, "Start entropy scan", slog.String("file", targetPath))
+		lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+		fileFindings := checkEntropy(repo, headSHA, file.Filename, lines, addedLineNumbers(file), s.threshold)
+		findings = append(findings, filterRuleFindings(file, lines, fileFindings)...)
+	}
+	return generateScanResultFromRuleFindings(findings), nil
+}
+
+func isEntropyIgnoredFile(fileName string) bool {
+	return entropyLockFiles[path.Base(fileName)] || entropyTestPathRegexp.MatchString(fileName)
+}
+
+func entropyLanguageOf(fileName string) entropyLanguage {
+	base := strings.ToLower(path.Base(fileName))
+	ext := path.Ext(base)
+	switch {
+	case ext == ".yaml" || ext == ".yml":
+		return entropyLanguageYAML
+	case entropyEnvExts[ext] || strings.HasPrefix(base, ".env"):
+		return entropyLanguageEnv
+	}
+	return entropyLanguageCode
+}
+
+func checkEntropy(repo *scm.Repository, commit, filePath string, lines []string, added map[int]bool, threshold float64) []*RuleFinding {
+	re := entropyAssignmentRegexps[entropyLanguageOf(filePath)]
+	var findings []*RuleFinding
+	for i, line := range lines {
+		if !added[i+1] || len(line) > entropyMaxLineLength {
+			continue
+		}
+		for _, m := range re.FindAllStringSubmatch(line, -1) {
+			identifier, value := m[1], m[2]
+			if !isSecretIdentifier(identifier) || isPlaceholderSecret(identifier, value) {
+				continue
+			}
+			entropy := shannonEntropy(value)
+			if entropy < threshold {
+				continue
+			}
+			code := strings.TrimSpace(strings.ReplaceAll(line, value, maskSecret(value)))
+			f := newRuleFinding(RULE_SCANNER_ENTROPY, entropyRuleGenericSecret, repo, commit, filePath, i+1, code)
+			f.Description = fmt.Sprintf(entropyRuleGenericSecret.Description, identifier, entropy)
+			f.RawSecret = value
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func isSecretIdentifier(identifier string) bool {
+	if i := strings.LastIndexAny(identifier, ".$"); i >= 0 {
+		identifier = identifier[i+1:] // e.g. config.password, $token
+	}
+	return entropySecretIdentifierRegexp.MatchString(identifier) && !entropyNonSecretSuffixRegexp.MatchString(identifier)
+}
+
+func isPlaceholderSecret(identifier, value string) bool {
+	if len(value) < entropyMinSecretLength || entropyPlaceholderRegexp.MatchString(value) || entropyUUIDRegexp.MatchString(value) {
+		return true
+	}
+	return strings.EqualFold(value, identifier) || strings.Trim(value, value[:1]) == ""
+}
+
+// shannonEntropy returns the Shannon entropy of the string in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// maskSecret keeps the first 4 characters not to post the secret on the PR comment.
+func maskSecret(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
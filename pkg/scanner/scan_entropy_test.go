@@ -0,0 +1,155 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  float64
+	}{
+		{input: "", want: 0},
+		{input: "aaaa", want: 0},
+		{input: "abab", want: 1},
+		{input: "abcdefgh", want: 3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := shannonEntropy(tc.input); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("shannonEntropy() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckEntropy(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	type want struct {
+		line int
+		code string
+	}
+	testCases := []struct {
+		name     string
+		filePath string
+		content  string
+		want     []want
+	}{
+		{
+			name:     "Go",
+			filePath: "internal/config.go",
+			content: `package config
+
+const apiKey = "q8Zt3LmX9vRw2KpN7cYd"
+var tokenURL = "https://example.com/oauth/q8Zt3LmX9vRw2KpN"
+var password = os.Getenv("PASSWORD")
+var cfg = Config{Password: "hunter22", Secret: "Vx7pQ2mZr9LkT4wNb6Ys"}
+var sessionToken = "123e4567-e89b-12d3-a456-426614174000"
+var secret = "your-secret-here-1234"
+var authKey = "k3Zq9XpL7mWv2B"`,
+			want: []want{
+				{line: 3, code: `const apiKey = "q8Zt****************"`},
+				{line: 6, code: `var cfg = Config{Password: "hunter22", Secret: "Vx7p****************"}`},
+			},
+		},
+		{
+			name:     "YAML",
+			filePath: "config/app.yaml",
+			content: `database:
+  password: Jd8sK2lqP0zXm4Vn
+  password_file: /run/secrets/db-password-a8B3kd
+  token: ${TOKEN}`,
+			want: []want{{line: 2, code: `password: Jd8s************`}},
+		},
+		{
+			name:     "dotenv",
+			filePath: ".env.production",
+			content: `export AWS_SECRET_ACCESS_KEY=wJalrXUtnFEMIK7MDENGbPxRfiCYzzKEY9f3
+DB_PASSWORD=changeme
+TIMEOUT=Jd8sK2lqP0zXm4Vn`,
+			want: []want{{line: 1, code: `export AWS_SECRET_ACCESS_KEY=wJal********************************`}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := strings.Split(tc.content, "\n")
+			added := map[int]bool{}
+			for i := range lines {
+				added[i+1] = true
+			}
+			findings := checkEntropy(repo, "headsha", tc.filePath, lines, added, DEFAULT_ENTROPY_THRESHOLD)
+			var got []want
+			for _, f := range findings {
+				got = append(got, want{line: f.Line, code: f.Code})
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("checkEntropy() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEntropyScan(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	headSHA := "headsha"
+	sourceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(sourceDir, "app.py"), `api_key = "q8Zt3LmX9vRw2KpN7cYd"
+client_secret = "Vx7pQ2mZr9LkT4wNb6Ys"  # risken-ignore
+token = "Jd8sK2lqP0zXm4Vn"
+`)
+	writeTestFile(t, filepath.Join(sourceDir, "tests/fixtures/secrets.json"), `{"token": "Jd8sK2lqP0zXm4Vn"}`)
+	writeTestFile(t, filepath.Join(sourceDir, "package-lock.json"), `"integrity": "sha512-Jd8sK2lqP0zXm4Vn"`)
+	changeFiles := []*scm.ChangeFile{
+		{
+			Filename: "app.py",
+			Patch:    "@@ -0,0 +1,2 @@\n+api_key = \"q8Zt3LmX9vRw2KpN7cYd\"\n+client_secret = \"Vx7pQ2mZr9LkT4wNb6Ys\"  # risken-ignore",
+		},
+		{
+			Filename: "tests/fixtures/secrets.json",
+			Patch:    "@@ -0,0 +1 @@\n+{\"token\": \"Jd8sK2lqP0zXm4Vn\"}",
+		},
+		{
+			Filename: "package-lock.json",
+			Patch:    "@@ -0,0 +1 @@\n+\"integrity\": \"sha512-Jd8sK2lqP0zXm4Vn\"",
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		threshold float64
+		want      int
+	}{
+		{name: "Default threshold", threshold: 0, want: 1},
+		{name: "High threshold", threshold: 5, want: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewEntropyScanner(slog.New(slog.NewTextHandler(io.Discard, nil)), tc.threshold)
+			got, err := s.Scan(context.Background(), repo, headSHA, sourceDir, changeFiles)
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if len(got) != tc.want {
+				t.Fatalf("Scan() results = %d, want %d", len(got), tc.want)
+			}
+			if tc.want > 0 && (got[0].File != "app.py" || got[0].Line != 1 || got[0].ScanID != "ENTROPY_GENERIC_SECRET") {
+				t.Errorf("Scan() unexpected result: file=%s, line=%d, scanID=%s", got[0].File, got[0].Line, got[0].ScanID)
+			}
+		})
+	}
+}
@@ -7,22 +7,26 @@ import (
 	"os"
 
 	"github.com/ca-risken/code/pkg/gitleaks"
+	"github.com/ca-risken/security-review/pkg/scm"
 	"github.com/google/go-github/v44/github"
+	"github.com/zricethezav/gitleaks/v8/config"
 	"github.com/zricethezav/gitleaks/v8/detect"
 	"github.com/zricethezav/gitleaks/v8/report"
 )
 
 type GitleaksScanner struct {
 	logger *slog.Logger
+	cache  *ResultCache
 }
 
-func NewGitleaksScanner(logger *slog.Logger) Scanner {
+func NewGitleaksScanner(logger *slog.Logger, cache *ResultCache) Scanner {
 	return &GitleaksScanner{
 		logger: logger,
+		cache:  cache,
 	}
 }
 
-func (s *GitleaksScanner) Scan(ctx context.Context, repo *github.Repository, pr *github.PullRequest, sourceCodePath string, changeFiles []*github.CommitFile) ([]*ScanResult, error) {
+func (s *GitleaksScanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error) {
 	d, err := detect.NewDetectorDefaultConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize detector: %w", err)
@@ -30,7 +34,7 @@ func (s *GitleaksScanner) Scan(ctx context.Context, repo *github.Repository, pr
 
 	gitleaksFindings := []report.Finding{}
 	for _, file := range changeFiles {
-		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, *file.Filename)
+		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, file.Filename)
 		info, err := os.Stat(targetPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to stat %s: %w", targetPath, err)
@@ -39,9 +43,9 @@ func (s *GitleaksScanner) Scan(ctx context.Context, repo *github.Repository, pr
 			s.logger.InfoContext(ctx, "Skip gitleaks scan for empty file", slog.String("file", targetPath))
 			continue
 		}
-		findings, err := d.DetectFiles(targetPath)
+		findings, err := s.detectFile(ctx, d, sourceCodePath, file.Filename)
 		if err != nil {
-			return nil, fmt.Errorf("failed to detect %s: %w", targetPath, err)
+			return nil, err
 		}
 		for _, f := range findings {
 			if isLineInDiff(file, f.Match) {
@@ -49,27 +53,79 @@ func (s *GitleaksScanner) Scan(ctx context.Context, repo *github.Repository, pr
 			}
 		}
 	}
-	return generateScanResultFromGitleaksResults(repo, sourceCodePath, gitleaksFindings), nil
+	return generateScanResultFromGitleaksResults(repo, headSHA, sourceCodePath, gitleaksFindings), nil
 }
 
-func generateScanResultFromGitleaksResults(repo *github.Repository, sourceCodePath string, results []report.Finding) []*ScanResult {
-	gitleaksFinding := gitleaks.GenrateGitleaksFinding(repo, results)
+// detectFile returns the findings of the file, skipping the detection if the same content was scanned clean with the same rules.
+// Only the clean files are cached, not to store the secrets (and the matched lines) in the cache directory.
+func (s *GitleaksScanner) detectFile(ctx context.Context, d *detect.Detector, sourceCodePath, fileName string) ([]report.Finding, error) {
+	targetPath := fmt.Sprintf("%s/%s", sourceCodePath, fileName)
+	var key string
+	if s.cache != nil {
+		content, err := os.ReadFile(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", targetPath, err)
+		}
+		key = cacheKey("gitleaks-clean", moduleVersion("github.com/zricethezav/gitleaks/v8"), hashString(config.DefaultConfig), fileName, content)
+	}
+	var clean bool
+	if s.cache.Get(ctx, key, &clean) && clean {
+		return nil, nil
+	}
+	detected, err := d.DetectFiles(targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect %s: %w", targetPath, err)
+	}
+	var findings []report.Finding
+	for _, f := range detected {
+		if f.File == targetPath { // the detector accumulates the findings of the files detected before
+			findings = append(findings, f)
+		}
+	}
+	if len(findings) == 0 {
+		s.cache.Put(ctx, key, true)
+	}
+	return findings, nil
+}
+
+func generateScanResultFromGitleaksResults(repo *scm.Repository, headSHA, sourceCodePath string, results []report.Finding) []*ScanResult {
+	gitleaksFinding := gitleaks.GenrateGitleaksFinding(gitleaksRepository(repo), results)
 
 	var scanResults []*ScanResult
 	for _, g := range gitleaksFinding {
+		file := removeDirPrefix(sourceCodePath, g.Result.File)
+		g.Result.URL = repo.LinesURL(headSHA, file, g.Result.StartLine, g.Result.EndLine)
+		f := &GitleaksFinding{GitleaksFinding: g}
 		scanResults = append(scanResults, &ScanResult{
 			ScanID:        g.Result.RuleDescription,
-			File:          removeDirPrefix(sourceCodePath, g.Result.File),
+			File:          file,
 			Line:          g.Result.EndLine,
 			DiffHunk:      g.Result.Secret,
-			ReviewComment: generateGitleaksReviewComment(sourceCodePath, g.Result),
-			GitHubURL:     g.Result.GenerateGitHubURL(*repo.HTMLURL),
-			ScanResult:    g,
+			ReviewComment: f.ReviewComment(),
+			GitHubURL:     g.Result.URL,
+			Finding:       f,
 		})
 	}
 	return scanResults
 }
 
+// gitleaksRepository returns the repository in the GitHub model, which the repository metadata of the RISKEN gitleaks finding is based on.
+func gitleaksRepository(repo *scm.Repository) *github.Repository {
+	return &github.Repository{
+		ID:            github.Int64(repo.ID),
+		Name:          github.String(repo.Name),
+		FullName:      github.String(repo.FullName),
+		Description:   github.String(repo.Description),
+		HTMLURL:       github.String(repo.URL),
+		CloneURL:      github.String(repo.CloneURL),
+		DefaultBranch: github.String(repo.DefaultBranch),
+		Visibility:    github.String(repo.Visibility),
+		Private:       github.Bool(repo.Private),
+		Archived:      github.Bool(repo.Archived),
+		Topics:        repo.Topics,
+	}
+}
+
 const (
 	GITLEAKS_REVIEW_COMMENT_TEMPLATE = `
 シークレット情報が含まれている可能性があります👀
@@ -84,6 +140,6 @@ const (
 `
 )
 
-func generateGitleaksReviewComment(sourceCodePath string, f *gitleaks.LeakFinding) string {
+func generateGitleaksReviewComment(f *gitleaks.LeakFinding) string {
 	return fmt.Sprintf(GITLEAKS_REVIEW_COMMENT_TEMPLATE, f.RuleDescription)
 }
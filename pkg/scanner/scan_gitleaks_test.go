@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/ca-risken/code/pkg/gitleaks"
+	"github.com/ca-risken/security-review/pkg/scm"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-github/v44/github"
 	"github.com/zricethezav/gitleaks/v8/report"
@@ -16,7 +17,8 @@ import (
 
 func TestGenerateScanResultFromGitleaksResults(t *testing.T) {
 	type Args struct {
-		repo           *github.Repository
+		repo           *scm.Repository
+		headSHA        string
 		sourceCodePath string
 		results        []report.Finding
 	}
@@ -28,10 +30,11 @@ func TestGenerateScanResultFromGitleaksResults(t *testing.T) {
 		{
 			name: "OK",
 			args: &Args{
-				repo: &github.Repository{
-					HTMLURL:  github.String("https://github.com/owner/repo"),
-					FullName: github.String("owner/repo"),
+				repo: &scm.Repository{
+					URL:      "https://github.com/owner/repo",
+					FullName: "owner/repo",
 				},
+				headSHA:        "headsha",
 				sourceCodePath: "/path/to/source",
 				results: []report.Finding{
 					{
@@ -66,9 +69,18 @@ func TestGenerateScanResultFromGitleaksResults(t *testing.T) {
 	もし、有効なシークレット情報の場合はキーのローテーションや権限の削除（キーの無効化）を行ってください。
 	どうしてもシークレット情報をコミットする必要がある場合は、プライベートリポジトリになっていることを確認しアクセスできる人を限定してください。
 `,
-					GitHubURL: "https://github.com/owner/repo/blob/commit//path/to/source/file1.go#L1-L1",
-					ScanResult: &gitleaks.GitleaksFinding{
-						RepositoryMetadata: &gitleaks.RepositoryMetadata{FullName: github.String("owner/repo")},
+					GitHubURL: "https://github.com/owner/repo/blob/headsha/file1.go#L1-L1",
+					Finding: &GitleaksFinding{GitleaksFinding: &gitleaks.GitleaksFinding{
+						RepositoryMetadata: &gitleaks.RepositoryMetadata{
+							ID:          github.Int64(0),
+							Name:        github.String(""),
+							FullName:    github.String("owner/repo"),
+							Description: github.String(""),
+							CloneURL:    github.String(""),
+							Archived:    github.Bool(false),
+							Private:     github.Bool(false),
+							Visibility:  github.String(""),
+						},
 						Result: &gitleaks.LeakFinding{
 							DataSourceID:    "cfb3a824c546bd5fd24a2e7450a4a08e8fb5c7bc682262ab4e36cfdc6b9d570f",
 							File:            "/path/to/source/file1.go",
@@ -82,9 +94,9 @@ func TestGenerateScanResultFromGitleaksResults(t *testing.T) {
 							Author:          "author",
 							Email:           "email",
 							Message:         "message",
-							URL:             "https://github.com/owner/repo/blob/commit//path/to/source/file1.go#L1-L1",
+							URL:             "https://github.com/owner/repo/blob/headsha/file1.go#L1-L1",
 						},
-					},
+					}},
 				},
 			},
 		},
@@ -92,7 +104,7 @@ func TestGenerateScanResultFromGitleaksResults(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			got := generateScanResultFromGitleaksResults(tt.args.repo, tt.args.sourceCodePath, tt.args.results)
+			got := generateScanResultFromGitleaksResults(tt.args.repo, tt.args.headSHA, tt.args.sourceCodePath, tt.args.results)
 			if diff := cmp.Diff(tt.want, got); diff != "" {
 				t.Errorf("generateScanResultFromGitleaksResults() mismatch (-want +got):\n%s", diff)
 			}
@@ -103,11 +115,11 @@ func TestGenerateScanResultFromGitleaksResults(t *testing.T) {
 func TestGitleaksScan(t *testing.T) {
 	t.Parallel()
 
-	repo := &github.Repository{
-		HTMLURL:  github.String("https://github.com/owner/repo"),
-		FullName: github.String("owner/repo"),
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
 	}
-	pr := &github.PullRequest{}
+	headSHA := ""
 
 	tests := []struct {
 		name        string
@@ -136,15 +148,15 @@ func TestGitleaksScan(t *testing.T) {
 				t.Fatalf("failed to create test file: %v", err)
 			}
 
-			scanner := NewGitleaksScanner(slog.New(slog.NewTextHandler(io.Discard, nil)))
-			changeFiles := []*github.CommitFile{
+			scanner := NewGitleaksScanner(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+			changeFiles := []*scm.ChangeFile{
 				{
-					Filename: github.String(tt.fileName),
-					Patch:    github.String(tt.patch),
+					Filename: tt.fileName,
+					Patch:    tt.patch,
 				},
 			}
 
-			got, err := scanner.Scan(context.Background(), repo, pr, tmpDir, changeFiles)
+			got, err := scanner.Scan(context.Background(), repo, headSHA, tmpDir, changeFiles)
 			if err != nil {
 				t.Fatalf("Scan() error = %v", err)
 			}
@@ -0,0 +1,411 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"gopkg.in/yaml.v3"
+)
+
+// IaCScanner checks the Terraform files and the Kubernetes manifests for misconfigurations.
+type IaCScanner struct {
+	logger *slog.Logger
+}
+
+func NewIaCScanner(logger *slog.Logger) Scanner {
+	return &IaCScanner{
+		logger: logger,
+	}
+}
+
+var (
+	iacRuleS3PublicAccess = &rule{
+		ID:             "IAC_S3_PUBLIC_ACCESS",
+		Severity:       SEVERITY_HIGH,
+		Description:    "S3バケットが公開される設定になっています。意図せずデータが外部に公開される恐れがあります。",
+		Recommendation: "acl は private を指定し、aws_s3_bucket_public_access_block の block_public_acls / block_public_policy / ignore_public_acls / restrict_public_buckets を全て true にしてください。公開が必要な場合は CloudFront などを経由してください。",
+	}
+	iacRuleOpenSecurityGroup = &rule{
+		ID:             "IAC_OPEN_SECURITY_GROUP",
+		Severity:       SEVERITY_HIGH,
+		Description:    "セキュリティグループがインターネット (0.0.0.0/0 または ::/0) からの管理用・データベースなどのポートへのアクセスを許可しています。",
+		Recommendation: "送信元を必要なIPアドレス範囲やセキュリティグループに限定してください。管理用のアクセスには SSM Session Manager や踏み台サーバーの利用を検討してください。",
+	}
+	iacRuleIAMWildcardAction = &rule{
+		ID:             "IAC_IAM_WILDCARD_ACTION",
+		Severity:       SEVERITY_HIGH,
+		Description:    "IAMポリシーでワイルドカードのアクション (\"*\" や \"s3:*\") を許可しています。必要以上の権限が付与されます。",
+		Recommendation: "最小権限の原則に従い、必要なアクションのみを列挙してください (例: \"s3:GetObject\")。",
+	}
+	iacRuleK8sPrivilegedContainer = &rule{
+		ID:             "IAC_K8S_PRIVILEGED_CONTAINER",
+		Severity:       SEVERITY_HIGH,
+		Description:    "特権コンテナ (privileged: true) が指定されています。コンテナからホストの全てのデバイスにアクセスでき、コンテナエスケープの恐れがあります。",
+		Recommendation: "privileged: false にし、必要な権限は securityContext.capabilities.add で個別に付与してください。",
+	}
+	iacRuleK8sHostPath = &rule{
+		ID:             "IAC_K8S_HOST_PATH",
+		Severity:       SEVERITY_MEDIUM,
+		Description:    "hostPath ボリュームがマウントされています。ノードのファイルシステムへのアクセスにより、コンテナエスケープや情報漏洩の恐れがあります。",
+		Recommendation: "emptyDir や PersistentVolumeClaim などを利用してください。hostPath が必要な場合は readOnly: true にし、マウントするパスを限定してください。",
+	}
+	iacRuleK8sMissingResourceLimits = &rule{
+		ID:             "IAC_K8S_MISSING_RESOURCE_LIMITS",
+		Severity:       SEVERITY_LOW,
+		Description:    "コンテナにリソースの上限 (resources.limits) が設定されていません。1つのコンテナがノードのリソースを使い切り、他のワークロードに影響を与える恐れがあります。",
+		Recommendation: "resources.limits に cpu と memory を設定してください。",
+	}
+)
+
+var (
+	iacS3PublicACLs          = map[string]bool{"public-read": true, "public-read-write": true, "authenticated-read": true}
+	iacS3PublicAccessBlocks  = []string{"block_public_acls", "block_public_policy", "ignore_public_acls", "restrict_public_buckets"}
+	iacOpenCIDRRegexp        = regexp.MustCompile(`"(0\.0\.0\.0/0|::/0)"`)
+	iacIAMActionKeyRegexp    = regexp.MustCompile(`(?i)^\s*"?actions?"?\s*[:=]`)
+	iacIAMWildcardRegexp     = regexp.MustCompile(`"(\*|[\w-]+:\*)"`)
+	iacSensitivePorts        = []int{20, 21, 22, 23, 445, 1433, 1521, 2375, 2376, 3306, 3389, 5432, 5601, 5900, 6379, 9200, 11211, 27017}
+	iacK8sPodTemplateKinds   = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "ReplicaSet": true, "ReplicationController": true, "Job": true}
+	terraformBlockRegexp     = regexp.MustCompile(`^([\w-]+)((?:\s+(?:"[^"]*"|[\w-]+))*)\s*\{$`)
+	terraformAttributeRegexp = regexp.MustCompile(`^([\w-]+)\s*=\s*(.*)$`)
+	terraformHeredocRegexp   = regexp.MustCompile(`<<-?\s*([A-Za-z_]+)\s*$`)
+)
+
+func (s *IaCScanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error) {
+	var findings []*RuleFinding
+	for _, file := range changeFiles {
+		isTerraform := path.Ext(file.Filename) == ".tf"
+		if !isTerraform && !isKubernetesManifestCandidate(file.Filename) {
+			continue
+		}
+		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, file.Filename)
+		content, err := os.ReadFile(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", targetPath, err)
+		}
+		lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+		var fileFindings []*RuleFinding
+		if isTerraform {
+			s.logger.InfoContext(ctx, "Start terraform scan", slog.String("file", targetPath))
+			fileFindings = checkTerraform(repo, headSHA, file.Filename, lines)
+		} else {
+			fileFindings, err = checkKubernetesManifest(repo, headSHA, file.Filename, content, lines)
+			if err != nil {
+				// Not a plain YAML (e.g. Helm templates), so just skip it.
+				s.logger.DebugContext(ctx, "Skip invalid yaml", slog.String("file", targetPath), slog.String("err", err.Error()))
+				continue
+			}
+		}
+		findings = append(findings, filterRuleFindings(file, lines, fileFindings)...)
+	}
+	return generateScanResultFromRuleFindings(findings), nil
+}
+
+func isKubernetesManifestCandidate(fileName string) bool {
+	ext := path.Ext(fileName)
+	return (ext == ".yaml" || ext == ".yml") && !isWorkflowFile(fileName)
+}
+
+type iacChecker struct {
+	repo     *scm.Repository
+	commit   string
+	path     string
+	lines    []string
+	findings []*RuleFinding
+}
+
+func (c *iacChecker) report(r *rule, resource string, line int) {
+	f := newRuleFinding(RULE_SCANNER_IAC, r, c.repo, c.commit, c.path, line, strings.TrimSpace(c.lines[line-1]))
+	f.Resource = resource
+	c.findings = append(c.findings, f)
+}
+
+// terraformBlock is a block of the Terraform configuration (e.g. resource "aws_s3_bucket" "name" { ... }).
+type terraformBlock struct {
+	labels     []string
+	attributes map[string]*terraformAttribute
+	blocks     []*terraformBlock
+	startLine  int
+	endLine    int
+}
+
+type terraformAttribute struct {
+	value string
+	line  int
+}
+
+func (b *terraformBlock) attribute(key string) string {
+	if a, ok := b.attributes[key]; ok {
+		return strings.Trim(a.value, `"`)
+	}
+	return ""
+}
+
+// parseTerraform parses the blocks and the attributes line by line to keep the line numbers.
+// Multi-line expressions (lists, jsonencode, heredoc) are joined into the attribute value.
+func parseTerraform(lines []string) []*terraformBlock {
+	root := &terraformBlock{}
+	stack := []*terraformBlock{root}
+	var currentAttr *terraformAttribute
+	depth := 0 // nesting depth of the multi-line expression
+	heredoc := ""
+	for i, l := range lines {
+		line := strings.TrimSpace(l)
+		if heredoc != "" {
+			currentAttr.value += "\n" + line
+			if line == heredoc {
+				heredoc, currentAttr = "", nil
+			}
+			continue
+		}
+		if currentAttr != nil {
+			currentAttr.value += "\n" + line
+			depth += terraformNestingDelta(line)
+			if depth <= 0 {
+				currentAttr = nil
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		current := stack[len(stack)-1]
+		if line == "}" && len(stack) > 1 {
+			current.endLine = i + 1
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if m := terraformBlockRegexp.FindStringSubmatch(line); m != nil {
+			labels := []string{m[1]}
+			for _, label := range strings.Fields(m[2]) {
+				labels = append(labels, strings.Trim(label, `"`))
+			}
+			block := &terraformBlock{labels: labels, attributes: map[string]*terraformAttribute{}, startLine: i + 1}
+			current.blocks = append(current.blocks, block)
+			stack = append(stack, block)
+			continue
+		}
+		m := terraformAttributeRegexp.FindStringSubmatch(line)
+		if m == nil || current == root {
+			continue
+		}
+		attr := &terraformAttribute{value: strings.TrimSpace(m[2]), line: i + 1}
+		current.attributes[m[1]] = attr
+		if h := terraformHeredocRegexp.FindStringSubmatch(line); h != nil {
+			heredoc, currentAttr = h[1], attr
+		} else if depth = terraformNestingDelta(line); depth > 0 {
+			currentAttr = attr
+		}
+	}
+	return root.blocks
+}
+
+func terraformNestingDelta(line string) int {
+	return strings.Count(line, "{") + strings.Count(line, "[") + strings.Count(line, "(") -
+		strings.Count(line, "}") - strings.Count(line, "]") - strings.Count(line, ")")
+}
+
+func checkTerraform(repo *scm.Repository, commit, filePath string, lines []string) []*RuleFinding {
+	c := &iacChecker{
+		repo:   repo,
+		commit: commit,
+		path:   filePath,
+		lines:  lines,
+	}
+	for _, block := range parseTerraform(lines) {
+		if len(block.labels) != 3 || (block.labels[0] != "resource" && block.labels[0] != "data") {
+			continue
+		}
+		address := fmt.Sprintf("%s.%s", block.labels[1], block.labels[2])
+		if block.labels[0] == "data" {
+			address = "data." + address
+		}
+		switch block.labels[1] {
+		case "aws_s3_bucket", "aws_s3_bucket_acl":
+			if iacS3PublicACLs[block.attribute("acl")] {
+				c.report(iacRuleS3PublicAccess, address, block.attributes["acl"].line)
+			}
+		case "aws_s3_bucket_public_access_block":
+			for _, key := range iacS3PublicAccessBlocks {
+				if block.attribute(key) == "false" {
+					c.report(iacRuleS3PublicAccess, address, block.attributes[key].line)
+					break
+				}
+			}
+		case "aws_security_group":
+			for _, ingress := range block.blocks {
+				if ingress.labels[0] == "ingress" {
+					c.checkIngress(ingress, address, "cidr_blocks", "ipv6_cidr_blocks")
+				}
+			}
+		case "aws_security_group_rule":
+			if block.attribute("type") == "ingress" {
+				c.checkIngress(block, address, "cidr_blocks", "ipv6_cidr_blocks")
+			}
+		case "aws_vpc_security_group_ingress_rule":
+			c.checkIngress(block, address, "cidr_ipv4", "cidr_ipv6")
+		}
+		c.checkIAMActions(block, address)
+	}
+	return c.findings
+}
+
+// checkIngress reports the ingress open to the internet on the sensitive ports (or all ports).
+func (c *iacChecker) checkIngress(ingress *terraformBlock, address string, cidrKeys ...string) {
+	var cidr *terraformAttribute
+	for _, key := range cidrKeys {
+		if a, ok := ingress.attributes[key]; ok && iacOpenCIDRRegexp.MatchString(a.value) {
+			cidr = a
+			break
+		}
+	}
+	if cidr == nil {
+		return
+	}
+	protocol := ingress.attribute("protocol")
+	if protocol == "" {
+		protocol = ingress.attribute("ip_protocol")
+	}
+	if protocol == "-1" || protocol == "all" {
+		c.report(iacRuleOpenSecurityGroup, address, cidr.line)
+		return
+	}
+	from, fromErr := strconv.Atoi(ingress.attribute("from_port"))
+	to, toErr := strconv.Atoi(ingress.attribute("to_port"))
+	if fromErr != nil || toErr != nil {
+		return // variables can not be evaluated
+	}
+	for _, port := range iacSensitivePorts {
+		if from <= port && port <= to {
+			c.report(iacRuleOpenSecurityGroup, address, cidr.line)
+			return
+		}
+	}
+}
+
+// checkIAMActions reports the wildcard actions in the policy documents (HCL, jsonencode and heredoc JSON).
+func (c *iacChecker) checkIAMActions(block *terraformBlock, address string) {
+	inList := false // in the multi-line list of the actions
+	for l := block.startLine; l <= block.endLine && l <= len(c.lines); l++ {
+		value := c.lines[l-1]
+		switch loc := iacIAMActionKeyRegexp.FindStringIndex(value); {
+		case loc != nil:
+			value = value[loc[1]:]
+			inList = strings.Contains(value, "[") && !strings.Contains(value, "]")
+		case inList:
+			inList = !strings.Contains(value, "]")
+		default:
+			continue
+		}
+		if iacIAMWildcardRegexp.MatchString(value) {
+			c.report(iacRuleIAMWildcardAction, address, l)
+		}
+	}
+}
+
+// checkKubernetesManifest checks the pod specs of the workloads in the (multi-document) manifest.
+func checkKubernetesManifest(repo *scm.Repository, commit, filePath string, content []byte, lines []string) ([]*RuleFinding, error) {
+	c := &iacChecker{
+		repo:   repo,
+		commit: commit,
+		path:   filePath,
+		lines:  lines,
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := doc.Content[0]
+		kind := yamlMappingValue(root, "kind")
+		if yamlMappingValue(root, "apiVersion") == nil || kind == nil {
+			continue // not a kubernetes manifest
+		}
+		resource := kind.Value
+		if metadata := yamlMappingValue(root, "metadata"); metadata != nil {
+			if namespace := yamlMappingValue(metadata, "namespace"); namespace != nil {
+				resource += "/" + namespace.Value
+			}
+			if name := yamlMappingValue(metadata, "name"); name != nil {
+				resource += "/" + name.Value
+			}
+		}
+		if podSpec := kubernetesPodSpec(root, kind.Value); podSpec != nil {
+			c.checkPodSpec(podSpec, resource)
+		}
+	}
+	return c.findings, nil
+}
+
+func kubernetesPodSpec(root *yaml.Node, kind string) *yaml.Node {
+	spec := yamlMappingValue(root, "spec")
+	switch {
+	case kind == "Pod":
+		return spec
+	case iacK8sPodTemplateKinds[kind]:
+		return yamlMappingValue(yamlMappingValue(spec, "template"), "spec")
+	case kind == "CronJob":
+		jobSpec := yamlMappingValue(yamlMappingValue(spec, "jobTemplate"), "spec")
+		return yamlMappingValue(yamlMappingValue(jobSpec, "template"), "spec")
+	}
+	return nil
+}
+
+func (c *iacChecker) checkPodSpec(podSpec *yaml.Node, resource string) {
+	for _, key := range []string{"initContainers", "containers"} {
+		containers := yamlMappingValue(podSpec, key)
+		if containers == nil || containers.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, container := range containers.Content {
+			if container.Kind != yaml.MappingNode {
+				continue
+			}
+			securityContext := yamlMappingValue(container, "securityContext")
+			if privileged := yamlMappingValue(securityContext, "privileged"); privileged != nil && privileged.Value == "true" {
+				c.report(iacRuleK8sPrivilegedContainer, resource, privileged.Line)
+			}
+			if yamlMappingValue(yamlMappingValue(container, "resources"), "limits") == nil {
+				c.report(iacRuleK8sMissingResourceLimits, resource, container.Line)
+			}
+		}
+	}
+	volumes := yamlMappingValue(podSpec, "volumes")
+	if volumes == nil || volumes.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, volume := range volumes.Content {
+		if hostPath := yamlMappingKey(volume, "hostPath"); hostPath != nil {
+			c.report(iacRuleK8sHostPath, resource, hostPath.Line)
+		}
+	}
+}
+
+// yamlMappingKey returns the key node in the mapping node.
+func yamlMappingKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
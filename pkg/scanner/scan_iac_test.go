@@ -0,0 +1,294 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ca-risken/datasource-api/pkg/message"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+type iacWant struct {
+	ruleID   string
+	resource string
+	line     int
+}
+
+func TestCheckTerraform(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	testCases := []struct {
+		name    string
+		content string
+		want    []iacWant
+	}{
+		{
+			name: "S3 public access",
+			content: `resource "aws_s3_bucket" "public" {
+  bucket = "public"
+  acl    = "public-read"
+}
+
+resource "aws_s3_bucket_acl" "private" {
+  bucket = aws_s3_bucket.public.id
+  acl    = "private"
+}
+
+resource "aws_s3_bucket_public_access_block" "public" {
+  bucket                  = aws_s3_bucket.public.id
+  block_public_acls       = true
+  block_public_policy     = false
+  ignore_public_acls      = true
+  restrict_public_buckets = false
+}`,
+			want: []iacWant{
+				{ruleID: "IAC_S3_PUBLIC_ACCESS", resource: "aws_s3_bucket.public", line: 3},
+				{ruleID: "IAC_S3_PUBLIC_ACCESS", resource: "aws_s3_bucket_public_access_block.public", line: 14},
+			},
+		},
+		{
+			name: "Security groups",
+			content: `resource "aws_security_group" "web" {
+  name = "web"
+
+  ingress {
+    from_port   = 443
+    to_port     = 443
+    protocol    = "tcp"
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+  ingress {
+    from_port = 22
+    to_port   = 22
+    protocol  = "tcp"
+    cidr_blocks = [
+      "0.0.0.0/0",
+    ]
+  }
+  tags = {
+    Name = "web"
+  }
+}
+
+resource "aws_security_group_rule" "all" {
+  type              = "ingress"
+  from_port         = 0
+  to_port           = 0
+  protocol          = "-1"
+  ipv6_cidr_blocks  = ["::/0"]
+  security_group_id = aws_security_group.web.id
+}
+
+resource "aws_vpc_security_group_ingress_rule" "db" {
+  security_group_id = aws_security_group.web.id
+  cidr_ipv4         = "0.0.0.0/0"
+  from_port         = 3000
+  to_port           = 3400
+  ip_protocol       = "tcp"
+}
+
+resource "aws_vpc_security_group_ingress_rule" "internal" {
+  security_group_id = aws_security_group.web.id
+  cidr_ipv4         = "10.0.0.0/8"
+  from_port         = 22
+  to_port           = 22
+  ip_protocol       = "tcp"
+}`,
+			want: []iacWant{
+				{ruleID: "IAC_OPEN_SECURITY_GROUP", resource: "aws_security_group.web", line: 14},
+				{ruleID: "IAC_OPEN_SECURITY_GROUP", resource: "aws_security_group_rule.all", line: 28},
+				{ruleID: "IAC_OPEN_SECURITY_GROUP", resource: "aws_vpc_security_group_ingress_rule.db", line: 34},
+			},
+		},
+		{
+			name: "IAM wildcard actions",
+			content: `data "aws_iam_policy_document" "admin" {
+  statement {
+    actions   = ["*"]
+    resources = ["*"]
+  }
+  statement {
+    actions = [
+      "s3:GetObject",
+      "s3:*",
+    ]
+    resources = ["*"]
+  }
+}
+
+resource "aws_iam_policy" "json" {
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [{
+      Effect    = "Allow"
+      Action    = "ec2:*"
+      NotAction = "*"
+      Resource  = "*"
+    }]
+  })
+}
+
+resource "aws_iam_role_policy" "heredoc" {
+  policy = <<POLICY
+{
+  "Statement": [{
+    "Effect": "Allow",
+    "Action": ["s3:GetObject"],
+    "Resource": "*"
+  }, {
+    "Effect": "Allow",
+    "Action": [
+      "iam:*"
+    ],
+    "Resource": "*"
+  }]
+}
+POLICY
+}`,
+			want: []iacWant{
+				{ruleID: "IAC_IAM_WILDCARD_ACTION", resource: "data.aws_iam_policy_document.admin", line: 3},
+				{ruleID: "IAC_IAM_WILDCARD_ACTION", resource: "data.aws_iam_policy_document.admin", line: 9},
+				{ruleID: "IAC_IAM_WILDCARD_ACTION", resource: "aws_iam_policy.json", line: 20},
+				{ruleID: "IAC_IAM_WILDCARD_ACTION", resource: "aws_iam_role_policy.heredoc", line: 37},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := checkTerraform(repo, "headsha", "main.tf", strings.Split(tc.content, "\n"))
+			var got []iacWant
+			for _, f := range findings {
+				got = append(got, iacWant{ruleID: f.RuleID, resource: f.Resource, line: f.Line})
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(iacWant{})); diff != "" {
+				t.Errorf("checkTerraform() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckKubernetesManifest(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	content := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox
+          resources:
+            limits:
+              cpu: 100m
+      containers:
+        - name: app
+          image: app
+          securityContext:
+            privileged: true
+      volumes:
+        - name: docker
+          hostPath:
+            path: /var/run/docker.sock
+        - name: tmp
+          emptyDir: {}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  privileged: "true"
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: batch
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: batch
+              image: batch
+`
+	want := []iacWant{
+		{ruleID: "IAC_K8S_PRIVILEGED_CONTAINER", resource: "Deployment/prod/app", line: 19},
+		{ruleID: "IAC_K8S_MISSING_RESOURCE_LIMITS", resource: "Deployment/prod/app", line: 16},
+		{ruleID: "IAC_K8S_HOST_PATH", resource: "Deployment/prod/app", line: 22},
+		{ruleID: "IAC_K8S_MISSING_RESOURCE_LIMITS", resource: "CronJob/batch", line: 44},
+	}
+	findings, err := checkKubernetesManifest(repo, "headsha", "k8s/app.yaml", []byte(content), strings.Split(content, "\n"))
+	if err != nil {
+		t.Fatalf("checkKubernetesManifest() error = %v", err)
+	}
+	var got []iacWant
+	for _, f := range findings {
+		got = append(got, iacWant{ruleID: f.RuleID, resource: f.Resource, line: f.Line})
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(iacWant{})); diff != "" {
+		t.Errorf("checkKubernetesManifest() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIaCScan(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	headSHA := "headsha"
+	sourceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(sourceDir, "infra/s3.tf"), `resource "aws_s3_bucket" "logs" {
+  acl = "public-read"
+}
+resource "aws_s3_bucket" "assets" {
+  acl = "public-read"
+}
+`)
+	writeTestFile(t, filepath.Join(sourceDir, "chart/templates/pod.yaml"), "{{- if .Values.enabled }}\nkind: Pod\n")
+	changeFiles := []*scm.ChangeFile{
+		{
+			Filename: "infra/s3.tf",
+			Patch:    "@@ -3,0 +4,3 @@\n+resource \"aws_s3_bucket\" \"assets\" {\n+  acl = \"public-read\"\n+}",
+		},
+		{
+			Filename: "chart/templates/pod.yaml",
+			Patch:    "@@ -0,0 +1,2 @@\n+{{- if .Values.enabled }}\n+kind: Pod",
+		},
+	}
+
+	s := NewIaCScanner(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	got, err := s.Scan(context.Background(), repo, headSHA, sourceDir, changeFiles)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Scan() results = %d, want 1", len(got))
+	}
+	f := got[0].Finding.(*RuleFinding)
+	if got[0].Line != 5 || f.Resource != "aws_s3_bucket.assets" {
+		t.Errorf("Scan() line = %d, resource = %s, want 5, aws_s3_bucket.assets", got[0].Line, f.Resource)
+	}
+	if f.DataSource() != IAC_DATA_SOURCE {
+		t.Errorf("DataSource() = %s, want %s", f.DataSource(), IAC_DATA_SOURCE)
+	}
+	if !strings.Contains(got[0].ReviewComment, "- リソース: aws_s3_bucket.assets") {
+		t.Errorf("ReviewComment does not contain the resource: %s", got[0].ReviewComment)
+	}
+	if ds := (&RuleFinding{ScannerName: RULE_SCANNER_WORKFLOW}).DataSource(); ds != message.CodeScanDataSource {
+		t.Errorf("DataSource() = %s, want %s", ds, message.CodeScanDataSource)
+	}
+}
@@ -0,0 +1,236 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ca-risken/core/proto/finding"
+	"github.com/ca-risken/datasource-api/pkg/message"
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+// rule is a check of the built-in rule based scanners (workflow, dockerfile, iac, entropy).
+type rule struct {
+	ID             string
+	Severity       string
+	Description    string
+	Recommendation string
+}
+
+// RuleFinding is a finding detected by the built-in rule based scanners.
+type RuleFinding struct {
+	ScannerName    string `json:"scanner"` // RULE_SCANNER_*
+	RuleID         string `json:"rule_id"`
+	Level          string `json:"severity"`
+	Description    string `json:"description"`
+	Recommendation string `json:"recommendation"`
+	Repository     string `json:"repository"`
+	Path           string `json:"path"`
+	Line           int    `json:"line"`
+	Resource       string `json:"resource,omitempty"`
+	Code           string `json:"code,omitempty"`
+	GitHubURL      string `json:"github_url"`
+	RawSecret      string `json:"-"` // raw secret for the verification, never sent outside
+}
+
+const (
+	RULE_SCANNER_WORKFLOW   = "workflow"
+	RULE_SCANNER_DOCKERFILE = "dockerfile"
+	RULE_SCANNER_IAC        = "iac"
+	RULE_SCANNER_ENTROPY    = "entropy"
+
+	// IAC_DATA_SOURCE is the RISKEN data source for the IaC misconfigurations (not defined in datasource-api).
+	IAC_DATA_SOURCE = "code:iac"
+	// ENTROPY_DATA_SOURCE is the RISKEN data source for the high-entropy strings, not to be mixed with the gitleaks findings.
+	ENTROPY_DATA_SOURCE = "code:entropy"
+)
+
+var ruleScannerTitles = map[string]string{
+	RULE_SCANNER_WORKFLOW:   "GitHub Actionsワークフロースキャン結果",
+	RULE_SCANNER_DOCKERFILE: "Dockerfileスキャン結果",
+	RULE_SCANNER_IAC:        "IaCスキャン結果",
+	RULE_SCANNER_ENTROPY:    "シークレットスキャン結果 (エントロピー)",
+}
+
+var ruleScannerDataSources = map[string]string{
+	RULE_SCANNER_IAC:     IAC_DATA_SOURCE,
+	RULE_SCANNER_ENTROPY: ENTROPY_DATA_SOURCE,
+}
+
+func newRuleFinding(scannerName string, r *rule, repo *scm.Repository, commit, path string, line int, code string) *RuleFinding {
+	return &RuleFinding{
+		ScannerName:    scannerName,
+		RuleID:         r.ID,
+		Level:          r.Severity,
+		Description:    r.Description,
+		Recommendation: r.Recommendation,
+		Repository:     repo.FullName,
+		Path:           path,
+		Line:           line,
+		Code:           code,
+		GitHubURL:      repo.FileURL(commit, path, line),
+	}
+}
+
+// filterRuleFindings returns the findings on the lines added in the patch, excluding the suppressed ones.
+func filterRuleFindings(file *scm.ChangeFile, lines []string, findings []*RuleFinding) []*RuleFinding {
+	added := addedLineNumbers(file)
+	filtered := []*RuleFinding{}
+	for _, f := range findings {
+		if added[f.Line] && !isSuppressed(lines, f.Line, f.RuleID) {
+			filtered = append(filtered, f)
+		}
+	}
+	return filtered
+}
+
+var (
+	suppressionRegexp = regexp.MustCompile(`risken-ignore(?::(.*))?`)
+	ruleIDRegexp      = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+)
+
+// isSuppressed returns true if the line or the previous line has the suppression comment.
+// e.g. "# risken-ignore" (all rules), "# risken-ignore: RULE_A, RULE_B because ..." or "# risken-ignore: RULE_A -- reason"
+func isSuppressed(lines []string, line int, ruleID string) bool {
+	for _, l := range []int{line, line - 1} {
+		if l <= 0 || l > len(lines) {
+			continue
+		}
+		m := suppressionRegexp.FindStringSubmatch(lines[l-1])
+		if m == nil {
+			continue
+		}
+		ids, all := parseSuppression(m[1])
+		if all || slices.Contains(ids, ruleID) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSuppression returns the rule IDs listed in the suppression comment, or all if no rule IDs are listed.
+// The list ends at "--" or the first token which is not a rule ID, so the rest of the comment is a free text.
+func parseSuppression(list string) (ids []string, all bool) {
+	list, _, _ = strings.Cut(list, "--")
+	for _, token := range strings.Split(list, ",") {
+		fields := strings.Fields(token)
+		if len(fields) == 0 || !ruleIDRegexp.MatchString(fields[0]) {
+			break
+		}
+		ids = append(ids, fields[0])
+		if len(fields) > 1 {
+			break // followed by the free text, e.g. "RULE_A because ..."
+		}
+	}
+	return ids, len(ids) == 0 && strings.TrimSpace(list) == ""
+}
+
+func generateScanResultFromRuleFindings(findings []*RuleFinding) []*ScanResult {
+	var scanResults []*ScanResult
+	for _, f := range findings {
+		scanResults = append(scanResults, &ScanResult{
+			ScanID:        f.RuleID,
+			File:          f.Path,
+			Line:          f.Line,
+			DiffHunk:      f.Code,
+			ReviewComment: f.ReviewComment(),
+			GitHubURL:     f.GitHubURL,
+			Finding:       f,
+		})
+	}
+	return scanResults
+}
+
+const (
+	RULE_REVIEW_COMMENT_TEMPLATE = `
+問題のコードを発見しました。修正が必要か確認してください🙏
+
+#### %s
+
+- ルールID: %s
+- 重要度: %s%s
+- 説明:
+	%s
+- 推奨対応:
+	%s
+`
+)
+
+func (f *RuleFinding) ReviewComment() string {
+	resource := ""
+	if f.Resource != "" {
+		resource = fmt.Sprintf("\n- リソース: %s", f.Resource)
+	}
+	return fmt.Sprintf(RULE_REVIEW_COMMENT_TEMPLATE, ruleScannerTitles[f.ScannerName], f.RuleID, f.Level, resource, f.Description, f.Recommendation)
+}
+
+func (f *RuleFinding) DataSource() string {
+	if dataSource, ok := ruleScannerDataSources[f.ScannerName]; ok {
+		return dataSource
+	}
+	return message.CodeScanDataSource
+}
+
+func (f *RuleFinding) Scanner() string {
+	return f.ScannerName
+}
+
+func (f *RuleFinding) Severity() string {
+	return f.Level
+}
+
+func (f *RuleFinding) CWE() []string {
+	return nil
+}
+
+func (f *RuleFinding) Secret() string {
+	return f.RawSecret
+}
+
+func (f *RuleFinding) Fingerprint() string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s/%s/line-%d", f.ScannerName, f.Repository, f.Path, f.RuleID, f.Line)))
+	return hex.EncodeToString(hash[:])
+}
+
+func (f *RuleFinding) PutFindingRequest(projectID uint32) (*finding.PutFindingRequest, error) {
+	buf, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: project_id=%d, repository=%s, err=%w", projectID, f.Repository, err)
+	}
+	return &finding.PutFindingRequest{
+		ProjectId: projectID,
+		Finding: &finding.FindingForUpsert{
+			Description:      f.description(),
+			DataSource:       f.DataSource(),
+			DataSourceId:     f.Fingerprint(),
+			ResourceName:     f.Repository,
+			ProjectId:        projectID,
+			OriginalScore:    getSeverityScore(f.Level),
+			OriginalMaxScore: 1.0,
+			Data:             string(buf),
+		},
+	}, nil
+}
+
+func (f *RuleFinding) description() string {
+	if f.Resource != "" {
+		return fmt.Sprintf("Detect %s finding (%s) in %s", f.ScannerName, f.RuleID, f.Resource)
+	}
+	return fmt.Sprintf("Detect %s finding (%s)", f.ScannerName, f.RuleID)
+}
+
+func (f *RuleFinding) PutRecommendRequest(projectID uint32, findingID uint64) *finding.PutRecommendRequest {
+	return &finding.PutRecommendRequest{
+		ProjectId:      projectID,
+		FindingId:      findingID,
+		DataSource:     f.DataSource(),
+		Type:           f.RuleID,
+		Risk:           f.Description,
+		Recommendation: f.Recommendation,
+	}
+}
@@ -0,0 +1,52 @@
+package scanner
+
+import (
+	"testing"
+)
+
+func TestIsSuppressed(t *testing.T) {
+	lines := []string{
+		"# risken-ignore",
+		"FROM ubuntu",
+		"FROM node:latest # risken-ignore: DOCKERFILE_LATEST_TAG, DOCKERFILE_ROOT_USER",
+		"USER root",
+		"  # risken-ignore: WORKFLOW_UNPINNED_ACTION",
+		"- uses: owner/action@v1",
+		"# risken-ignore: DOCKERFILE_ROOT_USER because base image runs as root",
+		"USER root",
+		"# risken-ignore: DOCKERFILE_LATEST_TAG, DOCKERFILE_ROOT_USER because of the base image, DOCKERFILE_CURL_PIPE_SHELL",
+		"RUN curl -sSL https://example.com/install.sh | sh",
+		"# risken-ignore: DOCKERFILE_ROOT_USER -- see DOCKERFILE_LATEST_TAG, too",
+		"FROM node:latest",
+		"# risken-ignore -- vendored file",
+		"FROM node:latest",
+		"# risken-ignore: see the ticket",
+		"FROM node:latest",
+	}
+	testCases := []struct {
+		name   string
+		line   int
+		ruleID string
+		want   bool
+	}{
+		{name: "All rules on the previous line", line: 2, ruleID: "DOCKERFILE_LATEST_TAG", want: true},
+		{name: "Listed rule on the same line", line: 3, ruleID: "DOCKERFILE_LATEST_TAG", want: true},
+		{name: "Listed rule on the previous line", line: 4, ruleID: "DOCKERFILE_ROOT_USER", want: true},
+		{name: "Other rule", line: 6, ruleID: "WORKFLOW_SECRETS_TO_UNTRUSTED_ACTION", want: false},
+		{name: "Listed rule with indent", line: 6, ruleID: "WORKFLOW_UNPINNED_ACTION", want: true},
+		{name: "Listed rule followed by the free text", line: 8, ruleID: "DOCKERFILE_ROOT_USER", want: true},
+		{name: "Listed rules before the free text", line: 10, ruleID: "DOCKERFILE_ROOT_USER", want: true},
+		{name: "Rule after the free text", line: 10, ruleID: "DOCKERFILE_CURL_PIPE_SHELL", want: false},
+		{name: "Rule after the separator", line: 12, ruleID: "DOCKERFILE_LATEST_TAG", want: false},
+		{name: "All rules with the reason", line: 14, ruleID: "DOCKERFILE_LATEST_TAG", want: true},
+		{name: "Free text without rules", line: 16, ruleID: "DOCKERFILE_LATEST_TAG", want: false},
+		{name: "Out of range", line: 20, ruleID: "WORKFLOW_UNPINNED_ACTION", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isSuppressed(lines, tc.line, tc.ruleID); got != tc.want {
+				t.Errorf("isSuppressed() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
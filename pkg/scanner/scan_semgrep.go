@@ -5,58 +5,236 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ca-risken/code/pkg/codescan"
-	"github.com/google/go-github/v44/github"
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+const (
+	SEMGREP_CONFIG       = "p/default"
+	SEMGREP_REGISTRY_URL = "https://semgrep.dev/c/"
+	SEMGREP_BATCH_SIZE   = 100 // files per semgrep run
 )
 
 type SemgrepScanner struct {
-	logger *slog.Logger
+	logger      *slog.Logger
+	cache       *ResultCache
+	registryURL string
+	client      *http.Client
 }
 
-func NewSemgrepScanner(logger *slog.Logger) Scanner {
+func NewSemgrepScanner(logger *slog.Logger, cache *ResultCache) Scanner {
 	return &SemgrepScanner{
-		logger: logger,
+		logger:      logger,
+		cache:       cache,
+		registryURL: SEMGREP_REGISTRY_URL,
+		client:      &http.Client{Timeout: 30 * time.Second},
 	}
 }
 
-func (s *SemgrepScanner) Scan(ctx context.Context, repo *github.Repository, pr *github.PullRequest, sourceCodePath string, changeFiles []*github.CommitFile) ([]*ScanResult, error) {
-	var semgrepFindings []*codescan.SemgrepFinding
+func (s *SemgrepScanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error) {
+	cache := s.cache
+	var version string
+	if cache != nil {
+		out, err := exec.CommandContext(ctx, "semgrep", "--version").Output()
+		if err != nil {
+			s.logger.WarnContext(ctx, "Failed to get semgrep version, disable the cache", slog.String("err", err.Error()))
+			cache = nil
+		}
+		version = strings.TrimSpace(string(out))
+	}
+	// The registry ruleset is updated remotely, so the cache key has the hash of the downloaded rules, and semgrep runs the same rules.
+	config, ruleset := SEMGREP_CONFIG, ""
+	if cache != nil {
+		path, hash, err := s.downloadRuleset(ctx)
+		if err != nil {
+			s.logger.WarnContext(ctx, "Failed to download semgrep ruleset, disable the cache", slog.String("err", err.Error()))
+			cache = nil
+		} else {
+			defer func() { _ = os.Remove(path) }()
+			config, ruleset = path, hash
+		}
+	}
+
+	// Run semgrep on the batches of the files not cached, because loading the ruleset takes most of the time of a run
+	// (e.g. the full scan of all tracked files).
+	results := map[string]string{} // file name -> semgrep output
+	keys := map[string]string{}
+	var targets []string
 	for _, file := range changeFiles {
-		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, *file.Filename)
-		cmd := exec.CommandContext(ctx,
-			"semgrep",
-			"scan",
-			"--metrics=off",
-			"--timeout=60",
-			"--config=p/default",
-			"--json",
-			targetPath,
-		)
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-		s.logger.InfoContext(ctx, "Start semgrep scan", slog.String("file", targetPath))
-
-		err := cmd.Run()
+		if cache != nil {
+			targetPath := fmt.Sprintf("%s/%s", sourceCodePath, file.Filename)
+			content, err := os.ReadFile(targetPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", targetPath, err)
+			}
+			keys[file.Filename] = cacheKey("semgrep", version, ruleset, file.Filename, content)
+			var result string
+			if cache.Get(ctx, keys[file.Filename], &result) {
+				results[file.Filename] = result
+				continue
+			}
+		}
+		targets = append(targets, file.Filename)
+	}
+	for start := 0; start < len(targets); start += SEMGREP_BATCH_SIZE {
+		batch := targets[start:min(start+SEMGREP_BATCH_SIZE, len(targets))]
+		stdout, err := s.runSemgrep(ctx, config, sourceCodePath, batch)
+		if err != nil {
+			return nil, err
+		}
+		batchResults, err := splitSemgrepResult(sourceCodePath, stdout, batch)
 		if err != nil {
-			return nil, fmt.Errorf("failed to execute semgrep: targetPath=%s, err=%w, stderr=%+v", targetPath, err, stderr.String())
+			return nil, fmt.Errorf("failed to parse semgrep: files=%d, err=%w", len(batch), err)
 		}
-		findings, err := parseSemgrepResult(sourceCodePath, stdout.String(), repo, pr, changeFiles)
+		for _, name := range batch {
+			results[name] = batchResults[name]
+			cache.Put(ctx, keys[name], batchResults[name])
+		}
+	}
+
+	var semgrepFindings []*codescan.SemgrepFinding
+	for _, file := range changeFiles {
+		findings, err := parseSemgrepResult(sourceCodePath, results[file.Filename], repo, headSHA, changeFiles)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse semgrep: targetPath=%s, err=%w", targetPath, err)
+			return nil, fmt.Errorf("failed to parse semgrep: file=%s, err=%w", file.Filename, err)
 		}
 		semgrepFindings = append(semgrepFindings, findings...)
 	}
-	return generateScanResultFromSemgrepResults(repo, *pr.Head.SHA, semgrepFindings), nil
+	return generateScanResultFromSemgrepResults(repo, headSHA, semgrepFindings), nil
+}
+
+// downloadRuleset saves the registry ruleset to the temporary file, and returns the path and the hash of it.
+func (s *SemgrepScanner) downloadRuleset(ctx context.Context) (string, string, error) {
+	url := s.registryURL + SEMGREP_CONFIG
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("failed to get %s: status=%d", url, resp.StatusCode)
+	}
+	rules, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	f, err := os.CreateTemp("", "semgrep-*.yaml")
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := f.Write(rules); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", "", err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", "", err
+	}
+	return f.Name(), hashString(string(rules)), nil
+}
+
+func (s *SemgrepScanner) runSemgrep(ctx context.Context, config, sourceCodePath string, files []string) (string, error) {
+	args := []string{
+		"scan",
+		"--metrics=off",
+		"--timeout=60",
+		"--config=" + config,
+		"--json",
+	}
+	for _, file := range files {
+		args = append(args, fmt.Sprintf("%s/%s", sourceCodePath, file))
+	}
+	cmd := exec.CommandContext(ctx, "semgrep", args...)
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	s.logger.InfoContext(ctx, "Start semgrep scan", slog.String("file", files[0]), slog.Int("files", len(files)))
+
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("failed to execute semgrep: files=%d, err=%w, stderr=%+v", len(files), err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// semgrepOutput is the part of the semgrep JSON output used by codescan.ParseSemgrepResult.
+type semgrepOutput struct {
+	Results []json.RawMessage `json:"results"`
+}
+
+// splitSemgrepResult splits the output of the batch by the file, to cache the results per file.
+// The paths of the results are normalized to the file names relative to the source code path (e.g. not ./main.go),
+// and the workspace paths in the results are relativized to share the cache across the workspaces.
+// A result of the file not in the batch is an error, not to cache the file as clean.
+func splitSemgrepResult(sourceCodePath, stdout string, files []string) (map[string]string, error) {
+	var out semgrepOutput
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		return nil, err
+	}
+	byFile := map[string]*semgrepOutput{}
+	names := map[string]string{} // normalized path -> file name
+	for _, file := range files {
+		byFile[file] = &semgrepOutput{Results: []json.RawMessage{}}
+		names[filepath.Clean(file)] = file
+	}
+	for _, raw := range out.Results {
+		var result map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, err
+		}
+		var path string
+		if err := json.Unmarshal(result["path"], &path); err != nil {
+			return nil, fmt.Errorf("invalid path of the result: %w", err)
+		}
+		file, ok := names[semgrepResultPath(sourceCodePath, path)]
+		if !ok {
+			return nil, fmt.Errorf("result of the file not in the batch: path=%s", path)
+		}
+		result["path"], _ = json.Marshal(file)
+		normalized, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		byFile[file].Results = append(byFile[file].Results, normalized)
+	}
+	split := map[string]string{}
+	for file, o := range byFile {
+		b, err := json.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		split[file] = relativizePaths(sourceCodePath, string(b))
+	}
+	return split, nil
+}
+
+// semgrepResultPath returns the path of the result relative to the source code path, as the target path is passed to semgrep.
+func semgrepResultPath(sourceCodePath, path string) string {
+	path = filepath.Clean(path)
+	if rel, err := filepath.Rel(filepath.Clean(sourceCodePath), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
 }
 
-func parseSemgrepResult(sourceCodePath, scanResult string, repo *github.Repository, pr *github.PullRequest, changeFiles []*github.CommitFile) ([]*codescan.SemgrepFinding, error) {
-	results, err := codescan.ParseSemgrepResult(sourceCodePath, scanResult, *repo.FullName, *pr.Head.SHA, *repo.HTMLURL)
+func parseSemgrepResult(sourceCodePath, scanResult string, repo *scm.Repository, headSHA string, changeFiles []*scm.ChangeFile) ([]*codescan.SemgrepFinding, error) {
+	results, err := codescan.ParseSemgrepResult(sourceCodePath, scanResult, repo.FullName, headSHA, repo.URL)
 	if err != nil {
 		return nil, err
 	}
@@ -73,6 +251,7 @@ func parseSemgrepResult(sourceCodePath, scanResult string, repo *github.Reposito
 			continue
 		}
 		r.Path = fileName
+		r.GitHubURL = repo.LinesURL(headSHA, fileName, r.Start.Line, r.End.Line)
 		findings = append(findings, r)
 	}
 	return findings, nil
@@ -119,17 +298,18 @@ func isSupportedResult(tech []string) bool {
 	return true
 }
 
-func generateScanResultFromSemgrepResults(repo *github.Repository, commit string, results []*codescan.SemgrepFinding) []*ScanResult {
+func generateScanResultFromSemgrepResults(repo *scm.Repository, commit string, results []*codescan.SemgrepFinding) []*ScanResult {
 	var scanResults []*ScanResult
 	for _, r := range results {
+		f := &SemgrepFinding{SemgrepFinding: r}
 		scanResults = append(scanResults, &ScanResult{
 			ScanID:        r.CheckID,
 			File:          r.Path,
 			Line:          r.End.Line,
 			DiffHunk:      r.Extra.Lines,
-			ReviewComment: generateSemgrepReviewComment(r),
+			ReviewComment: f.ReviewComment(),
 			GitHubURL:     r.GitHubURL,
-			ScanResult:    r,
+			Finding:       f,
 		})
 	}
 	return scanResults
@@ -1 +1,108 @@
 package scanner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitSemgrepResult(t *testing.T) {
+	files := []string{"main.go", "lib/util.go", "clean.go"}
+	testCases := []struct {
+		name           string
+		sourceCodePath string
+		stdout         string
+		want           map[string]string
+		wantErr        bool
+	}{
+		{
+			name:           "Absolute workspace",
+			sourceCodePath: "/github/workspace",
+			stdout:         `{"results":[{"check_id":"a","path":"/github/workspace/main.go"},{"check_id":"b","path":"/github/workspace/lib/util.go","extra":{"message":"see /github/workspace/lib/util.go"}},{"check_id":"c","path":"/github/workspace/main.go"}],"errors":[]}`,
+			want: map[string]string{
+				"main.go":     `{"results":[{"check_id":"a","path":"main.go"},{"check_id":"c","path":"main.go"}]}`,
+				"lib/util.go": `{"results":[{"check_id":"b","extra":{"message":"see lib/util.go"},"path":"lib/util.go"}]}`,
+				"clean.go":    `{"results":[]}`,
+			},
+		},
+		{
+			name:           "Current directory",
+			sourceCodePath: ".",
+			stdout:         `{"results":[{"check_id":"a","path":"./main.go","extra":{"message":"../main.go"}},{"check_id":"b","path":"lib/util.go"}],"errors":[]}`,
+			want: map[string]string{
+				"main.go":     `{"results":[{"check_id":"a","extra":{"message":"../main.go"},"path":"main.go"}]}`,
+				"lib/util.go": `{"results":[{"check_id":"b","path":"lib/util.go"}]}`,
+				"clean.go":    `{"results":[]}`,
+			},
+		},
+		{
+			name:           "Trailing slash",
+			sourceCodePath: "workspace/",
+			stdout:         `{"results":[{"check_id":"a","path":"workspace//main.go"}],"errors":[]}`,
+			want: map[string]string{
+				"main.go":     `{"results":[{"check_id":"a","path":"main.go"}]}`,
+				"lib/util.go": `{"results":[]}`,
+				"clean.go":    `{"results":[]}`,
+			},
+		},
+		{
+			name:           "File not in the batch",
+			sourceCodePath: "/github/workspace",
+			stdout:         `{"results":[{"check_id":"a","path":"/other/main.go"}],"errors":[]}`,
+			wantErr:        true,
+		},
+		{
+			name:    "Invalid output",
+			stdout:  "not json",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := splitSemgrepResult(tc.sourceCodePath, tc.stdout, files)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("splitSemgrepResult() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("splitSemgrepResult() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSemgrepDownloadRuleset(t *testing.T) {
+	rules := "rules:\n  - id: test\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/c/"+SEMGREP_CONFIG {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(rules))
+	}))
+	defer server.Close()
+
+	s := NewSemgrepScanner(slog.New(slog.NewTextHandler(io.Discard, nil)), nil).(*SemgrepScanner)
+	s.registryURL = server.URL + "/c/"
+	path, hash, err := s.downloadRuleset(context.Background())
+	if err != nil {
+		t.Fatalf("downloadRuleset() error = %v", err)
+	}
+	defer func() { _ = os.Remove(path) }()
+	if content, err := os.ReadFile(path); err != nil || string(content) != rules {
+		t.Errorf("ruleset = %q, %v, want %q", content, err, rules)
+	}
+	if hash != hashString(rules) {
+		t.Errorf("hash = %s, want %s", hash, hashString(rules))
+	}
+
+	s.registryURL = server.URL + "/missing/"
+	if _, _, err := s.downloadRuleset(context.Background()); err == nil {
+		t.Errorf("downloadRuleset() of the missing ruleset error = nil, want error")
+	}
+}
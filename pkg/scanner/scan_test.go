@@ -3,13 +3,14 @@ package scanner
 import (
 	"testing"
 
-	"github.com/google/go-github/v44/github"
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestIsChangeLine(t *testing.T) {
 	testCases := []struct {
 		name     string
-		files    []*github.CommitFile
+		files    []*scm.ChangeFile
 		fileName string
 		line     string
 		patch    string
@@ -17,14 +18,14 @@ func TestIsChangeLine(t *testing.T) {
 	}{
 		{
 			name: "OK",
-			files: []*github.CommitFile{
+			files: []*scm.ChangeFile{
 				{
-					Filename: github.String("file1.go"),
-					Patch: github.String(`diff --git file1.go file1.go
+					Filename: "file1.go",
+					Patch: `diff --git file1.go file1.go
 +line 1
 +target line
 -line 3
-line 4`),
+line 4`,
 				},
 			},
 			fileName: "file1.go",
@@ -33,14 +34,14 @@ line 4`),
 		},
 		{
 			name: "File not match",
-			files: []*github.CommitFile{
+			files: []*scm.ChangeFile{
 				{
-					Filename: github.String("file1.go"),
-					Patch: github.String(`diff --git file1.go file1.go
+					Filename: "file1.go",
+					Patch: `diff --git file1.go file1.go
 +line 1
 +target line
 -line 3
-line 4`),
+line 4`,
 				},
 			},
 			fileName: "unknown.go",
@@ -49,14 +50,14 @@ line 4`),
 		},
 		{
 			name: "File not match",
-			files: []*github.CommitFile{
+			files: []*scm.ChangeFile{
 				{
-					Filename: github.String("file1.go"),
-					Patch: github.String(`diff --git file1.go file1.go
+					Filename: "file1.go",
+					Patch: `diff --git file1.go file1.go
 +line 1
 +target line
 -line 3
-line 4`),
+line 4`,
 				},
 			},
 			fileName: "file1.go",
@@ -175,3 +176,47 @@ func TestGenerateRiskenURL(t *testing.T) {
 		})
 	}
 }
+
+func TestAddedLineNumbers(t *testing.T) {
+	testCases := []struct {
+		name  string
+		patch string
+		want  map[int]bool
+	}{
+		{
+			name: "OK",
+			patch: `@@ -1,3 +1,4 @@
+ line 1
+-line 2
++line 2 updated
++line 3
+ line 4
+@@ -10,2 +11,3 @@ func main() {
+ line 11
++line 12
+ line 13`,
+			want: map[int]bool{2: true, 3: true, 12: true},
+		},
+		{
+			name: "New file",
+			patch: `@@ -0,0 +1,2 @@
++line 1
++line 2
+\ No newline at end of file`,
+			want: map[int]bool{1: true, 2: true},
+		},
+		{
+			name:  "Empty patch",
+			patch: "",
+			want:  map[int]bool{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := addedLineNumbers(&scm.ChangeFile{Patch: tc.patch})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("addedLineNumbers() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
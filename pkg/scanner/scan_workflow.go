@@ -0,0 +1,332 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowScanner checks the GitHub Actions workflow files for supply-chain risks.
+type WorkflowScanner struct {
+	logger *slog.Logger
+}
+
+func NewWorkflowScanner(logger *slog.Logger) Scanner {
+	return &WorkflowScanner{
+		logger: logger,
+	}
+}
+
+var (
+	workflowRulePRTargetCheckout = &rule{
+		ID:             "WORKFLOW_PR_TARGET_CHECKOUT",
+		Severity:       SEVERITY_CRITICAL,
+		Description:    "pull_request_target トリガーでPRのHEADをチェックアウトしています。フォークからの信頼できないコードが書き込み権限やシークレットを持った状態で実行される可能性があります。",
+		Recommendation: "pull_request トリガーを使用するか、PRのコードをチェックアウトするジョブから権限とシークレットを取り除いてください。",
+	}
+	workflowRuleScriptInjection = &rule{
+		ID:             "WORKFLOW_SCRIPT_INJECTION",
+		Severity:       SEVERITY_HIGH,
+		Description:    "run: ステップで外部から入力可能な値 (${{ github.event.* }} など) を直接展開しています。スクリプトインジェクションの恐れがあります。",
+		Recommendation: "値を env: で環境変数に渡し、スクリプト内では \"$ENV_NAME\" のように参照してください。",
+	}
+	workflowRuleUnpinnedAction = &rule{
+		ID:             "WORKFLOW_UNPINNED_ACTION",
+		Severity:       SEVERITY_MEDIUM,
+		Description:    "サードパーティのアクションがコミットSHAで固定されていません。タグやブランチは書き換えられる可能性があります。",
+		Recommendation: "uses: owner/repo@<40桁のコミットSHA> のようにフルSHAで固定してください（# v1.2.3 のようにコメントでバージョンを残すと管理しやすくなります）。",
+	}
+	workflowRuleWriteAllPermissions = &rule{
+		ID:             "WORKFLOW_WRITE_ALL_PERMISSIONS",
+		Severity:       SEVERITY_HIGH,
+		Description:    "permissions: write-all が指定されています。GITHUB_TOKEN に全てのスコープの書き込み権限が付与されます。",
+		Recommendation: "必要なスコープのみを明示的に指定してください (例: contents: read)。",
+	}
+	workflowRuleWorkflowWritePermission = &rule{
+		ID:             "WORKFLOW_TOP_LEVEL_WRITE_PERMISSION",
+		Severity:       SEVERITY_LOW,
+		Description:    "ワークフローのトップレベルで書き込み権限が付与されています。全てのジョブに同じ権限が付与されます。",
+		Recommendation: "トップレベルは読み取り権限のみにし、書き込み権限は必要なジョブの permissions: で付与してください。",
+	}
+	workflowRuleJobWritePermission = &rule{
+		ID:             "WORKFLOW_JOB_WRITE_PERMISSION",
+		Severity:       SEVERITY_LOW,
+		Description:    "ジョブに書き込み権限が付与されています。ジョブ内の全てのステップ (サードパーティのアクションを含む) が書き込み権限を持った GITHUB_TOKEN を利用できます。",
+		Recommendation: "書き込み権限が本当に必要なスコープか確認し、書き込みを行うステップを最小限のジョブに分離してください。",
+	}
+	workflowRulePRTargetSecrets = &rule{
+		ID:             "WORKFLOW_PR_TARGET_SECRETS",
+		Severity:       SEVERITY_CRITICAL,
+		Description:    "pull_request_target トリガーでPRのHEADをチェックアウトした後の run: ステップでシークレットを使用しています。フォークからの信頼できないコードがシークレットを読み取れる可能性があります。",
+		Recommendation: "PRのコードをチェックアウトするジョブではシークレットを使用せず、シークレットが必要な処理は別のジョブ (workflow_run など) に分離してください。",
+	}
+	workflowRuleSecretsToUntrustedAction = &rule{
+		ID:             "WORKFLOW_SECRETS_TO_UNTRUSTED_ACTION",
+		Severity:       SEVERITY_HIGH,
+		Description:    "コミットSHAで固定されていないサードパーティのアクションにシークレットを渡しています。アクションが改ざんされた場合にシークレットが漏洩します。",
+		Recommendation: "アクションをフルSHAで固定するか、シークレットを渡さない実装に変更してください。",
+	}
+)
+
+var (
+	workflowExpressionRegexp = regexp.MustCompile(`\$\{\{\s*(.*?)\s*\}\}`)
+	// ref: https://securitylab.github.com/research/github-actions-untrusted-input/
+	workflowUntrustedInputRegexp = regexp.MustCompile(`github\.head_ref|github\.event\.[\w.*\[\]'"-]*?\b(title|body|message|name|email|ref|label|head_branch|page_name|default_branch)\b`)
+	workflowSHARegexp            = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	workflowSecretsRegexp        = regexp.MustCompile(`\bsecrets\.`)
+	workflowPRHeadRefRegexp      = regexp.MustCompile(`github\.event\.pull_request\.head\.(sha|ref)|github\.head_ref|refs/pull/`)
+	workflowFirstPartyOwners     = map[string]bool{"actions": true, "github": true}
+)
+
+func (s *WorkflowScanner) Scan(ctx context.Context, repo *scm.Repository, headSHA string, sourceCodePath string, changeFiles []*scm.ChangeFile) ([]*ScanResult, error) {
+	var findings []*RuleFinding
+	for _, file := range changeFiles {
+		if !isWorkflowFile(file.Filename) {
+			continue
+		}
+		targetPath := fmt.Sprintf("%s/%s", sourceCodePath, file.Filename)
+		s.logger.InfoContext(ctx, "Start workflow scan", slog.String("file", targetPath))
+		content, err := os.ReadFile(targetPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", targetPath, err)
+		}
+		fileFindings, err := checkWorkflow(repo, headSHA, file.Filename, content)
+		if err != nil {
+			// Invalid YAML is reported by GitHub itself, so just skip it.
+			s.logger.WarnContext(ctx, "Skip invalid workflow", slog.String("file", targetPath), slog.String("err", err.Error()))
+			continue
+		}
+		findings = append(findings, filterRuleFindings(file, strings.Split(string(content), "\n"), fileFindings)...)
+	}
+	return generateScanResultFromRuleFindings(findings), nil
+}
+
+func isWorkflowFile(fileName string) bool {
+	ext := path.Ext(fileName)
+	return path.Dir(fileName) == ".github/workflows" && (ext == ".yml" || ext == ".yaml")
+}
+
+type workflowChecker struct {
+	repo     *scm.Repository
+	commit   string
+	path     string
+	lines    []string
+	findings []*RuleFinding
+}
+
+func (c *workflowChecker) report(r *rule, line int) {
+	code := ""
+	if line > 0 && line <= len(c.lines) {
+		code = strings.TrimSpace(c.lines[line-1])
+	}
+	c.findings = append(c.findings, newRuleFinding(RULE_SCANNER_WORKFLOW, r, c.repo, c.commit, c.path, line, code))
+}
+
+func checkWorkflow(repo *scm.Repository, commit, filePath string, content []byte) ([]*RuleFinding, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	c := &workflowChecker{
+		repo:   repo,
+		commit: commit,
+		path:   filePath,
+		lines:  strings.Split(string(content), "\n"),
+	}
+
+	prTarget := hasWorkflowTrigger(yamlMappingValue(root, "on"), "pull_request_target")
+	if permissions := yamlMappingValue(root, "permissions"); permissions != nil {
+		c.checkPermissions(permissions, true)
+	}
+	jobs := yamlMappingValue(root, "jobs")
+	if jobs == nil || jobs.Kind != yaml.MappingNode {
+		return c.findings, nil
+	}
+	for i := 1; i < len(jobs.Content); i += 2 {
+		job := jobs.Content[i]
+		if job.Kind != yaml.MappingNode {
+			continue
+		}
+		if permissions := yamlMappingValue(job, "permissions"); permissions != nil {
+			c.checkPermissions(permissions, false)
+		}
+		if uses := yamlMappingValue(job, "uses"); uses != nil {
+			// reusable workflow
+			c.checkUses(uses, yamlMappingValue(job, "with"), yamlMappingValue(job, "secrets"))
+		}
+		steps := yamlMappingValue(job, "steps")
+		if steps == nil || steps.Kind != yaml.SequenceNode {
+			continue
+		}
+		prHead := false // PR head has been checked out by the previous steps
+		for _, step := range steps.Content {
+			if step.Kind != yaml.MappingNode {
+				continue
+			}
+			if c.checkStep(step, prTarget, prHead) {
+				prHead = true
+			}
+		}
+	}
+	return c.findings, nil
+}
+
+func (c *workflowChecker) checkPermissions(permissions *yaml.Node, topLevel bool) {
+	switch permissions.Kind {
+	case yaml.ScalarNode:
+		if permissions.Value == "write-all" {
+			c.report(workflowRuleWriteAllPermissions, permissions.Line)
+		}
+	case yaml.MappingNode:
+		r := workflowRuleJobWritePermission
+		if topLevel {
+			r = workflowRuleWorkflowWritePermission
+		}
+		for i := 1; i < len(permissions.Content); i += 2 {
+			scope, value := permissions.Content[i-1], permissions.Content[i]
+			if value.Value == "write" && scope.Value != "id-token" {
+				c.report(r, value.Line)
+			}
+		}
+	}
+}
+
+// checkStep returns true if the step checks out the PR head in the pull_request_target workflow.
+func (c *workflowChecker) checkStep(step *yaml.Node, prTarget, prHead bool) bool {
+	if run := yamlMappingValue(step, "run"); run != nil && run.Kind == yaml.ScalarNode {
+		for _, line := range expressionLines(run, workflowUntrustedInputRegexp) {
+			c.report(workflowRuleScriptInjection, line)
+		}
+		if prHead {
+			c.checkSecrets(run, yamlMappingValue(step, "env"))
+		}
+	}
+	uses := yamlMappingValue(step, "uses")
+	if uses == nil || uses.Kind != yaml.ScalarNode {
+		return false
+	}
+	with := yamlMappingValue(step, "with")
+	checkout := false
+	if prTarget && strings.HasPrefix(uses.Value, "actions/checkout@") {
+		if ref := yamlMappingValue(with, "ref"); ref != nil && workflowPRHeadRefRegexp.MatchString(ref.Value) {
+			c.report(workflowRulePRTargetCheckout, ref.Line)
+			checkout = true
+		}
+	}
+	c.checkUses(uses, with, yamlMappingValue(step, "env"))
+	return checkout
+}
+
+// checkSecrets reports the secrets used by the run: step (in the script or the step env) after the PR head checkout.
+func (c *workflowChecker) checkSecrets(run, env *yaml.Node) {
+	for _, line := range expressionLines(run, workflowSecretsRegexp) {
+		c.report(workflowRulePRTargetSecrets, line)
+	}
+	if env == nil || env.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(env.Content); i += 2 {
+		if workflowSecretsRegexp.MatchString(env.Content[i].Value) {
+			c.report(workflowRulePRTargetSecrets, env.Content[i].Line)
+		}
+	}
+}
+
+// checkUses checks the pinning of the third-party action and the secrets passed to it.
+func (c *workflowChecker) checkUses(uses *yaml.Node, inputs ...*yaml.Node) {
+	if uses.Kind != yaml.ScalarNode || !isThirdPartyAction(uses.Value) {
+		return
+	}
+	ref := uses.Value[strings.LastIndex(uses.Value, "@")+1:]
+	if workflowSHARegexp.MatchString(ref) {
+		return
+	}
+	c.report(workflowRuleUnpinnedAction, uses.Line)
+	for _, input := range inputs {
+		if input == nil {
+			continue
+		}
+		if input.Kind == yaml.ScalarNode && input.Value == "inherit" {
+			c.report(workflowRuleSecretsToUntrustedAction, input.Line) // secrets: inherit
+			continue
+		}
+		if input.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 1; i < len(input.Content); i += 2 {
+			if strings.Contains(input.Content[i].Value, "secrets.") {
+				c.report(workflowRuleSecretsToUntrustedAction, input.Content[i].Line)
+			}
+		}
+	}
+}
+
+func isThirdPartyAction(uses string) bool {
+	if strings.HasPrefix(uses, "./") || strings.HasPrefix(uses, "docker://") || !strings.Contains(uses, "@") {
+		return false
+	}
+	owner := strings.SplitN(uses, "/", 2)[0]
+	return !workflowFirstPartyOwners[owner]
+}
+
+// expressionLines returns the line numbers of the expressions matched with the pattern in the scalar.
+func expressionLines(node *yaml.Node, pattern *regexp.Regexp) []int {
+	var lines []int
+	for i, l := range strings.Split(node.Value, "\n") {
+		for _, m := range workflowExpressionRegexp.FindAllStringSubmatch(l, -1) {
+			if !pattern.MatchString(m[1]) {
+				continue
+			}
+			line := node.Line
+			if node.Style == yaml.LiteralStyle || node.Style == yaml.FoldedStyle {
+				line = node.Line + 1 + i // block scalar starts from the next line of the indicator
+			}
+			lines = append(lines, line)
+			break
+		}
+	}
+	return lines
+}
+
+func hasWorkflowTrigger(on *yaml.Node, event string) bool {
+	if on == nil {
+		return false
+	}
+	switch on.Kind {
+	case yaml.ScalarNode:
+		return on.Value == event
+	case yaml.SequenceNode:
+		for _, n := range on.Content {
+			if n.Value == event {
+				return true
+			}
+		}
+	case yaml.MappingNode:
+		return yamlMappingValue(on, event) != nil
+	}
+	return false
+}
+
+// yamlMappingValue returns the value node of the key in the mapping node.
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
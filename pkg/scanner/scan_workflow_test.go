@@ -0,0 +1,244 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCheckWorkflow(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	type want struct {
+		ruleID string
+		line   int
+	}
+	testCases := []struct {
+		name    string
+		content string
+		want    []want
+	}{
+		{
+			name: "pull_request_target with checkout of PR head",
+			content: `on:
+  pull_request_target:
+    types: [opened]
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+        with:
+          ref: ${{ github.event.pull_request.head.sha }}
+`,
+			want: []want{{ruleID: "WORKFLOW_PR_TARGET_CHECKOUT", line: 10}},
+		},
+		{
+			name: "checkout of PR head without pull_request_target",
+			content: `on: pull_request
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+        with:
+          ref: ${{ github.event.pull_request.head.sha }}
+`,
+			want: nil,
+		},
+		{
+			name: "pull_request_target with secrets after checkout of PR head",
+			content: `on: pull_request_target
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo "${{ secrets.TOKEN }}"
+      - uses: actions/checkout@v4
+        with:
+          ref: ${{ github.event.pull_request.head.sha }}
+      - run: make test
+      - run: |
+          make build
+          echo "${{ secrets.TOKEN }}"
+      - env:
+          TOKEN: ${{ secrets.TOKEN }}
+          NAME: test
+        run: ./deploy.sh
+  other:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo "${{ secrets.TOKEN }}"
+`,
+			want: []want{
+				{ruleID: "WORKFLOW_PR_TARGET_CHECKOUT", line: 9},
+				{ruleID: "WORKFLOW_PR_TARGET_SECRETS", line: 13},
+				{ruleID: "WORKFLOW_PR_TARGET_SECRETS", line: 15},
+			},
+		},
+		{
+			name: "Script injection",
+			content: `on: [issues]
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - run: echo "${{ github.event.issue.title }}"
+      - run: |
+          echo "start"
+          echo "${{ github.event.issue.number }}"
+          echo "${{ github.head_ref }}"
+      - env:
+          TITLE: ${{ github.event.issue.title }}
+        run: echo "$TITLE"
+`,
+			want: []want{
+				{ruleID: "WORKFLOW_SCRIPT_INJECTION", line: 6},
+				{ruleID: "WORKFLOW_SCRIPT_INJECTION", line: 10},
+			},
+		},
+		{
+			name: "Unpinned action and secrets",
+			content: `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/setup-go@v5
+      - uses: ./local-action
+      - uses: owner/pinned@0123456789abcdef0123456789abcdef01234567
+        with:
+          token: ${{ secrets.TOKEN }}
+      - uses: owner/action@v1
+        with:
+          token: ${{ secrets.TOKEN }}
+          name: test
+  call:
+    uses: owner/repo/.github/workflows/reusable.yml@main
+    secrets: inherit
+`,
+			want: []want{
+				{ruleID: "WORKFLOW_UNPINNED_ACTION", line: 11},
+				{ruleID: "WORKFLOW_SECRETS_TO_UNTRUSTED_ACTION", line: 13},
+				{ruleID: "WORKFLOW_UNPINNED_ACTION", line: 16},
+				{ruleID: "WORKFLOW_SECRETS_TO_UNTRUSTED_ACTION", line: 17},
+			},
+		},
+		{
+			name: "Permissions",
+			content: `on: push
+permissions:
+  contents: write
+  id-token: write
+  pull-requests: read
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    permissions: write-all
+    steps:
+      - run: echo ok
+  release:
+    runs-on: ubuntu-latest
+    permissions:
+      contents: write
+      id-token: write
+    steps:
+      - run: echo ok
+`,
+			want: []want{
+				{ruleID: "WORKFLOW_TOP_LEVEL_WRITE_PERMISSION", line: 3},
+				{ruleID: "WORKFLOW_WRITE_ALL_PERMISSIONS", line: 9},
+				{ruleID: "WORKFLOW_JOB_WRITE_PERMISSION", line: 15},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings, err := checkWorkflow(repo, "headsha", ".github/workflows/ci.yaml", []byte(tc.content))
+			if err != nil {
+				t.Fatalf("checkWorkflow() error = %v", err)
+			}
+			var got []want
+			for _, f := range findings {
+				got = append(got, want{ruleID: f.RuleID, line: f.Line})
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("checkWorkflow() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWorkflowScan(t *testing.T) {
+	repo := &scm.Repository{
+		URL:      "https://github.com/owner/repo",
+		FullName: "owner/repo",
+	}
+	headSHA := "headsha"
+	sourceDir := t.TempDir()
+	writeTestFile(t, filepath.Join(sourceDir, ".github/workflows/ci.yml"), `on: push
+jobs:
+  build:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: owner/old@v1
+      - uses: owner/new@v1
+`)
+	writeTestFile(t, filepath.Join(sourceDir, "docs/ci.yml"), "uses: owner/new@v1\n")
+	writeTestFile(t, filepath.Join(sourceDir, ".github/workflows/invalid.yml"), "on: [push\n")
+	changeFiles := []*scm.ChangeFile{
+		{
+			Filename: ".github/workflows/ci.yml",
+			Patch:    "@@ -6,1 +6,2 @@\n       - uses: owner/old@v1\n+      - uses: owner/new@v1",
+		},
+		{
+			Filename: "docs/ci.yml",
+			Patch:    "@@ -0,0 +1 @@\n+uses: owner/new@v1",
+		},
+		{
+			Filename: ".github/workflows/invalid.yml",
+			Patch:    "@@ -0,0 +1 @@\n+on: [push",
+		},
+	}
+
+	s := NewWorkflowScanner(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	got, err := s.Scan(context.Background(), repo, headSHA, sourceDir, changeFiles)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Scan() results = %d, want 1", len(got))
+	}
+	want := []*ScanResult{
+		{
+			ScanID:        "WORKFLOW_UNPINNED_ACTION",
+			File:          ".github/workflows/ci.yml",
+			Line:          7,
+			DiffHunk:      "- uses: owner/new@v1",
+			ReviewComment: got[0].Finding.ReviewComment(),
+			GitHubURL:     "https://github.com/owner/repo/blob/headsha/.github/workflows/ci.yml#L7",
+			Finding: &RuleFinding{
+				ScannerName:    RULE_SCANNER_WORKFLOW,
+				RuleID:         "WORKFLOW_UNPINNED_ACTION",
+				Level:          SEVERITY_MEDIUM,
+				Description:    workflowRuleUnpinnedAction.Description,
+				Recommendation: workflowRuleUnpinnedAction.Recommendation,
+				Repository:     "owner/repo",
+				Path:           ".github/workflows/ci.yml",
+				Line:           7,
+				Code:           "- uses: owner/new@v1",
+				GitHubURL:      "https://github.com/owner/repo/blob/headsha/.github/workflows/ci.yml#L7",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Scan() mismatch (-want +got):\n%s", diff)
+	}
+}
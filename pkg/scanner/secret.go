@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/ca-risken/security-review/pkg/verify"
+)
+
+// Secret returns the detected secret of the SecretFinding (gitleaks and entropy), or empty for the other findings.
+func (s *ScanResult) Secret() string {
+	if f, ok := s.Finding.(SecretFinding); ok {
+		return f.Secret()
+	}
+	return ""
+}
+
+// Severity returns the effective severity of the finding, which is critical for the verified live secret.
+// Empty for the findings carried forward from the PR comments, which have no finding.
+func (s *ScanResult) Severity() string {
+	if s.Verification != nil && s.Verification.Status == verify.STATUS_LIVE {
+		return SEVERITY_CRITICAL
+	}
+	if s.Finding == nil {
+		return ""
+	}
+	return s.Finding.Severity()
+}
+
+const (
+	LIVE_SECRET_REVIEW_COMMENT_TEMPLATE = `
+🚨 **有効なシークレットです（%s で検証済み）** 🚨
+
+- 重要度: CRITICAL
+- 直ちにキーのローテーション（無効化）を行い、監査ログなどで不正利用がないか確認してください。
+`
+	INVALID_SECRET_REVIEW_COMMENT_TEMPLATE = `
+ℹ️ 検証の結果、このシークレットは無効でした（%s）。失効済みのシークレットであってもコミット履歴からの削除を推奨します。
+`
+)
+
+// ApplyVerification stores the verification result and updates the review comment by the status.
+func (s *ScanResult) ApplyVerification(v *verify.Result) {
+	s.Verification = v
+	switch v.Status {
+	case verify.STATUS_LIVE:
+		s.ReviewComment = fmt.Sprintf(LIVE_SECRET_REVIEW_COMMENT_TEMPLATE, v.Verifier) + s.ReviewComment
+	case verify.STATUS_INVALID:
+		s.ReviewComment += fmt.Sprintf(INVALID_SECRET_REVIEW_COMMENT_TEMPLATE, v.Verifier)
+	}
+}
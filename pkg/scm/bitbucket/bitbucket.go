@@ -0,0 +1,389 @@
+// Package bitbucket is the Bitbucket Data Center (Server) implementation of the SCM provider, to review the pull requests on the CI (e.g. Jenkins, Bamboo).
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+const (
+	API_PATH = "/rest/api/1.0"
+
+	perPage = 100
+)
+
+// Config is the configuration of the Bitbucket API client.
+type Config struct {
+	APIURL         string // e.g. https://bitbucket.example.com/rest/api/1.0
+	Token          string // HTTP access token with the repository write permission
+	ProjectKey     string
+	RepositorySlug string
+	HTTPClient     *http.Client
+}
+
+// Client reviews the pull requests of the repository with the Bitbucket Data Center REST API.
+// ref: https://developer.atlassian.com/server/bitbucket/rest/
+type Client struct {
+	apiURL         string
+	token          string
+	projectKey     string
+	repositorySlug string
+	httpClient     *http.Client
+}
+
+var _ scm.Provider = (*Client)(nil)
+
+func NewClient(cfg *Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		apiURL:         strings.TrimSuffix(cfg.APIURL, "/"),
+		token:          cfg.Token,
+		projectKey:     cfg.ProjectKey,
+		repositorySlug: cfg.RepositorySlug,
+		httpClient:     httpClient,
+	}
+}
+
+// PullRequestRef is the pull request parsed from the web URL.
+type PullRequestRef struct {
+	APIURL         string
+	ProjectKey     string
+	RepositorySlug string
+	ID             int
+}
+
+var pullRequestURLRegexp = regexp.MustCompile(`^(https?://.+?)/projects/([^/]+)/repos/([^/]+)/pull-requests/(\d+)`)
+
+// ParsePullRequestURL parses the web URL of the pull request, e.g. CHANGE_URL of the Jenkins multibranch pipelines.
+// e.g. https://bitbucket.example.com/projects/PRJ/repos/repo/pull-requests/1/overview
+func ParsePullRequestURL(u string) (*PullRequestRef, error) {
+	m := pullRequestURLRegexp.FindStringSubmatch(u)
+	if m == nil {
+		return nil, fmt.Errorf("invalid bitbucket pull request URL: %s", u)
+	}
+	id, err := strconv.Atoi(m[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid bitbucket pull request ID: %s", u)
+	}
+	return &PullRequestRef{APIURL: m[1] + API_PATH, ProjectKey: m[2], RepositorySlug: m[3], ID: id}, nil
+}
+
+type repository struct {
+	ID          int64    `json:"id"`
+	Slug        string   `json:"slug"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Public      bool     `json:"public"`
+	Archived    bool     `json:"archived"`
+	Project     *project `json:"project"`
+	Links       *links   `json:"links"`
+}
+
+type project struct {
+	Key string `json:"key"`
+}
+
+type links struct {
+	Self  []*link `json:"self"`
+	Clone []*link `json:"clone"`
+}
+
+type link struct {
+	Href string `json:"href"`
+	Name string `json:"name"` // http or ssh for the clone links
+}
+
+type branch struct {
+	DisplayID string `json:"displayId"`
+}
+
+type pullRequest struct {
+	ID      int          `json:"id"`
+	FromRef *ref         `json:"fromRef"`
+	ToRef   *ref         `json:"toRef"`
+	Author  *participant `json:"author"`
+	Links   *links       `json:"links"`
+}
+
+type ref struct {
+	DisplayID    string `json:"displayId"`
+	LatestCommit string `json:"latestCommit"`
+}
+
+type participant struct {
+	User *user `json:"user"`
+}
+
+type user struct {
+	Name string `json:"name"`
+}
+
+type activity struct {
+	Action        string   `json:"action"`        // COMMENTED, APPROVED, ...
+	CommentAction string   `json:"commentAction"` // ADDED, EDITED or DELETED
+	Comment       *comment `json:"comment"`
+	CommentAnchor *anchor  `json:"commentAnchor"`
+}
+
+type comment struct {
+	ID     int64   `json:"id,omitempty"`
+	Text   string  `json:"text"`
+	Anchor *anchor `json:"anchor,omitempty"`
+}
+
+type anchor struct {
+	Path     string `json:"path"`
+	Line     int    `json:"line,omitempty"`
+	LineType string `json:"lineType,omitempty"` // ADDED, REMOVED or CONTEXT
+	FileType string `json:"fileType,omitempty"` // FROM or TO
+	DiffType string `json:"diffType,omitempty"` // EFFECTIVE for the pull request diff
+	Orphaned bool   `json:"orphaned,omitempty"` // true if the line is no longer in the diff
+}
+
+type page[T any] struct {
+	Values        []*T `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// GetChangeRequest returns the pull request of the repository.
+func (c *Client) GetChangeRequest(ctx context.Context, id int) (*scm.ChangeRequest, error) {
+	var repo repository
+	if err := c.do(ctx, http.MethodGet, c.repoPath(""), nil, nil, &repo); err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	var defaultBranch branch
+	if err := c.do(ctx, http.MethodGet, c.repoPath("/default-branch"), nil, nil, &defaultBranch); err != nil {
+		return nil, fmt.Errorf("failed to get default branch: %w", err)
+	}
+	var pr pullRequest
+	if err := c.do(ctx, http.MethodGet, c.pullRequestPath(id, ""), nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	visibility := "private"
+	if repo.Public {
+		visibility = "public"
+	}
+	cr := &scm.ChangeRequest{
+		Number: pr.ID,
+		URL:    selfURL(pr.Links),
+		Repository: &scm.Repository{
+			Provider:      scm.PROVIDER_BITBUCKET,
+			ID:            repo.ID,
+			Name:          repo.Name,
+			FullName:      c.projectKey + "/" + repo.Slug,
+			Description:   repo.Description,
+			URL:           strings.TrimSuffix(selfURL(repo.Links), "/browse"),
+			CloneURL:      cloneURL(repo.Links),
+			DefaultBranch: defaultBranch.DisplayID,
+			Visibility:    visibility,
+			Private:       !repo.Public,
+			Archived:      repo.Archived,
+		},
+	}
+	if pr.Author != nil && pr.Author.User != nil {
+		cr.Author = pr.Author.User.Name
+	}
+	if pr.FromRef != nil {
+		cr.HeadSHA, cr.HeadBranch = pr.FromRef.LatestCommit, pr.FromRef.DisplayID
+	}
+	if pr.ToRef != nil {
+		cr.BaseSHA, cr.BaseBranch = pr.ToRef.LatestCommit, pr.ToRef.DisplayID
+	}
+	return cr, nil
+}
+
+// ListChangeFiles returns the files of the pull request (effective) diff, parsed from the raw diff.
+func (c *Client) ListChangeFiles(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.ChangeFile, error) {
+	var diff bytes.Buffer
+	if err := c.do(ctx, http.MethodGet, c.pullRequestPath(cr.Number, ".diff"), nil, nil, &diff); err != nil {
+		return nil, fmt.Errorf("failed to get pull request diff: %w", err)
+	}
+	return scm.ParseDiff(diff.String()), nil
+}
+
+// ListComments returns the general comments of the pull request, not on the files.
+func (c *Client) ListComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	activities, err := c.listCommentActivities(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*scm.Comment
+	for _, a := range activities {
+		if a.CommentAnchor != nil {
+			continue
+		}
+		comments = append(comments, &scm.Comment{Body: a.Comment.Text, URL: commentURL(cr, a.Comment)})
+	}
+	return comments, nil
+}
+
+// ListLineComments returns the comments on the new file lines which are still on the latest diff (not orphaned).
+func (c *Client) ListLineComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	activities, err := c.listCommentActivities(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*scm.Comment
+	for _, a := range activities {
+		an := a.CommentAnchor
+		if an == nil || an.Orphaned || an.Line == 0 || an.FileType != "TO" {
+			continue
+		}
+		comments = append(comments, &scm.Comment{Path: an.Path, Line: an.Line, Body: a.Comment.Text, URL: commentURL(cr, a.Comment)})
+	}
+	return comments, nil
+}
+
+// listCommentActivities returns the activities of the comments added to the pull request. The replies are not included.
+func (c *Client) listCommentActivities(ctx context.Context, cr *scm.ChangeRequest) ([]*activity, error) {
+	activities, err := getAll[activity](ctx, c, c.pullRequestPath(cr.Number, "/activities"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull request activities: %w", err)
+	}
+	var comments []*activity
+	for _, a := range activities {
+		if a.Action == "COMMENTED" && a.CommentAction == "ADDED" && a.Comment != nil {
+			comments = append(comments, a)
+		}
+	}
+	return comments, nil
+}
+
+func (c *Client) CreateComment(ctx context.Context, cr *scm.ChangeRequest, body string) error {
+	if err := c.do(ctx, http.MethodPost, c.pullRequestPath(cr.Number, "/comments"), nil, &comment{Text: body}, nil); err != nil {
+		return fmt.Errorf("failed to create pull request comment: %w", err)
+	}
+	return nil
+}
+
+// CreateLineComment comments on the added line of the pull request diff.
+// ref: https://developer.atlassian.com/server/bitbucket/rest/v816/api-group-pull-requests/#api-api-latest-projects-projectkey-repos-repositoryslug-pull-requests-pullrequestid-comments-post
+func (c *Client) CreateLineComment(ctx context.Context, cr *scm.ChangeRequest, lineComment *scm.Comment) error {
+	req := &comment{
+		Text: lineComment.Body,
+		Anchor: &anchor{
+			Path:     lineComment.Path,
+			Line:     lineComment.Line,
+			LineType: "ADDED",
+			FileType: "TO",
+			DiffType: "EFFECTIVE",
+		},
+	}
+	if err := c.do(ctx, http.MethodPost, c.pullRequestPath(cr.Number, "/comments"), nil, req, nil); err != nil {
+		return fmt.Errorf("failed to create pull request comment: path=%s, line=%d, err=%w", lineComment.Path, lineComment.Line, err)
+	}
+	return nil
+}
+
+func selfURL(l *links) string {
+	if l == nil || len(l.Self) == 0 {
+		return ""
+	}
+	return l.Self[0].Href
+}
+
+func cloneURL(l *links) string {
+	if l == nil {
+		return ""
+	}
+	for _, clone := range l.Clone {
+		if clone.Name == "http" {
+			return clone.Href
+		}
+	}
+	return ""
+}
+
+func commentURL(cr *scm.ChangeRequest, c *comment) string {
+	if cr.URL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s?commentId=%d", cr.URL, c.ID)
+}
+
+func (c *Client) repoPath(suffix string) string {
+	return "/projects/" + url.PathEscape(c.projectKey) + "/repos/" + url.PathEscape(c.repositorySlug) + suffix
+}
+
+func (c *Client) pullRequestPath(id int, suffix string) string {
+	return c.repoPath(fmt.Sprintf("/pull-requests/%d%s", id, suffix))
+}
+
+// getAll returns the values of all pages, following nextPageStart.
+// ref: https://developer.atlassian.com/server/bitbucket/rest/v816/intro/#paged-apis
+func getAll[T any](ctx context.Context, c *Client, path string) ([]*T, error) {
+	var all []*T
+	start := 0
+	for {
+		query := url.Values{"limit": {strconv.Itoa(perPage)}, "start": {strconv.Itoa(start)}}
+		var p page[T]
+		if err := c.do(ctx, http.MethodGet, path, query, nil, &p); err != nil {
+			return nil, err
+		}
+		all = append(all, p.Values...)
+		if p.IsLastPage {
+			return all, nil
+		}
+		start = p.NextPageStart
+	}
+}
+
+// do sends the request with the JSON body, and decodes the JSON response into out (if not nil). The raw response is written if out is *bytes.Buffer.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	u := c.apiURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: method=%s, path=%s, err=%w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("bitbucket api error: method=%s, path=%s, status=%d, body=%s", method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	switch o := out.(type) {
+	case nil:
+	case *bytes.Buffer:
+		o.Write(respBody)
+	default:
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response: method=%s, path=%s, err=%w", method, path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package scm
+
+import "strings"
+
+// ParseDiff splits the unified diff of the git format (e.g. the raw diff of Gitea and Bitbucket) into the changed files, except the removed files.
+// The patch of the file is the hunks without the file header, as the GitHub API returns.
+func ParseDiff(diff string) []*ChangeFile {
+	var (
+		files   []*ChangeFile
+		current *ChangeFile
+		hunks   []string
+	)
+	flush := func() {
+		if current == nil || current.Status == FILE_REMOVED {
+			return
+		}
+		current.Patch = strings.Join(hunks, "\n")
+		files = append(files, current)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			current = &ChangeFile{Filename: diffHeaderPath(line), Status: FILE_MODIFIED}
+			hunks = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if len(hunks) > 0 || strings.HasPrefix(line, "@@") {
+			// The lines in the hunks may start with "---" or "+++" (e.g. the removed line "-- comment")
+			hunks = append(hunks, line)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			current.Status = FILE_ADDED
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Status = FILE_REMOVED
+		case strings.HasPrefix(line, "rename to "):
+			current.Filename = strings.TrimPrefix(line, "rename to ")
+			current.Status = FILE_RENAMED
+		case strings.HasPrefix(line, "+++ "):
+			if path := trimDiffPrefix(strings.TrimPrefix(line, "+++ ")); path != "/dev/null" {
+				current.Filename = path
+			}
+		}
+	}
+	flush()
+	return files
+}
+
+// diffHeaderPath returns the new path in the header "diff --git a/path b/path". Used if the diff has no "+++" line (e.g. the binary files).
+func diffHeaderPath(line string) string {
+	for _, prefix := range []string{" b/", " dst://"} {
+		if i := strings.LastIndex(line, prefix); i >= 0 {
+			return line[i+len(prefix):]
+		}
+	}
+	return ""
+}
+
+// trimDiffPrefix trims the prefix of the new path, "b/" by git and "dst://" by Bitbucket, and the timestamp after the tab.
+func trimDiffPrefix(path string) string {
+	path, _, _ = strings.Cut(path, "\t")
+	for _, prefix := range []string{"b/", "dst://"} {
+		if strings.HasPrefix(path, prefix) {
+			return strings.TrimPrefix(path, prefix)
+		}
+	}
+	return path
+}
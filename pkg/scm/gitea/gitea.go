@@ -0,0 +1,283 @@
+// Package gitea is the Gitea (and Forgejo) implementation of the SCM provider, to review the pull requests on Gitea Actions.
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+const perPage = 50 // default MAX_RESPONSE_ITEMS of Gitea
+
+// Config is the configuration of the Gitea API client.
+type Config struct {
+	APIURL     string // e.g. https://gitea.example.com/api/v1 (GITHUB_API_URL on Gitea Actions)
+	Token      string // access token with the repository read and write scope
+	Repository string // owner/repo
+	HTTPClient *http.Client
+}
+
+// Client reviews the pull requests of the repository with the Gitea REST API.
+// ref: https://gitea.com/api/swagger
+type Client struct {
+	apiURL     string
+	token      string
+	repository string
+	httpClient *http.Client
+}
+
+var _ scm.Provider = (*Client)(nil)
+
+func NewClient(cfg *Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{apiURL: strings.TrimSuffix(cfg.APIURL, "/"), token: cfg.Token, repository: cfg.Repository, httpClient: httpClient}
+}
+
+type repository struct {
+	ID            int64    `json:"id"`
+	Name          string   `json:"name"`
+	FullName      string   `json:"full_name"`
+	Description   string   `json:"description"`
+	HTMLURL       string   `json:"html_url"`
+	CloneURL      string   `json:"clone_url"`
+	DefaultBranch string   `json:"default_branch"`
+	Private       bool     `json:"private"`
+	Internal      bool     `json:"internal"`
+	Archived      bool     `json:"archived"`
+	Topics        []string `json:"topics"`
+}
+
+type pullRequest struct {
+	Number  int     `json:"number"`
+	HTMLURL string  `json:"html_url"`
+	User    *user   `json:"user"`
+	Head    *branch `json:"head"`
+	Base    *branch `json:"base"`
+}
+
+type branch struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+type user struct {
+	Login string `json:"login"`
+}
+
+type comment struct {
+	ID       int64  `json:"id"`
+	Body     string `json:"body"`
+	HTMLURL  string `json:"html_url"`
+	Path     string `json:"path"`
+	Position int    `json:"position"` // line of the new file, 0 for the comments on the removed lines
+}
+
+type review struct {
+	ID int64 `json:"id"`
+}
+
+type createReview struct {
+	CommitID string                 `json:"commit_id"`
+	Event    string                 `json:"event"`
+	Comments []*createReviewComment `json:"comments"`
+}
+
+type createReviewComment struct {
+	Path        string `json:"path"`
+	Body        string `json:"body"`
+	NewPosition int    `json:"new_position"`
+}
+
+// GetChangeRequest returns the pull request of the repository.
+func (c *Client) GetChangeRequest(ctx context.Context, number int) (*scm.ChangeRequest, error) {
+	var repo repository
+	if _, err := c.do(ctx, http.MethodGet, c.repoPath(""), nil, nil, &repo); err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	var pr pullRequest
+	if _, err := c.do(ctx, http.MethodGet, c.repoPath(fmt.Sprintf("/pulls/%d", number)), nil, nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	visibility := "public"
+	switch {
+	case repo.Private:
+		visibility = "private"
+	case repo.Internal:
+		visibility = "internal"
+	}
+	cr := &scm.ChangeRequest{
+		Number: pr.Number,
+		URL:    pr.HTMLURL,
+		Repository: &scm.Repository{
+			Provider:      scm.PROVIDER_GITEA,
+			ID:            repo.ID,
+			Name:          repo.Name,
+			FullName:      repo.FullName,
+			Description:   repo.Description,
+			URL:           repo.HTMLURL,
+			CloneURL:      repo.CloneURL,
+			DefaultBranch: repo.DefaultBranch,
+			Visibility:    visibility,
+			Private:       repo.Private,
+			Archived:      repo.Archived,
+			Topics:        repo.Topics,
+		},
+	}
+	if pr.User != nil {
+		cr.Author = pr.User.Login
+	}
+	if pr.Head != nil {
+		cr.HeadSHA, cr.HeadBranch = pr.Head.SHA, pr.Head.Ref
+	}
+	if pr.Base != nil {
+		cr.BaseSHA, cr.BaseBranch = pr.Base.SHA, pr.Base.Ref
+	}
+	return cr, nil
+}
+
+// ListChangeFiles returns the files of the pull request diff. The API of the changed files has no patch, so the raw diff is parsed.
+func (c *Client) ListChangeFiles(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.ChangeFile, error) {
+	var diff bytes.Buffer
+	if _, err := c.do(ctx, http.MethodGet, c.repoPath(fmt.Sprintf("/pulls/%d.diff", cr.Number)), nil, nil, &diff); err != nil {
+		return nil, fmt.Errorf("failed to get pull request diff: %w", err)
+	}
+	return scm.ParseDiff(diff.String()), nil
+}
+
+// ListComments returns the comments of the pull request, not including the review comments.
+func (c *Client) ListComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	var issueComments []*comment
+	if _, err := c.do(ctx, http.MethodGet, c.repoPath(fmt.Sprintf("/issues/%d/comments", cr.Number)), nil, nil, &issueComments); err != nil {
+		return nil, fmt.Errorf("failed to list issue comments: %w", err)
+	}
+	var comments []*scm.Comment
+	for _, ic := range issueComments {
+		comments = append(comments, &scm.Comment{Body: ic.Body, URL: ic.HTMLURL})
+	}
+	return comments, nil
+}
+
+// ListLineComments returns the review comments on the added or unchanged lines of the pull request.
+// Gitea does not tell whether the comment is outdated, so the comments on the previous diffs are also returned.
+func (c *Client) ListLineComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	reviews, err := getAll[review](ctx, c, c.repoPath(fmt.Sprintf("/pulls/%d/reviews", cr.Number)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull request reviews: %w", err)
+	}
+	var comments []*scm.Comment
+	for _, r := range reviews {
+		var reviewComments []*comment
+		if _, err := c.do(ctx, http.MethodGet, c.repoPath(fmt.Sprintf("/pulls/%d/reviews/%d/comments", cr.Number, r.ID)), nil, nil, &reviewComments); err != nil {
+			return nil, fmt.Errorf("failed to list review comments: review=%d, err=%w", r.ID, err)
+		}
+		for _, rc := range reviewComments {
+			if rc.Path == "" || rc.Position == 0 {
+				continue
+			}
+			comments = append(comments, &scm.Comment{Path: rc.Path, Line: rc.Position, Body: rc.Body, URL: rc.HTMLURL})
+		}
+	}
+	return comments, nil
+}
+
+func (c *Client) CreateComment(ctx context.Context, cr *scm.ChangeRequest, body string) error {
+	if _, err := c.do(ctx, http.MethodPost, c.repoPath(fmt.Sprintf("/issues/%d/comments", cr.Number)), nil, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to create issue comment: %w", err)
+	}
+	return nil
+}
+
+// CreateLineComment creates the review with the comment on the line of the head commit.
+// ref: https://gitea.com/api/swagger#/repository/repoCreatePullReview
+func (c *Client) CreateLineComment(ctx context.Context, cr *scm.ChangeRequest, comment *scm.Comment) error {
+	req := &createReview{
+		CommitID: cr.HeadSHA,
+		Event:    "COMMENT",
+		Comments: []*createReviewComment{
+			{Path: comment.Path, Body: comment.Body, NewPosition: comment.Line},
+		},
+	}
+	if _, err := c.do(ctx, http.MethodPost, c.repoPath(fmt.Sprintf("/pulls/%d/reviews", cr.Number)), nil, req, nil); err != nil {
+		return fmt.Errorf("failed to create pull request review: path=%s, line=%d, err=%w", comment.Path, comment.Line, err)
+	}
+	return nil
+}
+
+func (c *Client) repoPath(suffix string) string {
+	return "/repos/" + c.repository + suffix
+}
+
+// getAll returns the items of all pages, until the total count in the X-Total-Count header.
+func getAll[T any](ctx context.Context, c *Client, path string) ([]*T, error) {
+	var all []*T
+	for page := 1; ; page++ {
+		query := url.Values{"limit": {strconv.Itoa(perPage)}, "page": {strconv.Itoa(page)}}
+		var items []*T
+		header, err := c.do(ctx, http.MethodGet, path, query, nil, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		total, err := strconv.Atoi(header.Get("X-Total-Count"))
+		if len(items) == 0 || (err == nil && len(all) >= total) {
+			return all, nil
+		}
+	}
+}
+
+// do sends the request with the JSON body, and decodes the JSON response into out (if not nil). The raw response is written if out is *bytes.Buffer.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) (http.Header, error) {
+	u := c.apiURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "token "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: method=%s, path=%s, err=%w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("gitea api error: method=%s, path=%s, status=%d, body=%s", method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	switch o := out.(type) {
+	case nil:
+	case *bytes.Buffer:
+		o.Write(respBody)
+	default:
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: method=%s, path=%s, err=%w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
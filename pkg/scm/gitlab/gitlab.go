@@ -0,0 +1,337 @@
+// Package gitlab is the GitLab implementation of the SCM provider, to review the merge requests on GitLab CI.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+)
+
+const (
+	DEFAULT_API_URL = "https://gitlab.com/api/v4"
+
+	perPage = 100
+)
+
+// Config is the configuration of the GitLab API client.
+type Config struct {
+	APIURL     string // e.g. https://gitlab.example.com/api/v4 (CI_API_V4_URL)
+	Token      string // personal, project or group access token with the api scope
+	ProjectID  string // numeric ID or the path (group/project) of the project (CI_PROJECT_ID)
+	HTTPClient *http.Client
+}
+
+// Client reviews the merge requests of the project with the GitLab REST API.
+// ref: https://docs.gitlab.com/ee/api/merge_requests.html
+type Client struct {
+	apiURL     string
+	token      string
+	projectID  string
+	httpClient *http.Client
+}
+
+var _ scm.Provider = (*Client)(nil)
+
+func NewClient(cfg *Config) *Client {
+	apiURL := strings.TrimSuffix(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = DEFAULT_API_URL
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{apiURL: apiURL, token: cfg.Token, projectID: cfg.ProjectID, httpClient: httpClient}
+}
+
+type project struct {
+	ID                int64    `json:"id"`
+	Name              string   `json:"name"`
+	PathWithNamespace string   `json:"path_with_namespace"`
+	Description       string   `json:"description"`
+	WebURL            string   `json:"web_url"`
+	HTTPURLToRepo     string   `json:"http_url_to_repo"`
+	DefaultBranch     string   `json:"default_branch"`
+	Visibility        string   `json:"visibility"`
+	Archived          bool     `json:"archived"`
+	Topics            []string `json:"topics"`
+}
+
+type mergeRequest struct {
+	IID          int       `json:"iid"`
+	WebURL       string    `json:"web_url"`
+	SHA          string    `json:"sha"`
+	SourceBranch string    `json:"source_branch"`
+	TargetBranch string    `json:"target_branch"`
+	Author       *user     `json:"author"`
+	DiffRefs     *diffRefs `json:"diff_refs"`
+}
+
+type user struct {
+	Username string `json:"username"`
+}
+
+type diffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	HeadSHA  string `json:"head_sha"`
+	StartSHA string `json:"start_sha"`
+}
+
+type diff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	Diff        string `json:"diff"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+}
+
+type note struct {
+	ID       int64     `json:"id"`
+	Type     string    `json:"type"` // DiffNote for the notes on the diff lines
+	Body     string    `json:"body"`
+	System   bool      `json:"system"`
+	Position *position `json:"position,omitempty"`
+}
+
+type position struct {
+	PositionType string `json:"position_type"`
+	BaseSHA      string `json:"base_sha"`
+	StartSHA     string `json:"start_sha"`
+	HeadSHA      string `json:"head_sha"`
+	OldPath      string `json:"old_path,omitempty"`
+	NewPath      string `json:"new_path"`
+	NewLine      int    `json:"new_line,omitempty"`
+}
+
+type discussion struct {
+	ID    string  `json:"id"`
+	Notes []*note `json:"notes"`
+}
+
+type createDiscussion struct {
+	Body     string    `json:"body"`
+	Position *position `json:"position"`
+}
+
+// GetChangeRequest returns the merge request of the project, with the diff refs to comment on the latest diff.
+func (c *Client) GetChangeRequest(ctx context.Context, iid int) (*scm.ChangeRequest, error) {
+	var p project
+	if _, err := c.do(ctx, http.MethodGet, c.projectPath(""), nil, nil, &p); err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	var mr mergeRequest
+	if _, err := c.do(ctx, http.MethodGet, c.mergeRequestPath(iid, ""), nil, nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to get merge request: %w", err)
+	}
+	cr := &scm.ChangeRequest{
+		Number:     mr.IID,
+		URL:        mr.WebURL,
+		HeadSHA:    mr.SHA,
+		HeadBranch: mr.SourceBranch,
+		BaseBranch: mr.TargetBranch,
+		Repository: &scm.Repository{
+			Provider:      scm.PROVIDER_GITLAB,
+			ID:            p.ID,
+			Name:          p.Name,
+			FullName:      p.PathWithNamespace,
+			Description:   p.Description,
+			URL:           p.WebURL,
+			CloneURL:      p.HTTPURLToRepo,
+			DefaultBranch: p.DefaultBranch,
+			Visibility:    p.Visibility,
+			Private:       p.Visibility != "public",
+			Archived:      p.Archived,
+			Topics:        p.Topics,
+		},
+	}
+	if mr.Author != nil {
+		cr.Author = mr.Author.Username
+	}
+	// The diff refs are empty until the diff of the new merge request is created (asynchronously)
+	if mr.DiffRefs != nil {
+		cr.HeadSHA, cr.BaseSHA, cr.StartSHA = mr.DiffRefs.HeadSHA, mr.DiffRefs.BaseSHA, mr.DiffRefs.StartSHA
+	}
+	return cr, nil
+}
+
+// ListChangeFiles returns the files of the merge request diff. The diffs over the size limit of GitLab have no patch.
+func (c *Client) ListChangeFiles(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.ChangeFile, error) {
+	diffs, err := getAll[diff](ctx, c, c.mergeRequestPath(cr.Number, "/diffs"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merge request diffs: %w", err)
+	}
+	changeFiles := []*scm.ChangeFile{}
+	for _, d := range diffs {
+		if d.DeletedFile {
+			// Can not scan removed files
+			continue
+		}
+		status := scm.FILE_MODIFIED
+		switch {
+		case d.NewFile:
+			status = scm.FILE_ADDED
+		case d.RenamedFile:
+			status = scm.FILE_RENAMED
+		}
+		changeFiles = append(changeFiles, &scm.ChangeFile{
+			Filename: d.NewPath,
+			Status:   status,
+			Patch:    strings.TrimSuffix(d.Diff, "\n"),
+		})
+	}
+	return changeFiles, nil
+}
+
+// ListComments returns the notes of the merge request, except the system notes and the notes on the diff lines.
+func (c *Client) ListComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	notes, err := getAll[note](ctx, c, c.mergeRequestPath(cr.Number, "/notes"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merge request notes: %w", err)
+	}
+	var comments []*scm.Comment
+	for _, n := range notes {
+		if n.System || n.Position != nil {
+			continue
+		}
+		comments = append(comments, &scm.Comment{Body: n.Body, URL: noteURL(cr, n)})
+	}
+	return comments, nil
+}
+
+// ListLineComments returns the notes on the added or unchanged lines of the merge request diff.
+// GitLab does not tell whether the note is outdated, so the notes on the previous diffs are also returned.
+func (c *Client) ListLineComments(ctx context.Context, cr *scm.ChangeRequest) ([]*scm.Comment, error) {
+	discussions, err := getAll[discussion](ctx, c, c.mergeRequestPath(cr.Number, "/discussions"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list merge request discussions: %w", err)
+	}
+	var comments []*scm.Comment
+	for _, d := range discussions {
+		for _, n := range d.Notes {
+			if n.System || n.Position == nil || n.Position.NewLine == 0 {
+				continue
+			}
+			comments = append(comments, &scm.Comment{
+				Path: n.Position.NewPath,
+				Line: n.Position.NewLine,
+				Body: n.Body,
+				URL:  noteURL(cr, n),
+			})
+		}
+	}
+	return comments, nil
+}
+
+func (c *Client) CreateComment(ctx context.Context, cr *scm.ChangeRequest, body string) error {
+	if _, err := c.do(ctx, http.MethodPost, c.mergeRequestPath(cr.Number, "/notes"), nil, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to create merge request note: %w", err)
+	}
+	return nil
+}
+
+// CreateLineComment starts the discussion on the line of the latest merge request diff.
+// ref: https://docs.gitlab.com/ee/api/discussions.html#create-a-new-thread-in-the-merge-request-diff
+func (c *Client) CreateLineComment(ctx context.Context, cr *scm.ChangeRequest, comment *scm.Comment) error {
+	req := &createDiscussion{
+		Body: comment.Body,
+		Position: &position{
+			PositionType: "text",
+			BaseSHA:      cr.BaseSHA,
+			StartSHA:     cr.StartSHA,
+			HeadSHA:      cr.HeadSHA,
+			OldPath:      comment.Path,
+			NewPath:      comment.Path,
+			NewLine:      comment.Line,
+		},
+	}
+	if _, err := c.do(ctx, http.MethodPost, c.mergeRequestPath(cr.Number, "/discussions"), nil, req, nil); err != nil {
+		return fmt.Errorf("failed to create merge request discussion: path=%s, line=%d, err=%w", comment.Path, comment.Line, err)
+	}
+	return nil
+}
+
+func noteURL(cr *scm.ChangeRequest, n *note) string {
+	if cr.URL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s#note_%d", cr.URL, n.ID)
+}
+
+// projectPath returns the API path of the project. The project path (group/project) is URL-encoded as the ID.
+func (c *Client) projectPath(suffix string) string {
+	return "/projects/" + url.PathEscape(c.projectID) + suffix
+}
+
+func (c *Client) mergeRequestPath(iid int, suffix string) string {
+	return c.projectPath(fmt.Sprintf("/merge_requests/%d%s", iid, suffix))
+}
+
+// getAll returns the items of all pages, following the X-Next-Page header.
+// ref: https://docs.gitlab.com/ee/api/rest/#pagination
+func getAll[T any](ctx context.Context, c *Client, path string) ([]*T, error) {
+	var all []*T
+	page := "1"
+	for page != "" {
+		query := url.Values{"per_page": {strconv.Itoa(perPage)}, "page": {page}}
+		var items []*T
+		header, err := c.do(ctx, http.MethodGet, path, query, nil, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		page = header.Get("X-Next-Page")
+	}
+	return all, nil
+}
+
+// do sends the request with the JSON body, and decodes the JSON response into out (if not nil).
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) (http.Header, error) {
+	u := c.apiURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: method=%s, path=%s, err=%w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("gitlab api error: method=%s, path=%s, status=%d, body=%s", method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: method=%s, path=%s, err=%w", method, path, err)
+		}
+	}
+	return resp.Header, nil
+}
@@ -0,0 +1,214 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/scm"
+	"github.com/google/go-cmp/cmp"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("PRIVATE-TOKEN"); got != "token" {
+			t.Errorf("PRIVATE-TOKEN = %s, want token", got)
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(&Config{
+		APIURL:    server.URL + "/api/v4/",
+		Token:     "token",
+		ProjectID: "group/project",
+	})
+}
+
+func TestGetChangeRequest(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mr      string
+		status  int
+		want    *scm.ChangeRequest
+		wantErr bool
+	}{
+		{
+			name: "OK",
+			mr:   `{"iid":1,"web_url":"https://gitlab.com/group/project/-/merge_requests/1","sha":"head","source_branch":"feature","target_branch":"main","author":{"username":"contributor"},"diff_refs":{"base_sha":"base","head_sha":"head","start_sha":"start"}}`,
+			want: &scm.ChangeRequest{
+				Number: 1, URL: "https://gitlab.com/group/project/-/merge_requests/1", HeadSHA: "head", BaseSHA: "base", StartSHA: "start",
+				HeadBranch: "feature", BaseBranch: "main", Author: "contributor",
+				Repository: &scm.Repository{
+					Provider: scm.PROVIDER_GITLAB, ID: 10, Name: "project", FullName: "group/project",
+					URL: "https://gitlab.com/group/project", CloneURL: "https://gitlab.com/group/project.git",
+					DefaultBranch: "main", Visibility: "internal", Private: true, Topics: []string{"go"},
+				},
+			},
+		},
+		{
+			name: "No diff refs",
+			mr:   `{"iid":1,"web_url":"https://gitlab.com/group/project/-/merge_requests/1","sha":"head"}`,
+			want: &scm.ChangeRequest{
+				Number: 1, URL: "https://gitlab.com/group/project/-/merge_requests/1", HeadSHA: "head",
+				Repository: &scm.Repository{
+					Provider: scm.PROVIDER_GITLAB, ID: 10, Name: "project", FullName: "group/project",
+					URL: "https://gitlab.com/group/project", CloneURL: "https://gitlab.com/group/project.git",
+					DefaultBranch: "main", Visibility: "internal", Private: true, Topics: []string{"go"},
+				},
+			},
+		},
+		{
+			name:    "Not found",
+			status:  http.StatusNotFound,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.EscapedPath() {
+				case "/api/v4/projects/group%2Fproject":
+					_, _ = w.Write([]byte(`{"id":10,"name":"project","path_with_namespace":"group/project","web_url":"https://gitlab.com/group/project","http_url_to_repo":"https://gitlab.com/group/project.git","default_branch":"main","visibility":"internal","topics":["go"]}`))
+				case "/api/v4/projects/group%2Fproject/merge_requests/1":
+					if tc.status != 0 {
+						w.WriteHeader(tc.status)
+						_, _ = w.Write([]byte(`{"message":"404 Not found"}`))
+						return
+					}
+					_, _ = w.Write([]byte(tc.mr))
+				default:
+					t.Errorf("unexpected path: %s", r.URL.EscapedPath())
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+			got, err := client.GetChangeRequest(context.Background(), 1)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("GetChangeRequest() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GetChangeRequest() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListChangeFiles(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fproject/merge_requests/1/diffs" {
+			t.Errorf("unexpected path: %s", r.URL.EscapedPath())
+		}
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			_, _ = w.Write([]byte(`[{"old_path":"main.go","new_path":"main.go","diff":"@@ -1 +1 @@\n-a\n+b\n"},{"old_path":"new.go","new_path":"new.go","diff":"@@ -0,0 +1 @@\n+c\n","new_file":true}]`))
+		case "2":
+			_, _ = w.Write([]byte(`[{"old_path":"old.go","new_path":"renamed.go","renamed_file":true},{"old_path":"deleted.go","new_path":"deleted.go","deleted_file":true}]`))
+		default:
+			t.Errorf("unexpected page: %s", r.URL.Query().Get("page"))
+		}
+	})
+	got, err := client.ListChangeFiles(context.Background(), &scm.ChangeRequest{Number: 1})
+	if err != nil {
+		t.Fatalf("ListChangeFiles() error = %v", err)
+	}
+	want := []*scm.ChangeFile{
+		{Filename: "main.go", Status: scm.FILE_MODIFIED, Patch: "@@ -1 +1 @@\n-a\n+b"},
+		{Filename: "new.go", Status: scm.FILE_ADDED, Patch: "@@ -0,0 +1 @@\n+c"},
+		{Filename: "renamed.go", Status: scm.FILE_RENAMED},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ListChangeFiles() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestListComments(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/group%2Fproject/merge_requests/1/notes":
+			_, _ = w.Write([]byte(`[{"id":1,"body":"comment"},{"id":2,"body":"added 1 commit","system":true},{"id":3,"type":"DiffNote","body":"line comment","position":{"new_path":"main.go","new_line":3}}]`))
+		case "/api/v4/projects/group%2Fproject/merge_requests/1/discussions":
+			_, _ = w.Write([]byte(`[{"id":"a","notes":[{"id":3,"type":"DiffNote","body":"line comment","position":{"new_path":"main.go","new_line":3}},{"id":4,"type":"DiffNote","body":"reply","position":{"new_path":"main.go","new_line":3}}]},{"id":"b","notes":[{"id":5,"type":"DiffNote","body":"removed line","position":{"old_path":"main.go","new_path":"main.go"}}]},{"id":"c","notes":[{"id":1,"body":"comment"}]}]`))
+		default:
+			t.Errorf("unexpected path: %s", r.URL.EscapedPath())
+		}
+	})
+	cr := &scm.ChangeRequest{Number: 1, URL: "https://gitlab.com/group/project/-/merge_requests/1"}
+
+	comments, err := client.ListComments(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("ListComments() error = %v", err)
+	}
+	wantComments := []*scm.Comment{
+		{Body: "comment", URL: "https://gitlab.com/group/project/-/merge_requests/1#note_1"},
+	}
+	if diff := cmp.Diff(wantComments, comments); diff != "" {
+		t.Errorf("ListComments() mismatch (-want +got):\n%s", diff)
+	}
+
+	lineComments, err := client.ListLineComments(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("ListLineComments() error = %v", err)
+	}
+	wantLineComments := []*scm.Comment{
+		{Path: "main.go", Line: 3, Body: "line comment", URL: "https://gitlab.com/group/project/-/merge_requests/1#note_3"},
+		{Path: "main.go", Line: 3, Body: "reply", URL: "https://gitlab.com/group/project/-/merge_requests/1#note_4"},
+	}
+	if diff := cmp.Diff(wantLineComments, lineComments); diff != "" {
+		t.Errorf("ListLineComments() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestCreateComment(t *testing.T) {
+	var gotPaths []string
+	var gotBodies []map[string]any
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read body: %v", err)
+			return
+		}
+		var body map[string]any
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+			return
+		}
+		gotPaths = append(gotPaths, r.URL.EscapedPath())
+		gotBodies = append(gotBodies, body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	cr := &scm.ChangeRequest{Number: 1, HeadSHA: "head", BaseSHA: "base", StartSHA: "start"}
+	if err := client.CreateComment(context.Background(), cr, "summary"); err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	if err := client.CreateLineComment(context.Background(), cr, &scm.Comment{Path: "main.go", Line: 3, Body: "finding"}); err != nil {
+		t.Fatalf("CreateLineComment() error = %v", err)
+	}
+	wantPaths := []string{
+		"/api/v4/projects/group%2Fproject/merge_requests/1/notes",
+		"/api/v4/projects/group%2Fproject/merge_requests/1/discussions",
+	}
+	if diff := cmp.Diff(wantPaths, gotPaths); diff != "" {
+		t.Errorf("paths mismatch (-want +got):\n%s", diff)
+	}
+	wantBodies := []map[string]any{
+		{"body": "summary"},
+		{
+			"body": "finding",
+			"position": map[string]any{
+				"position_type": "text", "base_sha": "base", "start_sha": "start", "head_sha": "head",
+				"old_path": "main.go", "new_path": "main.go", "new_line": float64(3),
+			},
+		},
+	}
+	if diff := cmp.Diff(wantBodies, gotBodies); diff != "" {
+		t.Errorf("bodies mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,116 @@
+// Package scm is the provider-neutral model of the change requests (GitHub, Gitea and Bitbucket pull requests, GitLab merge requests) to review.
+package scm
+
+import (
+	"context"
+	"fmt"
+)
+
+// Providers
+const (
+	PROVIDER_GITHUB    = "github"
+	PROVIDER_GITLAB    = "gitlab"
+	PROVIDER_GITEA     = "gitea" // also Forgejo
+	PROVIDER_BITBUCKET = "bitbucket"
+)
+
+// Change file statuses
+const (
+	FILE_ADDED    = "added"
+	FILE_MODIFIED = "modified"
+	FILE_RENAMED  = "renamed"
+	FILE_REMOVED  = "removed"
+)
+
+// Repository is the repository hosted on the SCM.
+type Repository struct {
+	Provider      string
+	ID            int64
+	Name          string
+	FullName      string // owner/repo, group/subgroup/project on GitLab, or PROJECT/repo on Bitbucket
+	Description   string
+	URL           string // web URL, e.g. https://github.com/owner/repo
+	CloneURL      string
+	DefaultBranch string
+	Visibility    string // public, private or internal
+	Private       bool
+	Archived      bool
+	Topics        []string
+}
+
+// FileURL returns the web URL of the line of the file at the commit.
+func (r *Repository) FileURL(commit, path string, line int) string {
+	switch r.Provider {
+	case PROVIDER_GITLAB:
+		return fmt.Sprintf("%s/-/blob/%s/%s#L%d", r.URL, commit, path, line)
+	case PROVIDER_GITEA:
+		return fmt.Sprintf("%s/src/commit/%s/%s#L%d", r.URL, commit, path, line)
+	case PROVIDER_BITBUCKET:
+		return fmt.Sprintf("%s/browse/%s?at=%s#%d", r.URL, path, commit, line)
+	default:
+		return fmt.Sprintf("%s/blob/%s/%s#L%d", r.URL, commit, path, line)
+	}
+}
+
+// LinesURL returns the web URL of the lines of the file at the commit.
+func (r *Repository) LinesURL(commit, path string, start, end int) string {
+	switch r.Provider {
+	case PROVIDER_GITLAB:
+		return fmt.Sprintf("%s/-/blob/%s/%s#L%d-%d", r.URL, commit, path, start, end)
+	case PROVIDER_GITEA:
+		return fmt.Sprintf("%s/src/commit/%s/%s#L%d-L%d", r.URL, commit, path, start, end)
+	case PROVIDER_BITBUCKET:
+		return fmt.Sprintf("%s/browse/%s?at=%s#%d-%d", r.URL, path, commit, start, end)
+	default:
+		return fmt.Sprintf("%s/blob/%s/%s#L%d-L%d", r.URL, commit, path, start, end)
+	}
+}
+
+// ChangeRequest is the pull request (GitHub, Gitea and Bitbucket) or the merge request (GitLab).
+type ChangeRequest struct {
+	Number     int // PR number, or MR IID
+	URL        string
+	HeadSHA    string
+	BaseSHA    string
+	StartSHA   string // GitLab only: the target branch head the MR diff was created from
+	HeadBranch string
+	BaseBranch string
+	Author     string // login name of the author
+	Repository *Repository
+}
+
+// String returns the reference of the change request, e.g. owner/repo#1, or group/project!1 on GitLab.
+func (cr *ChangeRequest) String() string {
+	if cr.Repository.Provider == PROVIDER_GITLAB {
+		return fmt.Sprintf("%s!%d", cr.Repository.FullName, cr.Number)
+	}
+	return fmt.Sprintf("%s#%d", cr.Repository.FullName, cr.Number)
+}
+
+// ChangeFile is the file changed in the change request or the commit range.
+type ChangeFile struct {
+	Filename string
+	Status   string // added, modified, renamed or removed
+	Patch    string // unified diff hunks without the file header, empty if binary or too large
+}
+
+// Comment is the comment on the change request. Path and Line are set for the comments on the diff lines.
+type Comment struct {
+	Path string
+	Line int
+	Body string
+	URL  string
+}
+
+// Provider is the SCM API to review the change requests.
+type Provider interface {
+	// ListChangeFiles returns the changed files of the change request, except the removed files.
+	ListChangeFiles(ctx context.Context, cr *ChangeRequest) ([]*ChangeFile, error)
+	// ListComments returns the comments on the change request, not on the diff lines.
+	ListComments(ctx context.Context, cr *ChangeRequest) ([]*Comment, error)
+	// ListLineComments returns the comments on the diff lines which are still on the latest diff (not outdated).
+	ListLineComments(ctx context.Context, cr *ChangeRequest) ([]*Comment, error)
+	CreateComment(ctx context.Context, cr *ChangeRequest, body string) error
+	// CreateLineComment comments on the line (new file side) of the latest diff.
+	CreateLineComment(ctx context.Context, cr *ChangeRequest, comment *Comment) error
+}
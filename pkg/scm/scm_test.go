@@ -0,0 +1,56 @@
+package scm
+
+import "testing"
+
+func TestRepositoryURL(t *testing.T) {
+	testCases := []struct {
+		name      string
+		repo      *Repository
+		wantFile  string
+		wantLines string
+		wantCR    string
+	}{
+		{
+			name:      "GitHub",
+			repo:      &Repository{Provider: PROVIDER_GITHUB, FullName: "owner/repo", URL: "https://github.com/owner/repo"},
+			wantFile:  "https://github.com/owner/repo/blob/sha/dir/main.go#L3",
+			wantLines: "https://github.com/owner/repo/blob/sha/dir/main.go#L3-L5",
+			wantCR:    "owner/repo#1",
+		},
+		{
+			name:      "GitLab",
+			repo:      &Repository{Provider: PROVIDER_GITLAB, FullName: "group/project", URL: "https://gitlab.com/group/project"},
+			wantFile:  "https://gitlab.com/group/project/-/blob/sha/dir/main.go#L3",
+			wantLines: "https://gitlab.com/group/project/-/blob/sha/dir/main.go#L3-5",
+			wantCR:    "group/project!1",
+		},
+		{
+			name:      "Gitea",
+			repo:      &Repository{Provider: PROVIDER_GITEA, FullName: "owner/repo", URL: "https://gitea.example.com/owner/repo"},
+			wantFile:  "https://gitea.example.com/owner/repo/src/commit/sha/dir/main.go#L3",
+			wantLines: "https://gitea.example.com/owner/repo/src/commit/sha/dir/main.go#L3-L5",
+			wantCR:    "owner/repo#1",
+		},
+		{
+			name:      "Bitbucket",
+			repo:      &Repository{Provider: PROVIDER_BITBUCKET, FullName: "PRJ/repo", URL: "https://bitbucket.example.com/projects/PRJ/repos/repo"},
+			wantFile:  "https://bitbucket.example.com/projects/PRJ/repos/repo/browse/dir/main.go?at=sha#3",
+			wantLines: "https://bitbucket.example.com/projects/PRJ/repos/repo/browse/dir/main.go?at=sha#3-5",
+			wantCR:    "PRJ/repo#1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.repo.FileURL("sha", "dir/main.go", 3); got != tc.wantFile {
+				t.Errorf("FileURL() = %s, want %s", got, tc.wantFile)
+			}
+			if got := tc.repo.LinesURL("sha", "dir/main.go", 3, 5); got != tc.wantLines {
+				t.Errorf("LinesURL() = %s, want %s", got, tc.wantLines)
+			}
+			cr := &ChangeRequest{Number: 1, Repository: tc.repo}
+			if got := cr.String(); got != tc.wantCR {
+				t.Errorf("String() = %s, want %s", got, tc.wantCR)
+			}
+		})
+	}
+}
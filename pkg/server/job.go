@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ca-risken/security-review/pkg/review"
+)
+
+// job is the review of a pull request head.
+type job struct {
+	Delivery       string
+	Repository     string // owner/repo
+	CloneURL       string
+	Number         int
+	HeadSHA        string
+	InstallationID int64
+	Payload        []byte // the webhook payload, same as the pull_request event of Actions
+}
+
+func (s *Server) worker(ctx context.Context) {
+	for j := range s.queue {
+		logger := s.logger.With(slog.String("delivery", j.Delivery), slog.String("repository", j.Repository), slog.Int("number", j.Number))
+		if ctx.Err() != nil {
+			s.metrics.job(JOB_DROPPED, 0)
+			logger.Warn("Drop queued review on shutdown")
+			continue
+		}
+		s.metrics.running(1)
+		start := time.Now()
+		err := s.runJob(j, logger)
+		s.metrics.running(-1)
+		if err != nil {
+			s.metrics.job(JOB_FAILURE, time.Since(start))
+			logger.Error("Failed to review", slog.String("err", err.Error()))
+			continue
+		}
+		s.metrics.job(JOB_SUCCESS, time.Since(start))
+		logger.Info("Success review", slog.Duration("duration", time.Since(start)))
+	}
+}
+
+// runJob runs the review in the scratch workspace, which is removed after the review.
+// The running review is not canceled on shutdown, not to leave the partial comments.
+func (s *Server) runJob(j *job, logger *slog.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.JobTimeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp(s.cfg.WorkDir, "risken-review-*")
+	if err != nil {
+		return fmt.Errorf("failed to create workspace: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Warn("Failed to remove workspace", slog.String("dir", dir), slog.String("err", err.Error()))
+		}
+	}()
+	eventPath := filepath.Join(dir, "event.json")
+	if err := os.WriteFile(eventPath, j.Payload, 0600); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+
+	opt := s.jobOption(j, eventPath, filepath.Join(dir, "workspace"))
+	if err := s.fetch(ctx, opt, j); err != nil {
+		return err
+	}
+	return s.runReview(ctx, opt, logger)
+}
+
+// jobOption returns the review option of the job, based on the server option.
+func (s *Server) jobOption(j *job, eventPath, workspace string) *review.ReviewOption {
+	opt := *s.cfg.ReviewOption
+	opt.GithubEventName = review.EVENT_PULL_REQUEST
+	opt.GithubEventPath = eventPath
+	opt.GithubWorkspace = workspace
+	opt.GithubRepository = j.Repository
+	opt.GithubSHA = j.HeadSHA
+	opt.GithubRef = ""
+	if opt.GithubAppID != 0 {
+		opt.GithubAppInstallationID = j.InstallationID // 0 finds the installation by the repository
+	}
+	// The files are shared by the concurrent reviews
+	opt.Output = ""
+	opt.DryRunOutput = ""
+	return &opt
+}
+
+func runReview(ctx context.Context, opt *review.ReviewOption, logger *slog.Logger) error {
+	svc, err := review.NewReviewService(ctx, opt, logger)
+	if err != nil {
+		return err
+	}
+	return svc.Run(ctx)
+}
+
+// fetchPullRequest fetches only the PR head commit into the workspace.
+// The token is passed by the environment variables, not to appear in the process list.
+func (s *Server) fetchPullRequest(ctx context.Context, opt *review.ReviewOption, j *job) error {
+	ts, err := review.NewGitHubTokenSource(ctx, &review.GitHubClientConfig{
+		Token:             opt.GithubToken,
+		APIURL:            opt.GithubAPIURL,
+		UploadURL:         opt.GithubUploadURL,
+		CABundle:          opt.GithubCABundle,
+		AppID:             opt.GithubAppID,
+		AppPrivateKey:     opt.GithubAppPrivateKey,
+		AppInstallationID: opt.GithubAppInstallationID,
+		Repository:        opt.GithubRepository,
+		Logger:            s.logger,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create token source: %w", err)
+	}
+	token, err := ts.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	gitConfig := map[string]string{
+		"http.extraHeader": "Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("x-access-token:"+token.AccessToken)),
+	}
+	if opt.GithubCABundle != "" {
+		gitConfig["http.sslCAInfo"] = opt.GithubCABundle
+	}
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0", fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(gitConfig)))
+	i := 0
+	for key, value := range gitConfig {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, key), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, value))
+		i++
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q", opt.GithubWorkspace},
+		{"-C", opt.GithubWorkspace, "fetch", "-q", "--depth=1", "--no-tags", j.CloneURL, j.HeadSHA},
+		{"-C", opt.GithubWorkspace, "checkout", "-q", "--detach", "FETCH_HEAD"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Env = env
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to execute git %s: err=%w, stderr=%s", strings.Join(args, " "), err, stderr.String())
+		}
+	}
+	return nil
+}
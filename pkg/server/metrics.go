@@ -0,0 +1,97 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook results
+const (
+	WEBHOOK_ACCEPTED          = "accepted"
+	WEBHOOK_IGNORED           = "ignored"
+	WEBHOOK_INVALID_SIGNATURE = "invalid_signature"
+	WEBHOOK_BAD_REQUEST       = "bad_request"
+	WEBHOOK_QUEUE_FULL        = "queue_full"
+)
+
+// Job results
+const (
+	JOB_SUCCESS = "success"
+	JOB_FAILURE = "failure"
+	JOB_DROPPED = "dropped"
+)
+
+// metrics are exposed in the Prometheus text format.
+type metrics struct {
+	mu               sync.Mutex
+	webhooks         map[string]int
+	jobs             map[string]int
+	jobsRunning      int
+	jobDurationSum   float64
+	jobDurationCount int
+}
+
+func newMetrics() *metrics {
+	return &metrics{webhooks: map[string]int{}, jobs: map[string]int{}}
+}
+
+func (m *metrics) webhook(result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks[result]++
+}
+
+func (m *metrics) job(result string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs[result]++
+	if result != JOB_DROPPED {
+		m.jobDurationSum += duration.Seconds()
+		m.jobDurationCount++
+	}
+}
+
+func (m *metrics) running(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobsRunning += delta
+}
+
+func (m *metrics) write(w io.Writer, queueLength int) error {
+	m.mu.Lock()
+	var b strings.Builder
+	b.WriteString("# HELP risken_review_webhooks_total Number of the received webhooks by result.\n")
+	b.WriteString("# TYPE risken_review_webhooks_total counter\n")
+	writeCounters(&b, "risken_review_webhooks_total", m.webhooks)
+	b.WriteString("# HELP risken_review_jobs_total Number of the finished reviews by result.\n")
+	b.WriteString("# TYPE risken_review_jobs_total counter\n")
+	writeCounters(&b, "risken_review_jobs_total", m.jobs)
+	b.WriteString("# HELP risken_review_jobs_running Number of the running reviews.\n")
+	b.WriteString("# TYPE risken_review_jobs_running gauge\n")
+	fmt.Fprintf(&b, "risken_review_jobs_running %d\n", m.jobsRunning)
+	b.WriteString("# HELP risken_review_queue_length Number of the queued reviews.\n")
+	b.WriteString("# TYPE risken_review_queue_length gauge\n")
+	fmt.Fprintf(&b, "risken_review_queue_length %d\n", queueLength)
+	b.WriteString("# HELP risken_review_job_duration_seconds Duration of the reviews.\n")
+	b.WriteString("# TYPE risken_review_job_duration_seconds summary\n")
+	fmt.Fprintf(&b, "risken_review_job_duration_seconds_sum %g\n", m.jobDurationSum)
+	fmt.Fprintf(&b, "risken_review_job_duration_seconds_count %d\n", m.jobDurationCount)
+	m.mu.Unlock()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeCounters(b *strings.Builder, name string, counters map[string]int) {
+	results := make([]string, 0, len(counters))
+	for result := range counters {
+		results = append(results, result)
+	}
+	sort.Strings(results)
+	for _, result := range results {
+		fmt.Fprintf(b, "%s{result=%q} %d\n", name, result, counters[result])
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ca-risken/security-review/pkg/review"
+)
+
+const (
+	DEFAULT_ADDR        = ":8080"
+	DEFAULT_CONCURRENCY = 2
+	DEFAULT_QUEUE_SIZE  = 100
+	DEFAULT_JOB_TIMEOUT = 30 * time.Minute
+
+	shutdownTimeout = 30 * time.Second
+)
+
+// Config is the configuration of the webhook server.
+type Config struct {
+	Addr          string
+	WebhookSecret string
+	Concurrency   int           // number of the reviews running at the same time
+	QueueSize     int           // number of the reviews waiting, the webhooks are rejected (503) if full
+	WorkDir       string        // parent directory of the scratch workspaces, os.TempDir() if empty
+	JobTimeout    time.Duration // timeout of a review including the fetch
+
+	// ReviewOption is the base option of the reviews (GitHub App, RISKEN, scanners).
+	// The event, the workspace and the repository are set per webhook.
+	ReviewOption *review.ReviewOption
+	Logger       *slog.Logger
+}
+
+// Server receives the GitHub webhooks, and reviews the pull requests in the background.
+type Server struct {
+	cfg     *Config
+	queue   chan *job
+	metrics *metrics
+	logger  *slog.Logger
+
+	// replaceable in the tests
+	fetch     func(ctx context.Context, opt *review.ReviewOption, j *job) error
+	runReview func(ctx context.Context, opt *review.ReviewOption, logger *slog.Logger) error
+}
+
+func New(cfg *Config) (*Server, error) {
+	if cfg.WebhookSecret == "" {
+		return nil, errors.New("webhook secret is required")
+	}
+	if cfg.ReviewOption == nil {
+		return nil, errors.New("review option is required")
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = DEFAULT_ADDR
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DEFAULT_CONCURRENCY
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DEFAULT_QUEUE_SIZE
+	}
+	if cfg.JobTimeout <= 0 {
+		cfg.JobTimeout = DEFAULT_JOB_TIMEOUT
+	}
+	s := &Server{
+		cfg:     cfg,
+		queue:   make(chan *job, cfg.QueueSize),
+		metrics: newMetrics(),
+		logger:  cfg.Logger,
+	}
+	s.fetch = s.fetchPullRequest
+	s.runReview = runReview
+	return s, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := s.metrics.write(w, len(s.queue)); err != nil {
+			s.logger.Warn("Failed to write metrics", slog.String("err", err.Error()))
+		}
+	})
+	return mux
+}
+
+// Run serves until the context is canceled. On shutdown, the running reviews are completed, and the queued ones are dropped.
+func (s *Server) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < s.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(ctx)
+		}()
+	}
+
+	srv := &http.Server{
+		Addr:              s.cfg.Addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.InfoContext(ctx, "Start webhook server", slog.String("addr", s.cfg.Addr), slog.Int("concurrency", s.cfg.Concurrency), slog.Int("queue_size", s.cfg.QueueSize))
+		errCh <- srv.ListenAndServe()
+	}()
+
+	var serveErr error
+	select {
+	case err := <-errCh:
+		serveErr = fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+		s.logger.Info("Shutdown webhook server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			serveErr = fmt.Errorf("failed to shutdown: %w", err)
+		}
+	}
+	// No more webhooks are accepted after the shutdown
+	close(s.queue)
+	wg.Wait()
+	return serveErr
+}
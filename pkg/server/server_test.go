@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ca-risken/security-review/pkg/review"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testSecret = "secret"
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	payload := `{"action":"opened"}`
+	testCases := []struct {
+		name      string
+		signature string
+		want      bool
+	}{
+		{name: "Valid", signature: sign(payload), want: true},
+		{name: "Invalid", signature: sign(payload + " "), want: false},
+		{name: "No prefix", signature: strings.TrimPrefix(sign(payload), "sha256="), want: false},
+		{name: "Not hex", signature: "sha256=zz", want: false},
+		{name: "Empty", signature: "", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := verifySignature(testSecret, []byte(payload), tc.signature); got != tc.want {
+				t.Errorf("verifySignature() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func newTestServer(t *testing.T, queueSize int) *Server {
+	t.Helper()
+	s, err := New(&Config{
+		WebhookSecret: testSecret,
+		QueueSize:     queueSize,
+		WorkDir:       t.TempDir(),
+		ReviewOption:  &review.ReviewOption{GithubAppID: 1, GithubAppPrivateKey: "key", Output: "out.json"},
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestHandleWebhook(t *testing.T) {
+	prPayload := `{"action":"synchronize","number":1,"pull_request":{"head":{"sha":"head"}},"repository":{"full_name":"owner/repo","clone_url":"https://github.com/owner/repo.git"},"installation":{"id":123}}`
+	testCases := []struct {
+		name       string
+		event      string
+		payload    string
+		signature  string
+		queueSize  int
+		queued     int // jobs in the queue before the request
+		wantStatus int
+		wantQueued int
+	}{
+		{name: "Review", event: "pull_request", payload: prPayload, signature: sign(prPayload), wantStatus: http.StatusAccepted, wantQueued: 1},
+		{name: "Invalid signature", event: "pull_request", payload: prPayload, signature: sign("{}"), wantStatus: http.StatusUnauthorized},
+		{name: "Ping", event: "ping", payload: `{"zen":"hi"}`, signature: sign(`{"zen":"hi"}`), wantStatus: http.StatusAccepted},
+		{name: "Ignored action", event: "pull_request", payload: `{"action":"closed","number":1,"pull_request":{"head":{"sha":"head"}},"repository":{"full_name":"owner/repo"}}`,
+			signature: sign(`{"action":"closed","number":1,"pull_request":{"head":{"sha":"head"}},"repository":{"full_name":"owner/repo"}}`), wantStatus: http.StatusAccepted},
+		{name: "Invalid payload", event: "pull_request", payload: `{"action":"opened"}`, signature: sign(`{"action":"opened"}`), wantStatus: http.StatusBadRequest},
+		{name: "Queue full", event: "pull_request", payload: prPayload, signature: sign(prPayload), queueSize: 1, queued: 1, wantStatus: http.StatusServiceUnavailable, wantQueued: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t, tc.queueSize)
+			for i := 0; i < tc.queued; i++ {
+				s.queue <- &job{}
+			}
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tc.payload))
+			req.Header.Set(HEADER_EVENT, tc.event)
+			req.Header.Set(HEADER_DELIVERY, "delivery")
+			req.Header.Set(HEADER_SIGNATURE, tc.signature)
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if len(s.queue) != tc.wantQueued {
+				t.Errorf("queued = %d, want %d", len(s.queue), tc.wantQueued)
+			}
+		})
+	}
+}
+
+func TestWorker(t *testing.T) {
+	s := newTestServer(t, 0)
+	payload := []byte(`{"action":"opened"}`)
+	var gotOpts []*review.ReviewOption
+	s.fetch = func(ctx context.Context, opt *review.ReviewOption, j *job) error {
+		if j.HeadSHA == "fetch_error" {
+			return errors.New("fetch error")
+		}
+		return os.MkdirAll(opt.GithubWorkspace, 0755)
+	}
+	s.runReview = func(ctx context.Context, opt *review.ReviewOption, logger *slog.Logger) error {
+		event, err := os.ReadFile(opt.GithubEventPath)
+		if err != nil || !bytes.Equal(event, payload) {
+			t.Errorf("event = %s, err = %v", event, err)
+		}
+		gotOpts = append(gotOpts, opt)
+		return nil
+	}
+	s.queue <- &job{Delivery: "1", Repository: "owner/repo", Number: 1, HeadSHA: "head", InstallationID: 123, Payload: payload}
+	s.queue <- &job{Delivery: "2", Repository: "owner/repo", Number: 2, HeadSHA: "fetch_error", Payload: payload}
+	close(s.queue)
+	s.worker(context.Background())
+
+	if len(gotOpts) != 1 {
+		t.Fatalf("reviews = %d, want 1", len(gotOpts))
+	}
+	got := gotOpts[0]
+	want := &review.ReviewOption{
+		GithubAppID:             1,
+		GithubAppPrivateKey:     "key",
+		GithubAppInstallationID: 123,
+		GithubRepository:        "owner/repo",
+		GithubEventName:         review.EVENT_PULL_REQUEST,
+		GithubEventPath:         got.GithubEventPath,
+		GithubWorkspace:         got.GithubWorkspace,
+		GithubSHA:               "head",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("review option mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := os.Stat(got.GithubWorkspace); !os.IsNotExist(err) {
+		t.Errorf("workspace is not removed: %v", err)
+	}
+
+	var metrics strings.Builder
+	if err := s.metrics.write(&metrics, len(s.queue)); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`risken_review_jobs_total{result="failure"} 1`,
+		`risken_review_jobs_total{result="success"} 1`,
+		`risken_review_jobs_running 0`,
+		`risken_review_job_duration_seconds_count 2`,
+	} {
+		if !strings.Contains(metrics.String(), line+"\n") {
+			t.Errorf("metrics does not contain %q:\n%s", line, metrics.String())
+		}
+	}
+}
+
+func TestFetchPullRequest(t *testing.T) {
+	origin := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", origin}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v, %s", args, err, out)
+		}
+	}
+	out, err := exec.Command("git", "-C", origin, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headSHA := strings.TrimSpace(string(out))
+
+	s := newTestServer(t, 0)
+	workspace := filepath.Join(t.TempDir(), "workspace")
+	opt := &review.ReviewOption{GithubToken: "token", GithubWorkspace: workspace}
+	if err := s.fetchPullRequest(context.Background(), opt, &job{CloneURL: origin, HeadSHA: headSHA}); err != nil {
+		t.Fatalf("fetchPullRequest() error = %v", err)
+	}
+	out, err = exec.Command("git", "-C", workspace, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != headSHA {
+		t.Errorf("HEAD = %s, want %s", got, headSHA)
+	}
+}
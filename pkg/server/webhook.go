@@ -0,0 +1,114 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v44/github"
+)
+
+const (
+	maxPayloadBytes = 25 * 1024 * 1024 // GitHub caps the payloads at 25MB
+
+	HEADER_EVENT     = "X-GitHub-Event"
+	HEADER_DELIVERY  = "X-GitHub-Delivery"
+	HEADER_SIGNATURE = "X-Hub-Signature-256"
+)
+
+// reviewActions are the pull_request actions to review.
+var reviewActions = map[string]bool{
+	"opened":           true,
+	"synchronize":      true,
+	"reopened":         true,
+	"ready_for_review": true,
+}
+
+// pullRequestWebhook is the subset of the pull_request webhook payload.
+type pullRequestWebhook struct {
+	Action       string               `json:"action"`
+	Number       int                  `json:"number"`
+	PullRequest  *github.PullRequest  `json:"pull_request"`
+	Repository   *github.Repository   `json:"repository"`
+	Installation *github.Installation `json:"installation"`
+}
+
+// verifySignature verifies the HMAC-SHA256 signature (sha256=<hex>) of the payload.
+func verifySignature(secret string, payload []byte, signature string) bool {
+	sig, found := strings.CutPrefix(signature, "sha256=")
+	if !found {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadBytes+1))
+	if err != nil || len(payload) > maxPayloadBytes {
+		s.metrics.webhook(WEBHOOK_BAD_REQUEST)
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if !verifySignature(s.cfg.WebhookSecret, payload, r.Header.Get(HEADER_SIGNATURE)) {
+		s.metrics.webhook(WEBHOOK_INVALID_SIGNATURE)
+		s.logger.WarnContext(r.Context(), "Invalid webhook signature", slog.String("delivery", r.Header.Get(HEADER_DELIVERY)))
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := r.Header.Get(HEADER_EVENT)
+	delivery := r.Header.Get(HEADER_DELIVERY)
+	logger := s.logger.With(slog.String("event", event), slog.String("delivery", delivery))
+	if event != "pull_request" {
+		s.metrics.webhook(WEBHOOK_IGNORED)
+		w.WriteHeader(http.StatusAccepted) // e.g. ping
+		return
+	}
+	var pr pullRequestWebhook
+	if err := json.Unmarshal(payload, &pr); err != nil || pr.PullRequest == nil || pr.PullRequest.Head == nil || pr.Repository == nil {
+		s.metrics.webhook(WEBHOOK_BAD_REQUEST)
+		http.Error(w, "invalid pull_request payload", http.StatusBadRequest)
+		return
+	}
+	if !reviewActions[pr.Action] {
+		s.metrics.webhook(WEBHOOK_IGNORED)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	j := &job{
+		Delivery:       delivery,
+		Repository:     pr.Repository.GetFullName(),
+		CloneURL:       pr.Repository.GetCloneURL(),
+		Number:         pr.Number,
+		HeadSHA:        pr.PullRequest.Head.GetSHA(),
+		InstallationID: pr.Installation.GetID(),
+		Payload:        payload,
+	}
+	select {
+	case s.queue <- j:
+		s.metrics.webhook(WEBHOOK_ACCEPTED)
+		logger.InfoContext(r.Context(), "Queued review", slog.String("repository", j.Repository), slog.Int("number", j.Number), slog.String("head", j.HeadSHA))
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		// GitHub does not redeliver automatically, the failed deliveries can be redelivered from the App settings
+		s.metrics.webhook(WEBHOOK_QUEUE_FULL)
+		logger.WarnContext(r.Context(), "Review queue is full", slog.String("repository", j.Repository), slog.Int("number", j.Number))
+		http.Error(w, "review queue is full", http.StatusServiceUnavailable)
+	}
+}
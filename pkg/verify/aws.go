@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	awsAccessKeyIDRegexp = regexp.MustCompile(`^(?:AKIA|ASIA)[A-Z0-9]{16}$`)
+	awsSecretKeyRegexp   = regexp.MustCompile(`(?:^|[^A-Za-z0-9/+=])([A-Za-z0-9/+=]{40})(?:[^A-Za-z0-9/+=]|$)`)
+	awsErrorCodeRegexp   = regexp.MustCompile(`<Code>([^<]+)</Code>`)
+	awsArnRegexp         = regexp.MustCompile(`<Arn>([^<]+)</Arn>`)
+	awsInvalidErrorCodes = map[string]bool{"InvalidClientTokenId": true, "SignatureDoesNotMatch": true, "ExpiredToken": true}
+)
+
+const (
+	awsRegion  = "us-east-1"
+	awsService = "sts"
+)
+
+// awsVerifier verifies the access key ID with STS GetCallerIdentity, paired with the secret access key found around it.
+type awsVerifier struct {
+	endpoint string
+	client   *http.Client
+	now      func() time.Time
+}
+
+func (v *awsVerifier) Name() string {
+	return VERIFIER_AWS
+}
+
+func (v *awsVerifier) Match(c *Credential) bool {
+	return awsAccessKeyIDRegexp.MatchString(c.Secret)
+}
+
+func (v *awsVerifier) Verify(ctx context.Context, c *Credential) (*Result, error) {
+	secretKey := findAWSSecretKey(c.Context)
+	if secretKey == "" {
+		return &Result{Verifier: v.Name(), Status: STATUS_UNKNOWN, Message: "secret access key is not found around the access key ID"}, nil
+	}
+	body := []byte("Action=GetCallerIdentity&Version=2011-06-15")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signV4(req, body, c.Secret, secretKey, awsRegion, awsService, v.now())
+	code, resp, err := doRequest(v.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify aws access key: %w", err)
+	}
+	if code == http.StatusOK {
+		message := ""
+		if m := awsArnRegexp.FindSubmatch(resp); m != nil {
+			message = fmt.Sprintf("arn=%s", m[1])
+		}
+		return &Result{Verifier: v.Name(), Status: STATUS_LIVE, Message: message}, nil
+	}
+	errorCode := ""
+	if m := awsErrorCodeRegexp.FindSubmatch(resp); m != nil {
+		errorCode = string(m[1])
+	}
+	if awsInvalidErrorCodes[errorCode] {
+		return &Result{Verifier: v.Name(), Status: STATUS_INVALID, Message: errorCode}, nil
+	}
+	return &Result{Verifier: v.Name(), Status: STATUS_UNKNOWN, Message: fmt.Sprintf("GetCallerIdentity: %d %s", code, errorCode)}, nil
+}
+
+// findAWSSecretKey returns the first 40 characters token looking like the secret access key (git SHAs are excluded by the mixed case).
+func findAWSSecretKey(lines []string) string {
+	for _, line := range lines {
+		for _, m := range awsSecretKeyRegexp.FindAllStringSubmatch(line, -1) {
+			if strings.ToLower(m[1]) != m[1] && strings.ToUpper(m[1]) != m[1] {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// signV4 signs the request with AWS Signature Version 4.
+// ref: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func signV4(req *http.Request, body []byte, accessKeyID, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURIPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalURIPath(u *url.URL) string {
+	if u.EscapedPath() == "" {
+		return "/"
+	}
+	return u.EscapedPath()
+}
+
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sha256Hex(b []byte) string {
+	hash := sha256.Sum256(b)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,35 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// bearerVerifier sends the secret as the bearer token to the configured endpoint (e.g. the internal API).
+// It matches any secret, so it must be the last verifier.
+type bearerVerifier struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (v *bearerVerifier) Name() string {
+	return VERIFIER_BEARER
+}
+
+func (v *bearerVerifier) Match(c *Credential) bool {
+	return c.Secret != ""
+}
+
+func (v *bearerVerifier) Verify(ctx context.Context, c *Credential) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Secret)
+	code, _, err := doRequest(v.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify bearer token: %w", err)
+	}
+	return &Result{Verifier: v.Name(), Status: statusFromHTTP(code), Message: fmt.Sprintf("GET %s: %d", v.endpoint, code)}, nil
+}
@@ -0,0 +1,38 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+var githubTokenRegexp = regexp.MustCompile(`^(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_\w{22,})$`)
+
+// githubVerifier verifies the GitHub tokens with GET /user.
+type githubVerifier struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (v *githubVerifier) Name() string {
+	return VERIFIER_GITHUB
+}
+
+func (v *githubVerifier) Match(c *Credential) bool {
+	return githubTokenRegexp.MatchString(c.Secret)
+}
+
+func (v *githubVerifier) Verify(ctx context.Context, c *Credential) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.endpoint+"/user", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+c.Secret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	code, _, err := doRequest(v.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify github token: %w", err)
+	}
+	return &Result{Verifier: v.Name(), Status: statusFromHTTP(code), Message: fmt.Sprintf("GET /user: %d", code)}, nil
+}
@@ -0,0 +1,56 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+var slackTokenRegexp = regexp.MustCompile(`^xox[abposr]-[0-9A-Za-z-]{10,}$`)
+
+// slackVerifier verifies the Slack tokens with auth.test.
+type slackVerifier struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (v *slackVerifier) Name() string {
+	return VERIFIER_SLACK
+}
+
+func (v *slackVerifier) Match(c *Credential) bool {
+	return slackTokenRegexp.MatchString(c.Secret)
+}
+
+func (v *slackVerifier) Verify(ctx context.Context, c *Credential) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint+"/api/auth.test", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Secret)
+	code, body, err := doRequest(v.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify slack token: %w", err)
+	}
+	if code != http.StatusOK {
+		return &Result{Verifier: v.Name(), Status: STATUS_UNKNOWN, Message: fmt.Sprintf("auth.test: %d", code)}, nil
+	}
+	// auth.test returns 200 with {"ok": false, "error": "invalid_auth"} for the invalid tokens.
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		Team  string `json:"team"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode auth.test response: %w", err)
+	}
+	switch {
+	case resp.OK:
+		return &Result{Verifier: v.Name(), Status: STATUS_LIVE, Message: fmt.Sprintf("team=%s", resp.Team)}, nil
+	case resp.Error == "invalid_auth" || resp.Error == "account_inactive" || resp.Error == "token_revoked" || resp.Error == "token_expired":
+		return &Result{Verifier: v.Name(), Status: STATUS_INVALID, Message: resp.Error}, nil
+	}
+	return &Result{Verifier: v.Name(), Status: STATUS_UNKNOWN, Message: resp.Error}, nil
+}
@@ -0,0 +1,135 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Status string
+
+const (
+	STATUS_LIVE    Status = "live"
+	STATUS_INVALID Status = "invalid"
+	STATUS_UNKNOWN Status = "unknown"
+)
+
+const (
+	VERIFIER_AWS    = "aws"
+	VERIFIER_GITHUB = "github"
+	VERIFIER_SLACK  = "slack"
+	VERIFIER_BEARER = "bearer"
+)
+
+// Result is the outcome of the secret liveness verification.
+type Result struct {
+	Verifier string `json:"verifier"`
+	Status   Status `json:"status"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Credential is a detected secret and the lines around it (e.g. AWS secret access key paired with the access key ID).
+type Credential struct {
+	Secret  string
+	Context []string
+}
+
+// Verifier checks whether the credential is live against the provider endpoint.
+type Verifier interface {
+	Name() string
+	Match(c *Credential) bool
+	Verify(ctx context.Context, c *Credential) (*Result, error)
+}
+
+var defaultEndpoints = map[string]string{
+	VERIFIER_AWS:    "https://sts.amazonaws.com",
+	VERIFIER_GITHUB: "https://api.github.com",
+	VERIFIER_SLACK:  "https://slack.com",
+}
+
+// NewVerifiers returns the verifiers in the matching order.
+// The endpoints override the default endpoints by the verifier name (e.g. for the local stub),
+// and the generic bearer verifier is enabled only if its endpoint is given.
+func NewVerifiers(endpoints map[string]string, client *http.Client) ([]Verifier, error) {
+	for name := range endpoints {
+		if _, ok := defaultEndpoints[name]; !ok && name != VERIFIER_BEARER {
+			return nil, fmt.Errorf("unknown verifier: %s", name)
+		}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	endpoint := func(name string) string {
+		if e, ok := endpoints[name]; ok && e != "" {
+			return strings.TrimSuffix(e, "/")
+		}
+		return defaultEndpoints[name]
+	}
+	verifiers := []Verifier{
+		&awsVerifier{endpoint: endpoint(VERIFIER_AWS), client: client, now: time.Now},
+		&githubVerifier{endpoint: endpoint(VERIFIER_GITHUB), client: client},
+		&slackVerifier{endpoint: endpoint(VERIFIER_SLACK), client: client},
+	}
+	if e := endpoint(VERIFIER_BEARER); e != "" {
+		verifiers = append(verifiers, &bearerVerifier{endpoint: e, client: client})
+	}
+	return verifiers, nil
+}
+
+// Verify verifies the credential with the first matched verifier. It returns nil if no verifier matches.
+// Errors (e.g. network errors) are reported as the unknown status not to fail the review.
+func Verify(ctx context.Context, verifiers []Verifier, c *Credential) *Result {
+	for _, v := range verifiers {
+		if !v.Match(c) {
+			continue
+		}
+		result, err := v.Verify(ctx, c)
+		if err != nil {
+			return &Result{Verifier: v.Name(), Status: STATUS_UNKNOWN, Message: err.Error()}
+		}
+		return result
+	}
+	return nil
+}
+
+// Names returns the verifier names for the flag description.
+func Names() []string {
+	names := []string{VERIFIER_BEARER}
+	for name := range defaultEndpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// statusFromHTTP maps the common HTTP status codes of the authenticated APIs.
+// Only 401 means the invalid credential. 403 is unknown, because the valid credential is also forbidden
+// by the rate limit, the missing scope or the SSO enforcement (e.g. GitHub).
+func statusFromHTTP(code int) Status {
+	switch {
+	case code >= 200 && code < 300:
+		return STATUS_LIVE
+	case code == http.StatusUnauthorized:
+		return STATUS_INVALID
+	}
+	return STATUS_UNKNOWN
+}
+
+func doRequest(client *http.Client, req *http.Request) (int, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if closeErr := resp.Body.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}